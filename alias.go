@@ -0,0 +1,90 @@
+package anyhttp
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// AliasFile is the default file used to resolve alias addresses, e.g. alias?name=prod-api.
+// Set from $ANYHTTP_ALIAS_FILE if present, else /etc/anyhttp/aliases
+//
+// Each line of the file is name = address. Empty lines and lines starting with # are ignored
+//
+//	# Changing the address only needs a restart
+//	prod-api = unix?path=/run/api/api.sock&mode=660
+//	admin    = sysd?name=admin.socket&idle_timeout=30m
+var AliasFile = func() string {
+	if f := os.Getenv("ANYHTTP_ALIAS_FILE"); f != "" {
+		return f
+	}
+	return "/etc/anyhttp/aliases"
+}()
+
+// parseAlias parses alias?name=<name>&file=<path>
+func parseAlias(addr string, query url.Values) (*address, error) {
+	a := &address{}
+	name, file := "", AliasFile
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("alias address error. Multiple %v found: %v", key, val)
+		}
+		if key == "name" {
+			name = val[0]
+		} else if key == "file" {
+			file = val[0]
+		} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return nil, fmt.Errorf("alias address error. %w", cerr)
+		} else if !ok {
+			return nil, fmt.Errorf("alias address error. Bad option; key: %v, val: %v", key, val)
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("alias address error. Missing name; addr: %v", addr)
+	}
+	target, err := lookupAlias(file, name)
+	if err != nil {
+		return nil, fmt.Errorf("alias address error. %w", err)
+	}
+	if base, _, _ := strings.Cut(target, "?"); base == "alias" {
+		return nil, fmt.Errorf("alias address error. %v in %v can not be another alias: %v", name, file, target)
+	}
+	ta, err := parseAddr(target)
+	if err != nil {
+		return nil, fmt.Errorf("alias address error. Bad address for %v in %v: %w", name, file, err)
+	}
+	if err := ta.mergeCommon(a); err != nil {
+		return nil, err
+	}
+	return ta, nil
+}
+
+func lookupAlias(file, name string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return "", fmt.Errorf("bad line %v in %v, expected name = address: %q", lineNum, file, line)
+		}
+		if strings.TrimSpace(key) == name {
+			return strings.TrimSpace(val), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("alias %q not found in %v", name, file)
+}
@@ -0,0 +1,44 @@
+package anyhttp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	aliasFile := filepath.Join(t.TempDir(), "aliases")
+	err := os.WriteFile(aliasFile, []byte(`
+# comment
+prod-api = unix?path=/run/api.sock&mode=660
+admin=sysd?name=admin.socket
+loop = alias?name=prod-api
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f string) { AliasFile = f }(AliasFile)
+	AliasFile = aliasFile
+
+	a, err := parseAddr("alias?name=prod-api&shutdown_timeout=5s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.addrType != UnixSocket || a.usc.SocketPath != "/run/api.sock" || a.usc.SocketMode != 0660 || a.srvOpts.shutdownTimeout.String() != "5s" {
+		t.Errorf("parseAddr() = %+v, usc: %v", a, a.usc)
+	}
+
+	a, err = parseAddr("alias?name=admin&file=" + aliasFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.addrType != SystemdFD || *a.sysc.FDName != "admin.socket" {
+		t.Errorf("parseAddr() = %+v, sysc: %v", a, a.sysc)
+	}
+
+	for _, addr := range []string{"alias?name=missing", "alias?name=loop", "alias", "alias?name=admin&file=/nonexistent"} {
+		if _, err := parseAddr(addr); err == nil {
+			t.Errorf("parseAddr(%q) should fail", addr)
+		}
+	}
+}
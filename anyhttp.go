@@ -3,20 +3,25 @@ package anyhttp
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io/fs"
+	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"go.balki.me/anyhttp/idle"
+	"go.balki.me/anyhttp/journal"
 )
 
 // AddressType of the address passed
@@ -29,21 +34,41 @@ var (
 	SystemdFD AddressType = "SystemdFD"
 	// TCP - address is a TCP address, e.g. :1234
 	TCP AddressType = "TCP"
+	// UDP - address is a udp address, e.g. udp?addr=:5353. Only for GetPacketConn
+	UDP AddressType = "UDP"
+	// S6FD - address is a listening fd passed by s6 or runit, e.g. s6?env=S6_FD
+	S6FD AddressType = "S6FD"
+	// Vsock - address is a vsock port, e.g. vsock?cid=3&port=8080
+	Vsock AddressType = "Vsock"
+	// Socketpair - served on one end of a socketpair, see ServeSocketpair
+	Socketpair AddressType = "Socketpair"
 	// Unknown - address is not recognized
 	Unknown AddressType = "Unknown"
 )
 
+// Strict when set, addresses that match no builtin or registered scheme, nginx or systemd listen syntax, and are not a
+// valid host:port tcp address either fail to parse instead of being passed as is to net.Listen. Also fails for a bad
+// query in tcp addresses. Catches typos like unixx?path=/run/app.sock
+var Strict = false
+
 // UnixSocketConfig has the configuration for Unix socket
 type UnixSocketConfig struct {
 
-	// Absolute or relative path of socket, e.g. /run/app.sock
-	SocketPath string
+	// Absolute or relative path of socket, e.g. /run/app.sock. On linux, @name is in the abstract namespace, without a file
+	SocketPath string `json:"path" yaml:"path"`
 
-	// Socket file permission
-	SocketMode fs.FileMode
+	// Socket file permission. An octal string in config files, e.g. "660"
+	SocketMode fs.FileMode `json:"mode" yaml:"-"`
 
 	// Whether to delete existing socket before creating new one
-	RemoveExisting bool
+	RemoveExisting bool `json:"remove_existing" yaml:"remove_existing"`
+
+	// Interval to check the socket file still exists, e.g. not deleted by tmpfiles cleanup. If removed or replaced, the socket
+	// is created again and the listener switches to it transparently. 0 disables
+	WatchInterval time.Duration `json:"watch,omitempty" yaml:"watch,omitempty"`
+
+	// Socket type, stream, seqpacket or dgram. Empty is stream for GetListener and dgram for GetPacketConn
+	SocketType string `json:"type,omitempty" yaml:"type,omitempty"`
 }
 
 // DefaultUnixSocketConfig has defaults for UnixSocketConfig
@@ -95,15 +120,28 @@ func parse() (sysdEnvData, error) {
 // SysdConfig has the configuration for the socket activated fd
 type SysdConfig struct {
 	// Integer value starting at 0. Either index or name is required
-	FDIndex *int
+	FDIndex *int `json:"idx,omitempty" yaml:"idx,omitempty"`
 	// Name configured via FileDescriptorName or the default socket file name. Either index or name is required
-	FDName *string
+	FDName *string `json:"name,omitempty" yaml:"name,omitempty"`
 	// Check process PID matches LISTEN_PID
-	CheckPID bool
+	CheckPID bool `json:"check_pid" yaml:"check_pid"`
 	// Unsets the LISTEN* environment variables, so they don't get passed to any child processes
-	UnsetEnv bool
+	UnsetEnv bool `json:"unset_env" yaml:"unset_env"`
 	// Shutdown http server if no requests received for below timeout
-	IdleTimeout *time.Duration
+	IdleTimeout *time.Duration `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty"`
+	// Exit the process with this code after shutting down on idle_timeout, instead of sending to Done. Deferred functions
+	// of the caller are not run, functions passed to ServerCtx.RegisterOnShutdown are
+	IdleExitCode *int `json:"idle_exit,omitempty" yaml:"idle_exit,omitempty"`
+	// Send STOPPING=1 to systemd before shutting down on idle_timeout. Sent if nil
+	IdleNotify *bool `json:"idle_notify,omitempty" yaml:"idle_notify,omitempty"`
+	// Serve on all socket activated fds instead of one selected by index or name. Only with Serve, see ServeAllSystemdFDs
+	All bool `json:"all,omitempty" yaml:"all,omitempty"`
+	// The fd is a connection accepted by systemd, i.e. Accept=yes in the socket unit. Only that connection is served and
+	// the server is done once it is closed. name and idx are optional, defaults to the first fd
+	Accepted bool `json:"accepted,omitempty" yaml:"accepted,omitempty"`
+	// Verify the fd is a socket of this network before using it, e.g. tcp, tcp6, unix or udp. Like sd_is_socket, gives a
+	// clear error when the socket unit does not match the app's config. Not checked if empty
+	Network string `json:"network,omitempty" yaml:"network,omitempty"`
 }
 
 // DefaultSysdConfig has the default values for SysdConfig
@@ -129,24 +167,63 @@ func NewSysDConfigWithFDName(fdName string) SysdConfig {
 // GetListener returns the unix socket listener
 func (u *UnixSocketConfig) GetListener() (net.Listener, error) {
 
-	if u.RemoveExisting {
-		if err := os.Remove(u.SocketPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
-			return nil, err
-		}
+	network, err := u.streamNetwork()
+	if err != nil {
+		return nil, err
 	}
 
-	l, err := net.Listen("unix", u.SocketPath)
+	if u.isAbstract() {
+		// No socket file to remove, chmod or watch. Closed when the listener is closed
+		return net.Listen(network, u.SocketPath)
+	}
+
+	if err := u.removeExisting(); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen(network, u.SocketPath)
 	if err != nil {
 		return nil, err
 	}
 
 	if err = os.Chmod(u.SocketPath, u.SocketMode); err != nil {
+		l.Close()
 		return nil, err
 	}
 
+	if u.WatchInterval > 0 {
+		return watchUnixListener(u, l)
+	}
 	return l, nil
 }
 
+// streamNetwork returns the network for net.Listen, unix or unixpacket for seqpacket
+func (u *UnixSocketConfig) streamNetwork() (string, error) {
+	switch u.SocketType {
+	case "", "stream":
+		return "unix", nil
+	case "seqpacket":
+		return "unixpacket", nil
+	}
+	return "", fmt.Errorf("unix socket of type %v is not a stream socket, use GetPacketConn", u.SocketType)
+}
+
+// removeExisting removes the socket file left by a previous run if RemoveExisting is set
+func (u *UnixSocketConfig) removeExisting() error {
+	if !u.RemoveExisting {
+		return nil
+	}
+	if err := os.Remove(u.SocketPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// isAbstract checks if the socket is in the linux abstract namespace, i.e. path starts with @. Elsewhere @ is part of the file name
+func (u *UnixSocketConfig) isAbstract() bool {
+	return strings.HasPrefix(u.SocketPath, "@") && (runtime.GOOS == "linux" || runtime.GOOS == "android")
+}
+
 // StartFD is the starting file descriptor number
 const StartFD = 3
 
@@ -156,10 +233,22 @@ func makeFdListener(fd int, name string) (net.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
-	syscall.CloseOnExec(fd)
+	closeOnExec(fd)
 	return l, nil
 }
 
+// envData returns the parsed LISTEN* environment variables, checking LISTEN_PID if enabled
+func (s *SysdConfig) envData() (sysdEnvData, error) {
+	envData, err := parse()
+	if err != nil {
+		return envData, err
+	}
+	if s.CheckPID && envData.pid != os.Getpid() {
+		return envData, fmt.Errorf("unexpected PID, current:%v, LISTEN_PID: %v", os.Getpid(), envData.pid)
+	}
+	return envData, nil
+}
+
 // GetListener returns the FileListener created with socketed activated fd
 func (s *SysdConfig) GetListener() (net.Listener, error) {
 
@@ -167,68 +256,192 @@ func (s *SysdConfig) GetListener() (net.Listener, error) {
 		defer UnsetSystemdListenVars()
 	}
 
-	envData, err := parse()
+	fd, name, err := s.fd()
 	if err != nil {
 		return nil, err
 	}
+	if isDatagram(fd) {
+		return nil, fmt.Errorf("fd %v, name: %q is a datagram socket, e.g. ListenDatagram=, use GetPacketConn", fd, name)
+	}
+	if err := s.verify(fd, name, !s.Accepted); err != nil {
+		return nil, err
+	}
+	if s.Accepted {
+		return makeFdConnListener(fd, name)
+	}
+	return makeFdListener(fd, name)
+}
+
+// fd returns the socket activated fd selected by FDIndex or FDName, and its name
+func (s *SysdConfig) fd() (int, string, error) {
+
+	if s.All {
+		return 0, "", errors.New("all socket activated fds can not be used as one, use Serve or ServeAllSystemdFDs")
+	}
 
-	if s.CheckPID {
-		if envData.pid != os.Getpid() {
-			return nil, fmt.Errorf("unexpected PID, current:%v, LISTEN_PID: %v", os.Getpid(), envData.pid)
-		}
+	envData, err := s.envData()
+	if err != nil {
+		return 0, "", err
 	}
 
-	if s.FDIndex != nil {
-		idx := *s.FDIndex
+	fdIndex := s.FDIndex
+	if fdIndex == nil && s.FDName == nil && s.Accepted {
+		// Accept=yes passes only the connection
+		fdIndex = new(int)
+	}
+	if fdIndex != nil {
+		idx := *fdIndex
 		if idx < 0 || idx >= envData.numFds {
-			return nil, fmt.Errorf("invalid fd index, expected between 0 and %v, got: %v", envData.numFds, idx)
+			return 0, "", fmt.Errorf("invalid fd index, expected between 0 and %v, got: %v", envData.numFds, idx)
 		}
 		fd := StartFD + idx
 		if idx < len(envData.fdNames) {
-			return makeFdListener(fd, envData.fdNames[idx])
+			return fd, envData.fdNames[idx], nil
 		}
-		return makeFdListener(fd, fmt.Sprintf("sysdfd_%d", fd))
+		return fd, fmt.Sprintf("sysdfd_%d", fd), nil
 	}
 
 	if s.FDName != nil {
 		for idx, name := range envData.fdNames {
 			if name == *s.FDName {
-				fd := StartFD + idx
-				return makeFdListener(fd, name)
+				return StartFD + idx, name, nil
 			}
 		}
-		return nil, fmt.Errorf("fdName not found: %q, LISTEN_FDNAMES:%q", *s.FDName, envData.fdNamesStr)
+		return 0, "", fmt.Errorf("fdName not found: %q, LISTEN_FDNAMES:%q", *s.FDName, envData.fdNamesStr)
 	}
 
-	return nil, errors.New("neither FDIndex nor FDName set")
+	return 0, "", errors.New("neither FDIndex nor FDName set")
 }
 
 // GetListener is low level function for use with non-http servers. e.g. tcp, smtp
-// Caller should handle idle timeout if needed
+// Caller should handle idle timeout if needed, e.g. with idle.WrapListener
 func GetListener(addr string) (net.Listener, AddressType, any /* cfg */, error) {
 
-	addrType, unixSocketConfig, sysdConfig, perr := parseAddress(addr)
+	a, perr := parseAddr(addr)
 	if perr != nil {
 		return nil, Unknown, nil, perr
 	}
-	if unixSocketConfig != nil {
-		listener, err := unixSocketConfig.GetListener()
-		if err != nil {
-			return nil, Unknown, nil, err
+	listener, cfg, err := a.listen()
+	if err != nil {
+		return nil, Unknown, nil, err
+	}
+	return a.sockOpts.wrap(listener), a.addrType, cfg, nil
+}
+
+func (a *address) listen() (net.Listener, any, error) {
+	if a.fdStore != "" {
+		return a.listenFDStore()
+	}
+	if a.handoff != "" {
+		if a.lock != "" {
+			return nil, nil, errors.New("lock can not be used with handoff, the running instance holds the lock")
+		}
+		listener, err := takeover(a.handoff)
+		if err != nil || listener != nil {
+			return listener, a.cfg(), err
 		}
-		return listener, addrType, unixSocketConfig, nil
-	} else if sysdConfig != nil {
-		listener, err := sysdConfig.GetListener()
+	}
+	if a.lock != "" {
+		path, err := a.lockPath()
 		if err != nil {
-			return nil, Unknown, nil, err
+			return nil, nil, err
 		}
-		return listener, addrType, sysdConfig, nil
+		// Lock is retried too, the old instance holds it until its listener is closed
+		listener, err := retryBind(a.bindRetry, func() (net.Listener, error) {
+			return lockListen(path, func() (net.Listener, error) { return a.bind(0) })
+		})
+		return listener, a.cfg(), err
 	}
-	if addr == "" {
-		addr = ":http"
+	listener, err := a.bind(a.bindRetry)
+	return listener, a.cfg(), err
+}
+
+// listenFDStore takes the listener from the systemd fd store, or binds and stores it on first start
+func (a *address) listenFDStore() (net.Listener, any, error) {
+	if a.handoff != "" || a.lock != "" || a.sysc != nil {
+		return nil, nil, errors.New("fd_store error. Can not be used with handoff, lock or sysd addresses")
+	}
+	listener, err := storedListener(a.fdStore)
+	if err != nil || listener != nil {
+		return listener, a.cfg(), err
 	}
-	listener, err := net.Listen("tcp", addr)
-	return listener, TCP, nil, err
+	if listener, err = a.bind(a.bindRetry); err != nil {
+		return nil, nil, err
+	}
+	if err := storeListener(a.fdStore, listener); err != nil {
+		listener.Close()
+		return nil, nil, err
+	}
+	return listener, a.cfg(), nil
+}
+
+// bind creates the listener for the address, retrying upto bindRetry while the address is in use
+func (a *address) bind(bindRetry time.Duration) (net.Listener, error) {
+	if a.schemeListen != nil {
+		l, err := a.schemeListen(a.schemeQuery)
+		if st, ok := l.(interface{ SetShutdownTimeout(time.Duration) }); ok && err == nil {
+			st.SetShutdownTimeout(a.srvOpts.shutdownTimeout)
+		}
+		return l, err
+	}
+	if a.usc != nil {
+		return retryBind(bindRetry, a.usc.GetListener)
+	} else if a.sysc != nil {
+		return a.sysc.GetListener()
+	} else if a.s6c != nil {
+		return a.s6c.GetListener()
+	} else if a.vsc != nil {
+		return retryBind(bindRetry, a.vsc.GetListener)
+	} else if a.udpc != nil {
+		return nil, errors.New("udp address error. Not a stream address, use GetPacketConn")
+	}
+	tcpAddr := a.tcpAddr
+	if tcpAddr == "" {
+		tcpAddr = ":http"
+	}
+	return retryBind(bindRetry, func() (net.Listener, error) {
+		return a.tcpOpts.listen(tcpAddr)
+	})
+}
+
+// cfg returns the config returned by GetListener
+func (a *address) cfg() any {
+	if a.usc != nil {
+		return a.usc
+	} else if a.sysc != nil {
+		return a.sysc
+	} else if a.s6c != nil {
+		return a.s6c
+	} else if a.vsc != nil {
+		return a.vsc
+	} else if a.udpc != nil {
+		return a.udpc
+	}
+	return nil
+}
+
+// DialContext connects to the address a server is listening on. Useful for clients and proxies that take the same address syntax.
+// Only unix and tcp addresses can be dialed
+func DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	a, err := parseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	switch a.addrType {
+	case UnixSocket:
+		network, err := a.usc.streamNetwork()
+		if err != nil {
+			return nil, err
+		}
+		return d.DialContext(ctx, network, a.usc.SocketPath)
+	case TCP:
+		if a.tcpAddr == "" {
+			return nil, errors.New("tcp address error. Empty address can not be dialed")
+		}
+		return d.DialContext(ctx, a.tcpOpts.networkName(), a.tcpAddr)
+	}
+	return nil, fmt.Errorf("address type %v can not be dialed: %q", a.addrType, addr)
 }
 
 type ServerCtx struct {
@@ -239,32 +452,360 @@ type ServerCtx struct {
 	Done             <-chan error
 	UnixSocketConfig *UnixSocketConfig
 	SysdConfig       *SysdConfig
+	VsockConfig      *VsockConfig
+	// Certificate and key files when serving https with ServeTLS or cert and key options. Nil otherwise
+	TLSConfig *TLSConfig
+	// All listeners served, more than one for ServeNamed. Listener is the first one
+	Listeners []net.Listener
+
+	conns           *connTracker
+	shutdownTimeout time.Duration
+	maintenance     atomic.Bool
+	maintenanceResp atomic.Pointer[MaintenanceResponse]
+	drainRetryAfter time.Duration
+	draining        atomic.Bool
+
+	progressInterval time.Duration
+	progressFn       atomic.Pointer[func(DrainProgress)]
+
+	// closed after WithWarmup func returns, nil without warmup. warmupErr is set before closing if it failed
+	warmupDone chan struct{}
+	warmupErr  error
+
+	ready *readyFile
+	// http.Server.ErrorLog writing to the journal socket, set by journal option
+	journalLog *log.Logger
+	// $NOTIFY_SOCKET when the server is started, to notify systemd. Empty when not set or disabled with sd_notify=false
+	notifySocket string
+	stopping     sync.Once
+	// closed when the server stops serving
+	stopped chan struct{}
+	// Done, buffered so that the server stops even if nobody receives
+	done chan error
+	// closed after the final error is set to err and sent to Done, i.e. after draining too
+	finished chan struct{}
+	err      error
+	// closed to drain the server, by ServeContext or WithShutdownSignals
+	drainReq  chan struct{}
+	drainOnce sync.Once
+	// Set by RegisterOnShutdown, run once the server stopped
+	onShutdownMu  sync.Mutex
+	onShutdown    []func()
+	onShutdownRan bool
+
+	// Set by WithReload, handler is swapped on reload
+	reload   ReloadFunc
+	reloadMu sync.Mutex
+	handler  atomic.Pointer[http.Handler]
+	certs    []certSource
+
+	// Address passed to Serve, to match the listener passed to the new instance on Upgrade
+	addr string
+	// Serving https on Listener, for URL
+	https bool
 }
 
+// serveAll serves on all listeners and returns the first error. If it is not due to shutdown, the server is closed so
+// that the remaining listeners are not served silently
+func (s *ServerCtx) serveAll(serveFn func(s *ServerCtx, idx int) error) error {
+	if s.ready != nil {
+		defer s.ready.remove()
+	}
+	defer close(s.stopped)
+	if len(s.Listeners) == 1 {
+		return serveFn(s, 0)
+	}
+	errs := make(chan error, len(s.Listeners))
+	for idx := range s.Listeners {
+		go func(idx int) {
+			errs <- serveFn(s, idx)
+		}(idx)
+	}
+	err := <-errs
+	if !errors.Is(err, http.ErrServerClosed) {
+		_ = s.Server.Close()
+	}
+	return err
+}
+
+// Wait waits for the server to stop and returns the error it stopped with, same as Err. Can be called from more than
+// one goroutine, along with receiving from Done
 func (s *ServerCtx) Wait() error {
-	return <-s.Done
+	<-s.finished
+	return s.err
+}
+
+// Err returns the error the server stopped with once Done is closed, nil before. Done receives the error and is then
+// closed, so that any number of goroutines can wait on it, but only the first receive gets the error
+func (s *ServerCtx) Err() error {
+	select {
+	case <-s.finished:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// RegisterOnShutdown registers f to be called once the server stopped serving, e.g. to close databases or flush queues.
+// Unlike http.Server.RegisterOnShutdown, which runs f as soon as shutdown begins, f is called after requests are drained
+// and socket and ready files are removed, for any reason the server stops, including idle_timeout and shutdown signals.
+// Functions are called in reverse order of registration, like deferred calls, before Done is closed. f is called right
+// away if the server already stopped
+func (s *ServerCtx) RegisterOnShutdown(f func()) {
+	s.onShutdownMu.Lock()
+	if !s.onShutdownRan {
+		s.onShutdown = append(s.onShutdown, f)
+		s.onShutdownMu.Unlock()
+		return
+	}
+	s.onShutdownMu.Unlock()
+	f()
+}
+
+// runOnShutdown calls the functions passed to RegisterOnShutdown
+func (s *ServerCtx) runOnShutdown() {
+	s.onShutdownMu.Lock()
+	hooks := s.onShutdown
+	s.onShutdown, s.onShutdownRan = nil, true
+	s.onShutdownMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// finish sets the error the server stopped with and closes Done
+func (s *ServerCtx) finish(err error) {
+	s.runOnShutdown()
+	s.err = err
+	s.done <- err
+	close(s.done)
+	close(s.finished)
 }
 
 func (s *ServerCtx) Addr() net.Addr {
 	return s.Listener.Addr()
 }
 
-func (s *ServerCtx) Shutdown(ctx context.Context) error {
+// Port returns the TCP port listening on, e.g. the one picked for :0. 0 for other listeners
+func (s *ServerCtx) Port() int {
+	if addr, ok := s.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// URL returns the base URL to connect to the server, e.g. http://127.0.0.1:8080 for :8080 or https://[::1]:8443.
+// Unix sockets use the http+unix scheme with the escaped path as host, e.g. http+unix://%2Frun%2Fapp.sock, which
+// clients need to dial themselves. Empty for other listeners
+func (s *ServerCtx) URL() string {
+	scheme := "http"
+	if s.https {
+		scheme = "https"
+	}
+	switch addr := s.Addr().(type) {
+	case *net.TCPAddr:
+		ip := addr.IP
+		// Dual-stack for :8080
+		if ip == nil || ip.IsUnspecified() {
+			ip = net.IPv4(127, 0, 0, 1)
+		}
+		return scheme + "://" + net.JoinHostPort(ip.String(), strconv.Itoa(addr.Port))
+	case *net.UnixAddr:
+		return scheme + "+unix://" + url.PathEscape(addr.Name)
+	}
+	return ""
+}
+
+// TCPListener returns the listener as *net.TCPListener, e.g. to get the file descriptor. ok is false for other listeners
+func (s *ServerCtx) TCPListener() (l *net.TCPListener, ok bool) {
+	l, ok = unwrapListener(s.Listener).(*net.TCPListener)
+	return l, ok
+}
+
+// UnixListener returns the listener as *net.UnixListener. ok is false for other listeners
+func (s *ServerCtx) UnixListener() (l *net.UnixListener, ok bool) {
+	l, ok = unwrapListener(s.Listener).(*net.UnixListener)
+	return l, ok
+}
+
+// SetUnlinkOnClose sets whether the socket file is removed when the listener is closed, e.g. set false to keep it for the
+// next instance. Unix socket listeners created from address remove the file by default, socket activated ones don't
+func (s *ServerCtx) SetUnlinkOnClose(unlink bool) error {
+	ul, ok := s.UnixListener()
+	if !ok {
+		return fmt.Errorf("not a unix socket listener: %T", unwrapListener(s.Listener))
+	}
+	ul.SetUnlinkOnClose(unlink)
+	return nil
+}
+
+// unwrapListener returns the listener created for the address, without the wrappers added for options
+func unwrapListener(l net.Listener) net.Listener {
+	for {
+		w, ok := l.(interface{ unwrap() net.Listener })
+		if !ok {
+			return l
+		}
+		l = w.unwrap()
+	}
+}
+
+func (s *ServerCtx) logf(format string, args ...any) {
+	s.printf(prioWarning, format, args...)
+}
+
+func (s *ServerCtx) infof(format string, args ...any) {
+	s.printf(prioInfo, format, args...)
+}
+
+func (s *ServerCtx) printf(prio string, format string, args ...any) {
+	if s.Server != nil && s.journalLog != nil && s.Server.ErrorLog == s.journalLog {
+		_ = journal.Send(fmt.Sprintf(format, args...), journalPriority[prio], nil)
+		return
+	}
+	if s.Server != nil && s.Server.ErrorLog != nil && s.Server.ErrorLog != journalOnce.errorLog {
+		s.Server.ErrorLog.Printf(format, args...)
+		return
+	}
+	logPrintf(prio, format, args...)
+}
+
+// drain gracefully shuts down the server. Remaining connections are closed after shutdown_timeout
+func (s *ServerCtx) drain() error {
+	ctx := context.Background()
+	if s.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.shutdownTimeout)
+		defer cancel()
+	}
+	s.draining.Store(true)
+	defer s.reportProgress()()
 	err := s.Server.Shutdown(ctx)
-	if err != nil {
+	if errors.Is(err, context.DeadlineExceeded) {
+		s.logf("anyhttp: shutdown_timeout %v exceeded, closing remaining connections", s.shutdownTimeout)
+		err = s.Server.Close()
+	}
+	s.conns.waitHijacked()
+	return err
+}
+
+// idleShutdown drains the server after idle_timeout, sending STOPPING=1 first unless disabled by idle_notify
+func (s *ServerCtx) idleShutdown() error {
+	status := fmt.Sprintf("No requests for %v, shutting down", *s.SysdConfig.IdleTimeout)
+	s.infof("anyhttp: %v", status)
+	if notify := s.SysdConfig.IdleNotify; notify == nil || *notify {
+		s.notifyStopping(status)
+	} else {
+		// Not sent on shutdown either
+		s.stopping.Do(func() {})
+	}
+	// Cancels the ctx of Idler.Go jobs, e.g. started by the last requests while draining
+	s.Idler.(idle.Controller).Close()
+	return s.drain()
+}
+
+// osExit is replaced in tests
+var osExit = os.Exit
+
+func (s *ServerCtx) Shutdown(ctx context.Context) error {
+	if err := s.shutdown(ctx); err != nil {
+		return err
+	}
+	return s.Wait()
+}
+
+// ForceClosedError is returned by ShutdownOrClose when connections were closed as the shutdown did not finish in time
+type ForceClosedError struct {
+	// Open connections, e.g. with a stuck request
+	Conns int
+	// Hijacked connections, e.g. websockets. Tracked only with hijack_timeout option
+	Hijacked int
+	// ctx.Err() of the shutdown
+	Err error
+}
+
+func (e *ForceClosedError) Error() string {
+	return fmt.Sprintf("shutdown not finished: %v, closed %v connections and %v hijacked connections", e.Err, e.Conns, e.Hijacked)
+}
+
+func (e *ForceClosedError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownOrClose is Shutdown, but once ctx is done, the remaining connections including hijacked ones are closed,
+// instead of a stuck client blocking shutdown. Returns *ForceClosedError then
+func (s *ServerCtx) ShutdownOrClose(ctx context.Context) error {
+	err := s.shutdown(ctx)
+	if err == nil {
+		return s.Wait()
+	}
+	if ctx.Err() == nil {
 		return err
 	}
-	return <-s.Done
+	closed := &ForceClosedError{Conns: s.conns.progress(time.Now()).Conns, Err: err}
+	_ = s.Server.Close()
+	closed.Hijacked = s.conns.closeHijacked()
+	<-s.finished
+	return closed
+}
+
+// shutdown gracefully shuts down the server without waiting for Done
+func (s *ServerCtx) shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+	stop := s.reportProgress()
+	defer stop()
+	err := s.Server.Shutdown(ctx)
+	if err == nil {
+		s.conns.waitHijacked()
+	}
+	return err
 }
 
 // ServeTLS creates and serves a HTTPS server.
-func ServeTLS(addr string, h http.Handler, certFile string, keyFile string) (*ServerCtx, error) {
-	return serve(addr, h, certFile, keyFile)
+func ServeTLS(addr string, h http.Handler, certFile string, keyFile string, opts ...Option) (*ServerCtx, error) {
+	return serve(addr, h, certFile, keyFile, opts)
+}
+
+// ServeTLSConfig creates and serves a HTTPS server with config, e.g. for client certificates, GetCertificate or
+// certificates loaded from memory. Same as Serve with WithTLSConfig
+func ServeTLSConfig(addr string, h http.Handler, config *tls.Config, opts ...Option) (*ServerCtx, error) {
+	if config == nil {
+		return nil, errors.New("ServeTLSConfig needs a tls.Config")
+	}
+	return serve(addr, h, "", "", append(opts[:len(opts):len(opts)], WithTLSConfig(config)))
 }
 
 // Serve creates and serves a HTTP server.
-func Serve(addr string, h http.Handler) (*ServerCtx, error) {
-	return serve(addr, h, "", "")
+func Serve(addr string, h http.Handler, opts ...Option) (*ServerCtx, error) {
+	return serve(addr, h, "", "", opts)
+}
+
+// ServeContext is Serve, but the server is shut down gracefully when ctx is canceled, e.g. by signal.NotifyContext on
+// SIGTERM. Done then reports nil once drained, or the error draining
+func ServeContext(ctx context.Context, addr string, h http.Handler, opts ...Option) (*ServerCtx, error) {
+	s, err := Serve(addr, h, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.shutdownOnCancel(ctx)
+	return s, nil
+}
+
+// shutdownOnCancel drains the server when ctx is canceled. Done reports after the drain, Serve returns as soon as it
+// begins
+func (s *ServerCtx) shutdownOnCancel(ctx context.Context) {
+	go func() {
+		select {
+		case <-s.stopped:
+		case <-ctx.Done():
+			s.requestDrain()
+		}
+	}()
+}
+
+// requestDrain drains the server, reporting the drain error to Done instead of the one from Serve
+func (s *ServerCtx) requestDrain() {
+	s.drainOnce.Do(func() { close(s.drainReq) })
 }
 
 // ListenAndServe is the drop-in replacement for `http.ListenAndServe`.
@@ -277,6 +818,15 @@ func ListenAndServe(addr string, h http.Handler) error {
 	return ctx.Wait()
 }
 
+// ListenAndServeContext is ListenAndServe, but returns nil once the server is drained after ctx is canceled
+func ListenAndServeContext(ctx context.Context, addr string, h http.Handler) error {
+	s, err := ServeContext(ctx, addr, h)
+	if err != nil {
+		return err
+	}
+	return s.Wait()
+}
+
 func ListenAndServeTLS(addr string, certFile string, keyFile string, h http.Handler) error {
 	ctx, err := ServeTLS(addr, h, certFile, keyFile)
 	if err != nil {
@@ -292,149 +842,960 @@ func UnsetSystemdListenVars() {
 	_ = os.Unsetenv("LISTEN_FDNAMES")
 }
 
+// address is the parsed form of the address string
+type address struct {
+	addrType AddressType
+	usc      *UnixSocketConfig
+	sysc     *SysdConfig
+	vsc      *VsockConfig
+	s6c      *S6Config
+	udpc     *UDPConfig
+	tcpAddr  string
+	tcpOpts  tcpOptions
+	sockOpts socketOptions
+	srvOpts  serverOptions
+	// raw values of the options supported by all address types
+	common map[string]string
+	// Set by tls option and nginx style addresses with ssl parameter
+	requireTLS bool
+	// Retry binding upto this duration while the address is in use. 0 disables
+	bindRetry time.Duration
+	// Path of the unix socket to take over the listener from a running instance, and to hand it off to the next one
+	handoff string
+	// Lock file path, or "true" to derive it from the address. Guards against another instance using the same address
+	lock string
+	// Name of the listener in the systemd fd store. Taken from there after a restart instead of binding again
+	fdStore string
+	// Set for schemes added by RegisterScheme
+	schemeListen ListenFunc
+	schemeQuery  url.Values
+}
+
+// serverOptions are options for the http server, supported on all address types. Not used by GetListener
+type serverOptions struct {
+	// Close oldest idle keep-alive connections when open connections reach this limit. 0 disables
+	maxConns int
+	// Time to wait for active connections to finish when shutting down, before closing them. 0 waits forever
+	shutdownTimeout time.Duration
+	// When set, requests received during shutdown are answered with 503 and this Retry-After
+	drainRetryAfter time.Duration
+	// Interval to report progress while shutdown is in progress. 0 disables
+	progressInterval time.Duration
+	// Close connections older than this once the request in progress finishes. 0 disables
+	maxConnAge time.Duration
+	// Time to wait for hijacked connections to be closed when shutting down, before closing them. 0 does not track them
+	hijackTimeout time.Duration
+	// With TLS, redirect plain http requests to https instead of the default 400 response
+	redirectHTTP bool
+	// Serve https with certificates in this directory selected by SNI
+	certDir string
+	// Interval to rescan certDir
+	certRescan time.Duration
+	// Respond with 503 when requests in progress exceed maxRequests or active idler jobs exceed maxJobs. 0 disables
+	maxRequests int
+	maxJobs     int
+	// File created once ready to handle requests and removed when shutting down
+	readyFile string
+	// Serve https with this certificate and key, same as ServeTLS. keyFile defaults to certFile, for a single PEM file with both
+	certFile string
+	keyFile  string
+	// Names of systemd credentials with the certificate and key, in $CREDENTIALS_DIRECTORY. Used instead of certFile and keyFile
+	certCred string
+	keyCred  string
+	// Verify client certificates with the CAs in this PEM file, for mutual TLS
+	clientCA string
+	// Client certificate policy, one of clientAuthTypes. Defaults to require_and_verify with clientCA
+	clientAuth string
+	// Expect PROXY protocol v1 or v2 header on all connections, sent by load balancers like HAProxy or AWS NLB
+	proxyProtocol bool
+	// Do not notify systemd even if NOTIFY_SOCKET is set, e.g. when another part of the app sends READY=1
+	noSdNotify bool
+	// Write logs to the journal socket when run by systemd
+	journal bool
+	// Serve HTTP/2 without TLS, e.g. for gRPC behind a proxy
+	h2c bool
+	// HTTP/2 settings, 0 keeps the net/http default
+	h2MaxStreams   int
+	h2MaxFrameSize int
+	h2PingTimeout  time.Duration
+}
+
+// tlsFiles returns the certificate and key files passed to ServeTLS, or set by cert and key options
+func (o *serverOptions) tlsFiles(certFile, keyFile string) (string, string, error) {
+	optCert, optKey, err := o.credFiles()
+	if err != nil {
+		return "", "", err
+	}
+	if optCert == "" {
+		if optKey != "" {
+			return "", "", errors.New("Bad key: cert is not set")
+		}
+		return certFile, keyFile, nil
+	}
+	if certFile != "" {
+		return "", "", errors.New("Bad cert: certificate is also passed to ServeTLS")
+	}
+	if optKey == "" {
+		return optCert, optCert, nil
+	}
+	return optCert, optKey, nil
+}
+
+// credFiles returns the cert and key options, with cert_cred and key_cred resolved to files in $CREDENTIALS_DIRECTORY
+func (o *serverOptions) credFiles() (string, string, error) {
+	if o.certCred != "" && o.certFile != "" {
+		return "", "", errors.New("Bad cert_cred: cert is also set")
+	}
+	if o.keyCred != "" && o.keyFile != "" {
+		return "", "", errors.New("Bad key_cred: key is also set")
+	}
+	certFile, keyFile := o.certFile, o.keyFile
+	if o.certCred == "" && o.keyCred == "" {
+		return certFile, keyFile, nil
+	}
+	// Set by systemd for units with LoadCredential= or SetCredential=
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", "", errors.New("Bad cert_cred or key_cred: $CREDENTIALS_DIRECTORY is not set, use LoadCredential= in the unit")
+	}
+	if o.certCred != "" {
+		certFile = filepath.Join(dir, o.certCred)
+	}
+	if o.keyCred != "" {
+		keyFile = filepath.Join(dir, o.keyCred)
+	}
+	return certFile, keyFile, nil
+}
+
+// configureClientAuth sets client_ca and client_auth on config of the https server
+func (o *serverOptions) configureClientAuth(config *tls.Config) error {
+	key := "client_ca"
+	if o.clientCA == "" {
+		key = "client_auth"
+	}
+	if config == nil {
+		return fmt.Errorf("Bad %v: https is not enabled, set cert or cert_dir", key)
+	}
+	authType := tls.RequireAndVerifyClientCert
+	if o.clientAuth != "" {
+		authType = clientAuthTypes[o.clientAuth]
+	}
+	if o.clientCA != "" {
+		pool, err := loadClientCAs(o.clientCA)
+		if err != nil {
+			return err
+		}
+		config.ClientCAs = pool
+	}
+	// Verifying without ClientCAs would use the system roots, which is never intended
+	verify := authType == tls.VerifyClientCertIfGiven || authType == tls.RequireAndVerifyClientCert
+	if verify && config.ClientCAs == nil {
+		return fmt.Errorf("Bad client_auth: %v needs client_ca", o.clientAuth)
+	}
+	config.ClientAuth = authType
+	return nil
+}
+
+func (o *serverOptions) parse(key, val string) (bool, error) {
+	switch key {
+	case "max_conns":
+		if val == "auto" {
+			limit, err := fdLimit()
+			if err != nil {
+				return true, fmt.Errorf("Bad max_conns: %v, err: %w", val, err)
+			}
+			// Leave headroom for files and outgoing connections used by the app
+			o.maxConns = int(limit) * 9 / 10
+			return true, nil
+		}
+		maxConns, err := strconv.Atoi(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad max_conns: %v, err: %w", val, err)
+		}
+		if maxConns < 0 {
+			return true, fmt.Errorf("Bad max_conns: %v, must not be negative", val)
+		}
+		o.maxConns = maxConns
+	case "shutdown_timeout":
+		timeout, err := time.ParseDuration(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad shutdown_timeout: %v, err: %w", val, err)
+		}
+		o.shutdownTimeout = timeout
+	case "drain_retry_after":
+		retryAfter, err := time.ParseDuration(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad drain_retry_after: %v, err: %w", val, err)
+		}
+		o.drainRetryAfter = retryAfter
+	case "drain_progress":
+		interval, err := time.ParseDuration(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad drain_progress: %v, err: %w", val, err)
+		}
+		o.progressInterval = interval
+	case "max_conn_age":
+		age, err := time.ParseDuration(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad max_conn_age: %v, err: %w", val, err)
+		}
+		o.maxConnAge = age
+	case "hijack_timeout":
+		timeout, err := time.ParseDuration(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad hijack_timeout: %v, err: %w", val, err)
+		}
+		o.hijackTimeout = timeout
+	case "redirect_http":
+		redirect, err := strconv.ParseBool(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad redirect_http: %v, err: %w", val, err)
+		}
+		o.redirectHTTP = redirect
+	case "cert_dir":
+		if val == "" {
+			return true, errors.New("Bad cert_dir: empty path")
+		}
+		o.certDir = val
+	case "cert_rescan":
+		interval, err := time.ParseDuration(val)
+		if err != nil || interval <= 0 {
+			return true, fmt.Errorf("Bad cert_rescan: %v, must be a positive duration", val)
+		}
+		o.certRescan = interval
+	case "cert", "key":
+		if val == "" {
+			return true, fmt.Errorf("Bad %v: empty path", key)
+		}
+		if key == "cert" {
+			o.certFile = val
+		} else {
+			o.keyFile = val
+		}
+	case "cert_cred", "key_cred":
+		// Credential names are file names in $CREDENTIALS_DIRECTORY
+		if val == "" || strings.Contains(val, "/") || val == "." || val == ".." {
+			return true, fmt.Errorf("Bad %v: %q, must be a credential name", key, val)
+		}
+		if key == "cert_cred" {
+			o.certCred = val
+		} else {
+			o.keyCred = val
+		}
+	case "client_ca":
+		if val == "" {
+			return true, errors.New("Bad client_ca: empty path")
+		}
+		o.clientCA = val
+	case "client_auth":
+		if _, ok := clientAuthTypes[val]; !ok {
+			return true, fmt.Errorf("Bad client_auth: %q, must be none, request, require, verify_if_given or require_and_verify", val)
+		}
+		o.clientAuth = val
+	case "ready_file":
+		if val == "" {
+			return true, errors.New("Bad ready_file: empty path")
+		}
+		o.readyFile = val
+	case "proxy_protocol":
+		enabled, err := strconv.ParseBool(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad proxy_protocol: %v, err: %w", val, err)
+		}
+		o.proxyProtocol = enabled
+	case "journal":
+		enabled, err := strconv.ParseBool(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad journal: %v, err: %w", val, err)
+		}
+		o.journal = enabled
+	case "h2c":
+		enabled, err := strconv.ParseBool(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad h2c: %v, err: %w", val, err)
+		}
+		o.h2c = enabled
+	case "h2_max_streams":
+		streams, err := strconv.ParseUint(val, 10, 32)
+		if err != nil || streams == 0 {
+			return true, fmt.Errorf("Bad h2_max_streams: %v, must be a positive number", val)
+		}
+		o.h2MaxStreams = int(streams)
+	case "h2_max_frame_size":
+		size, err := strconv.Atoi(val)
+		// Limits from RFC 9113 section 6.5.2
+		if err != nil || size < 1<<14 || size > 1<<24-1 {
+			return true, fmt.Errorf("Bad h2_max_frame_size: %v, must be between 16384 and 16777215", val)
+		}
+		o.h2MaxFrameSize = size
+	case "h2_ping_timeout":
+		timeout, err := time.ParseDuration(val)
+		if err != nil || timeout <= 0 {
+			return true, fmt.Errorf("Bad h2_ping_timeout: %v, must be a positive duration", val)
+		}
+		o.h2PingTimeout = timeout
+	case "sd_notify":
+		enabled, err := strconv.ParseBool(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad sd_notify: %v, err: %w", val, err)
+		}
+		o.noSdNotify = !enabled
+	case "max_requests", "max_jobs":
+		limit, err := strconv.Atoi(val)
+		if err != nil || limit < 0 {
+			return true, fmt.Errorf("Bad %v: %v, must be a non-negative integer", key, val)
+		}
+		if key == "max_requests" {
+			o.maxRequests = limit
+		} else {
+			o.maxJobs = limit
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
 func parseAddress(addr string) (addrType AddressType, usc *UnixSocketConfig, sysc *SysdConfig, err error) {
-	usc = nil
-	sysc = nil
-	err = nil
-	u, err := url.Parse(addr)
+	a, err := parseAddr(addr)
 	if err != nil {
-		return TCP, nil, nil, nil
+		return Unknown, nil, nil, err
 	}
-	if u.Path == "unix" {
+	return a.addrType, a.usc, a.sysc, nil
+}
+
+func parseAddr(addr string) (a *address, err error) {
+	defer func() {
+		// Jobs are counted by the idler, which is created only for idle_timeout
+		if err == nil && a.srvOpts.maxJobs > 0 && (a.sysc == nil || a.sysc.IdleTimeout == nil) {
+			a, err = nil, fmt.Errorf("Bad max_jobs: %v, needs a systemd socket with idle_timeout; addr: %v", a.srvOpts.maxJobs, addr)
+		}
+	}()
+	if isNginxListen(addr) {
+		return parseNginxListen(addr)
+	}
+	if isSystemdListen(addr) {
+		return parseSystemdListen(addr)
+	}
+	a = &address{}
+	base, rawQuery, _ := strings.Cut(addr, "?")
+	query, qerr := url.ParseQuery(rawQuery)
+	if base == "unix" {
+		if qerr != nil {
+			return nil, fmt.Errorf("unix socket address error. Bad query: %q, err: %w", rawQuery, qerr)
+		}
 		duc := DefaultUnixSocketConfig
-		usc = &duc
-		addrType = UnixSocket
-		for key, val := range u.Query() {
+		a.usc = &duc
+		a.addrType = UnixSocket
+		usc := a.usc
+		var abstract string
+		for key, val := range query {
 			if len(val) != 1 {
-				err = fmt.Errorf("unix socket address error. Multiple %v found: %v", key, val)
-				return
+				return nil, fmt.Errorf("unix socket address error. Multiple %v found: %v", key, val)
 			}
 			if key == "path" {
 				usc.SocketPath = val[0]
+			} else if key == "abstract" {
+				if val[0] == "" {
+					return nil, errors.New("unix socket address error. Bad abstract: empty name")
+				}
+				abstract = val[0]
 			} else if key == "mode" {
 				if _, serr := fmt.Sscanf(val[0], "%o", &usc.SocketMode); serr != nil {
-					err = fmt.Errorf("unix socket address error. Bad mode: %v, err: %w", val, serr)
-					return
+					return nil, fmt.Errorf("unix socket address error. Bad mode: %v, err: %w", val, serr)
 				}
 			} else if key == "remove_existing" {
 				if removeExisting, berr := strconv.ParseBool(val[0]); berr == nil {
 					usc.RemoveExisting = removeExisting
 				} else {
-					err = fmt.Errorf("unix socket address error. Bad remove_existing: %v, err: %w", val, berr)
-					return
+					return nil, fmt.Errorf("unix socket address error. Bad remove_existing: %v, err: %w", val, berr)
 				}
-			} else {
-				err = fmt.Errorf("unix socket address error. Bad option; key: %v, val: %v", key, val)
-				return
+			} else if key == "type" {
+				switch val[0] {
+				case "stream", "seqpacket", "dgram":
+					usc.SocketType = val[0]
+				default:
+					return nil, fmt.Errorf("unix socket address error. Bad type: %v, must be stream, seqpacket or dgram", val)
+				}
+			} else if key == "watch" {
+				if interval, terr := time.ParseDuration(val[0]); terr == nil && interval >= 0 {
+					usc.WatchInterval = interval
+				} else {
+					return nil, fmt.Errorf("unix socket address error. Bad watch: %v, err: %v", val, terr)
+				}
+			} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+				return nil, fmt.Errorf("unix socket address error. %w", cerr)
+			} else if !ok {
+				return nil, fmt.Errorf("unix socket address error. Bad option; key: %v, val: %v", key, val)
+			}
+		}
+		if abstract != "" {
+			if usc.SocketPath != "" {
+				return nil, fmt.Errorf("unix socket address error. Only one of path and abstract can be set; addr: %v", addr)
+			}
+			usc.SocketPath = "@" + abstract
+			if !usc.isAbstract() {
+				return nil, fmt.Errorf("unix socket address error. abstract sockets are linux only; addr: %v", addr)
 			}
 		}
 		if usc.SocketPath == "" {
-			err = fmt.Errorf("unix socket address error. Missing path; addr: %v", addr)
-			return
+			return nil, fmt.Errorf("unix socket address error. Missing path; addr: %v", addr)
 		}
-	} else if u.Path == "sysd" {
-		dsc := DefaultSysdConfig
-		sysc = &dsc
-		addrType = SystemdFD
-		for key, val := range u.Query() {
-			if len(val) != 1 {
-				err = fmt.Errorf("systemd socket fd address error. Multiple %v found: %v", key, val)
-				return
+	} else if base == "sysd" {
+		if qerr != nil {
+			return nil, fmt.Errorf("systemd socket fd address error. Bad query: %q, err: %w", rawQuery, qerr)
+		}
+		if err := a.parseSysd(query); err != nil {
+			return nil, err
+		}
+		sysc := a.sysc
+		if sysc.All {
+			if sysc.FDIndex != nil || sysc.FDName != nil || sysc.Accepted {
+				return nil, fmt.Errorf("systemd socket fd address error. name, idx and accepted can not be set with all. name: %v, idx: %v", sysc.FDName, sysc.FDIndex)
 			}
-			if key == "name" {
-				sysc.FDName = &val[0]
-			} else if key == "idx" {
-				if idx, ierr := strconv.Atoi(val[0]); ierr == nil {
-					sysc.FDIndex = &idx
-				} else {
-					err = fmt.Errorf("systemd socket fd address error. Bad idx: %v, err: %w", val, ierr)
-					return
-				}
-			} else if key == "check_pid" {
-				if checkPID, berr := strconv.ParseBool(val[0]); berr == nil {
-					sysc.CheckPID = checkPID
-				} else {
-					err = fmt.Errorf("systemd socket fd address error. Bad check_pid: %v, err: %w", val, berr)
-					return
-				}
-			} else if key == "unset_env" {
-				if unsetEnv, berr := strconv.ParseBool(val[0]); berr == nil {
-					sysc.UnsetEnv = unsetEnv
-				} else {
-					err = fmt.Errorf("systemd socket fd address error. Bad unset_env: %v, err: %w", val, berr)
-					return
-				}
-			} else if key == "idle_timeout" {
-				if timeout, terr := time.ParseDuration(val[0]); terr == nil {
-					sysc.IdleTimeout = &timeout
-				} else {
-					err = fmt.Errorf("systemd socket fd address error. Bad idle_timeout: %v, err: %w", val, terr)
-					return
+		} else if sysc.FDIndex != nil && sysc.FDName != nil || sysc.FDIndex == nil && sysc.FDName == nil && !sysc.Accepted {
+			return nil, fmt.Errorf("systemd socket fd address error. Exactly only one of name and idx has to be set. name: %v, idx: %v", sysc.FDName, sysc.FDIndex)
+		}
+	} else if base == "s6" {
+		if qerr != nil {
+			return nil, fmt.Errorf("s6 address error. Bad query: %q, err: %w", rawQuery, qerr)
+		}
+		return a.parseS6(query)
+	} else if base == "tcp4" || base == "tcp6" {
+		if qerr != nil {
+			return nil, fmt.Errorf("%v address error. Bad query: %q, err: %w", base, rawQuery, qerr)
+		}
+		return a.parseTCPFamily(base, query)
+	} else if base == "udp" {
+		if qerr != nil {
+			return nil, fmt.Errorf("udp address error. Bad query: %q, err: %w", rawQuery, qerr)
+		}
+		return a.parseUDP(query)
+	} else if base == "vsock" {
+		if qerr != nil {
+			return nil, fmt.Errorf("vsock address error. Bad query: %q, err: %w", rawQuery, qerr)
+		}
+		return a.parseVsock(addr, query)
+	} else if base == "alias" {
+		if qerr != nil {
+			return nil, fmt.Errorf("alias address error. Bad query: %q, err: %w", rawQuery, qerr)
+		}
+		return parseAlias(addr, query)
+	} else if base == "port_env" {
+		if qerr != nil {
+			return nil, fmt.Errorf("port env address error. Bad query: %q, err: %w", rawQuery, qerr)
+		}
+		return parsePortEnv(addr, query)
+	} else if listen, ok := lookupScheme(base); ok {
+		if qerr != nil {
+			return nil, fmt.Errorf("%v address error. Bad query: %q, err: %w", base, rawQuery, qerr)
+		}
+		a.schemeListen = listen
+		return a.parseScheme(base, query)
+	} else {
+		// Just assume as TCP address
+		a.addrType = TCP
+		a.tcpAddr = addr
+		if Strict {
+			if qerr != nil {
+				return nil, fmt.Errorf("tcp address error. Bad query: %q, err: %w", rawQuery, qerr)
+			}
+			if base != "" {
+				if _, _, serr := net.SplitHostPort(base); serr != nil {
+					return nil, fmt.Errorf("unrecognized address: %q, not a known scheme or a valid tcp address, err: %w", addr, serr)
 				}
+			}
+		}
+		if rawQuery == "" || qerr != nil {
+			return a, nil
+		}
+		a.tcpAddr = base
+		for key, val := range query {
+			if len(val) != 1 {
+				return nil, fmt.Errorf("tcp address error. Multiple %v found: %v", key, val)
+			}
+			if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+				return nil, fmt.Errorf("tcp address error. %w", cerr)
+			} else if !ok {
+				return nil, fmt.Errorf("tcp address error. Bad option; key: %v, val: %v", key, val)
+			}
+		}
+	}
+	return a, nil
+}
+
+// parseSysd parses the options of sysd addresses. Does not check name or idx is set, see ServeNamed
+func (a *address) parseSysd(query url.Values) error {
+	dsc := DefaultSysdConfig
+	a.sysc = &dsc
+	a.addrType = SystemdFD
+	sysc := a.sysc
+	for key, val := range query {
+		if len(val) != 1 {
+			return fmt.Errorf("systemd socket fd address error. Multiple %v found: %v", key, val)
+		}
+		if key == "name" {
+			sysc.FDName = &val[0]
+		} else if key == "idx" {
+			if idx, ierr := strconv.Atoi(val[0]); ierr == nil {
+				sysc.FDIndex = &idx
+			} else {
+				return fmt.Errorf("systemd socket fd address error. Bad idx: %v, err: %w", val, ierr)
+			}
+		} else if key == "check_pid" {
+			if checkPID, berr := strconv.ParseBool(val[0]); berr == nil {
+				sysc.CheckPID = checkPID
 			} else {
-				err = fmt.Errorf("systemd socket fd address error. Bad option; key: %v, val: %v", key, val)
-				return
+				return fmt.Errorf("systemd socket fd address error. Bad check_pid: %v, err: %w", val, berr)
 			}
+		} else if key == "unset_env" {
+			if unsetEnv, berr := strconv.ParseBool(val[0]); berr == nil {
+				sysc.UnsetEnv = unsetEnv
+			} else {
+				return fmt.Errorf("systemd socket fd address error. Bad unset_env: %v, err: %w", val, berr)
+			}
+		} else if key == "all" {
+			if all, berr := strconv.ParseBool(val[0]); berr == nil {
+				sysc.All = all
+			} else {
+				return fmt.Errorf("systemd socket fd address error. Bad all: %v, err: %w", val, berr)
+			}
+		} else if key == "accepted" {
+			if accepted, berr := strconv.ParseBool(val[0]); berr == nil {
+				sysc.Accepted = accepted
+			} else {
+				return fmt.Errorf("systemd socket fd address error. Bad accepted: %v, err: %w", val, berr)
+			}
+		} else if key == "network" {
+			if !contains(sysdNetworks, val[0]) {
+				return fmt.Errorf("systemd socket fd address error. Bad network: %v, expected one of %v", val, sysdNetworks)
+			}
+			sysc.Network = val[0]
+		} else if key == "idle_timeout" {
+			if timeout, terr := time.ParseDuration(val[0]); terr == nil {
+				sysc.IdleTimeout = &timeout
+			} else {
+				return fmt.Errorf("systemd socket fd address error. Bad idle_timeout: %v, err: %w", val, terr)
+			}
+		} else if key == "idle_exit" {
+			if code, ierr := strconv.Atoi(val[0]); ierr == nil && code >= 0 && code <= 255 {
+				sysc.IdleExitCode = &code
+			} else {
+				return fmt.Errorf("systemd socket fd address error. Bad idle_exit: %v, must be an exit code between 0 and 255", val)
+			}
+		} else if key == "idle_notify" {
+			if notify, berr := strconv.ParseBool(val[0]); berr == nil {
+				sysc.IdleNotify = &notify
+			} else {
+				return fmt.Errorf("systemd socket fd address error. Bad idle_notify: %v, err: %w", val, berr)
+			}
+		} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return fmt.Errorf("systemd socket fd address error. %w", cerr)
+		} else if !ok {
+			return fmt.Errorf("systemd socket fd address error. Bad option; key: %v, val: %v", key, val)
 		}
-		if (sysc.FDIndex == nil) == (sysc.FDName == nil) {
-			err = fmt.Errorf("systemd socket fd address error. Exactly only one of name and idx has to be set. name: %v, idx: %v", sysc.FDName, sysc.FDIndex)
-			return
+	}
+	return nil
+}
+
+// parsePortEnv parses port_env?env=PORT&fallback=<address>, for platforms like Cloud Run and Heroku that pass the port to listen in $PORT
+func parsePortEnv(addr string, query url.Values) (*address, error) {
+	a := &address{addrType: TCP}
+	envName, fallback := "PORT", ""
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("port env address error. Multiple %v found: %v", key, val)
+		}
+		if key == "env" {
+			envName = val[0]
+		} else if key == "fallback" {
+			fallback = val[0]
+		} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return nil, fmt.Errorf("port env address error. %w", cerr)
+		} else if !ok {
+			return nil, fmt.Errorf("port env address error. Bad option; key: %v, val: %v", key, val)
 		}
-	} else {
-		// Just assume as TCP address
-		return TCP, nil, nil, nil
 	}
-	return
+	if port := os.Getenv(envName); port != "" {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("port env address error. Bad $%v: %q, err: %w", envName, port, err)
+		}
+		a.tcpAddr = ":" + port
+		return a, nil
+	}
+	if fallback == "" {
+		return nil, fmt.Errorf("port env address error. $%v not set and no fallback; addr: %v", envName, addr)
+	}
+	if base, _, _ := strings.Cut(fallback, "?"); base == "port_env" {
+		return nil, fmt.Errorf("port env address error. fallback can not be port_env; addr: %v", addr)
+	}
+	fa, err := parseAddr(fallback)
+	if err != nil {
+		return nil, fmt.Errorf("port env address error. Bad fallback: %w", err)
+	}
+	if err := fa.mergeCommon(a); err != nil {
+		return nil, err
+	}
+	return fa, nil
 }
 
-func serve(addr string, h http.Handler, certFile string, keyFile string) (*ServerCtx, error) {
+// mergeCommon applies the options supported by all address types from other, e.g. for fallback or alias addresses
+func (a *address) mergeCommon(other *address) error {
+	for key, val := range other.common {
+		if _, err := a.parseCommon(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	serveFn := func() func(ctx *ServerCtx) error {
-		if certFile != "" {
-			return func(ctx *ServerCtx) error {
-				return ctx.Server.ServeTLS(ctx.Listener, certFile, keyFile)
+// parseCommon parses the options supported by all address types. Returns false if key is not known
+func (a *address) parseCommon(key, val string) (ok bool, err error) {
+	defer func() {
+		if ok && err == nil {
+			if a.common == nil {
+				a.common = map[string]string{}
 			}
-		}
-		return func(ctx *ServerCtx) error {
-			return ctx.Server.Serve(ctx.Listener)
+			a.common[key] = val
 		}
 	}()
-	var ctx ServerCtx
-	var err error
-	var cfg any
+	switch key {
+	case "bind_retry":
+		if a.bindRetry, err = time.ParseDuration(val); err != nil {
+			return true, fmt.Errorf("Bad bind_retry: %v, err: %w", val, err)
+		}
+		return true, nil
+	case "handoff":
+		if val == "" {
+			return true, errors.New("Bad handoff: empty path")
+		}
+		a.handoff = val
+		return true, nil
+	case "tls":
+		if a.requireTLS, err = strconv.ParseBool(val); err != nil {
+			return true, fmt.Errorf("Bad tls: %v, err: %w", val, err)
+		}
+		return true, nil
+	case "fd_store":
+		if !validFDName(val) {
+			return true, fmt.Errorf("Bad fd_store: %q, must be upto 255 printable characters without colons", val)
+		}
+		a.fdStore = val
+		return true, nil
+	case "lock":
+		if val == "" {
+			return true, errors.New("Bad lock: empty path")
+		}
+		a.lock = val
+		if val == "false" {
+			a.lock = ""
+		}
+		return true, nil
+	}
+	if a.addrType == TCP {
+		if ok, err := a.tcpOpts.parse(key, val); ok {
+			return ok, err
+		}
+	}
+	if ok, err := a.sockOpts.parse(key, val); ok {
+		return ok, err
+	}
+	return a.srvOpts.parse(key, val)
+}
+
+func serve(addr string, h http.Handler, certFile string, keyFile string, opts []Option) (*ServerCtx, error) {
 
-	ctx.Listener, ctx.AddressType, cfg, err = GetListener(addr)
+	cfg := applyOptions(opts)
+	a, err := parseAddr(addr)
 	if err != nil {
 		return nil, err
 	}
-	switch ctx.AddressType {
-	case UnixSocket:
-		ctx.UnixSocketConfig = cfg.(*UnixSocketConfig)
-	case SystemdFD:
-		ctx.SysdConfig = cfg.(*SysdConfig)
-	}
-	errChan := make(chan error)
-	ctx.Done = errChan
-	if ctx.AddressType == SystemdFD && ctx.SysdConfig.IdleTimeout != nil {
-		ctx.Idler = idle.CreateIdler(*ctx.SysdConfig.IdleTimeout)
-		ctx.Server = &http.Server{Handler: idle.WrapIdlerHandler(ctx.Idler, h)}
-		waitErrChan := make(chan error)
+	if certFile, keyFile, err = a.srvOpts.tlsFiles(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	if _, ok := h.(Hosts); a.requireTLS && certFile == "" && a.srvOpts.certDir == "" && cfg.tlsConfig == nil && !ok {
+		return nil, fmt.Errorf("address requires TLS, use ServeTLS or set cert: %q", addr)
+	}
+	if a.sysc != nil && a.sysc.All {
+		if a.handoff != "" || a.lock != "" {
+			return nil, fmt.Errorf("systemd socket fd address error. handoff and lock are not supported with all: %q", addr)
+		}
+		listeners, names, err := a.sysc.allListeners()
+		if err != nil {
+			return nil, err
+		}
+		cfg.fdNames = names
+		return serveListener(a, listeners, h, certFile, keyFile, cfg)
+	}
+	listener, err := inheritedListener(addr)
+	if err != nil {
+		return nil, err
+	}
+	if listener != nil {
+		ctx, err := serveListener(a, []net.Listener{listener}, h, certFile, keyFile, cfg)
+		if err != nil {
+			return nil, err
+		}
+		ctx.addr = addr
+		ctx.upgraded()
+		return ctx, nil
+	}
+	if listener, _, err = a.listen(); err != nil {
+		return nil, err
+	}
+	ctx, err := serveListener(a, []net.Listener{listener}, h, certFile, keyFile, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addr = addr
+	return ctx, nil
+}
+
+// serveListener serves on the listeners created for the address. More than one for ServeNamed
+func serveListener(a *address, listeners []net.Listener, h http.Handler, certFile string, keyFile string, cfg serveConfig) (*ServerCtx, error) {
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+	// Decided before serving, Serve could set TLSConfig for http2
+	var useTLS bool
+	serveFn := func(ctx *ServerCtx, idx int) error {
+		l := ctx.Listeners[idx]
+		if a.srvOpts.proxyProtocol {
+			l = &proxyListener{Listener: l}
+		}
+		l = ctx.conns.wrap(l)
+		if cfg.fdNames != nil {
+			l = &namedListener{Listener: l, name: cfg.fdNames[idx]}
+		}
+		if useTLS && (cfg.fdTLS == nil || cfg.fdTLS[idx]) {
+			if a.srvOpts.redirectHTTP {
+				l = newRedirectListener(l)
+			}
+			// The cert files are in TLSConfig.GetCertificate
+			return ctx.Server.ServeTLS(newResilientListener(l, ctx.logf), "", "")
+		}
+		return ctx.Server.Serve(newResilientListener(l, ctx.logf))
+	}
+	var ctx ServerCtx
+
+	for _, l := range listeners {
+		ctx.Listeners = append(ctx.Listeners, a.sockOpts.wrap(l))
+	}
+	ctx.Listener = ctx.Listeners[0]
+	ctx.AddressType = a.addrType
+	ctx.UnixSocketConfig = a.usc
+	ctx.SysdConfig = a.sysc
+	ctx.VsockConfig = a.vsc
+	if certFile != "" {
+		ctx.TLSConfig = &TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: a.srvOpts.clientCA, ClientAuth: a.srvOpts.clientAuth}
+	}
+	ctx.conns = newConnTracker(a.srvOpts.maxConns, ctx.logf)
+	ctx.conns.maxAge = a.srvOpts.maxConnAge
+	ctx.conns.hijackTimeout = a.srvOpts.hijackTimeout
+	ctx.shutdownTimeout = a.srvOpts.shutdownTimeout
+	ctx.drainRetryAfter = a.srvOpts.drainRetryAfter
+	ctx.progressInterval = a.srvOpts.progressInterval
+
+	ctx.stopped = make(chan struct{})
+	ctx.finished = make(chan struct{})
+	ctx.drainReq = make(chan struct{})
+	ctx.done = make(chan error, 1)
+	ctx.Done = ctx.done
+	served := make(chan error, 1)
+	isIdle := ctx.AddressType == SystemdFD && ctx.SysdConfig.IdleTimeout != nil
+	var idler idle.Controller
+	if isIdle {
+		// A connection that arrived just now would be reset on shutdown
+		idler = idle.CreateIdler(*ctx.SysdConfig.IdleTimeout, idle.WithIdleCheck(func() bool {
+			if pendingConns(listeners) {
+				ctx.infof("anyhttp: connections pending, not shutting down on idle_timeout")
+				return false
+			}
+			return true
+		})).(idle.Controller)
+		ctx.Idler = idler
+	}
+	handler := ctx.wrapHandler(ctx.currentHandler(h))
+	if a.srvOpts.maxRequests > 0 || a.srvOpts.maxJobs > 0 {
+		handler = idle.WrapLoadShedHandler(idler, a.srvOpts.maxJobs, a.srvOpts.maxRequests, handler)
+	}
+	if isIdle {
+		handler = idle.WrapIdlerHandler(ctx.Idler, handler)
+	}
+	ctx.Server = &http.Server{Handler: handler, ConnState: ctx.conns.connState}
+	if isIdle {
+		ctx.Server.ConnState = idle.WrapConnState(ctx.Idler, ctx.conns.connState)
+		// Otherwise an idle keep-alive connection keeps the server running forever
+		ctx.Server.IdleTimeout = *ctx.SysdConfig.IdleTimeout
+	}
+	ctx.Server.ReadTimeout = cfg.readTimeout
+	ctx.Server.WriteTimeout = cfg.writeTimeout
+	if cfg.idleTimeout != 0 {
+		ctx.Server.IdleTimeout = cfg.idleTimeout
+	}
+	if err := a.srvOpts.configureHTTP2(ctx.Server); err != nil {
+		closeAll()
+		return nil, err
+	}
+	if cfg.logger != nil {
+		ctx.Server.ErrorLog = cfg.logger
+	} else if a.srvOpts.journal && os.Getenv("INVOCATION_ID") != "" && journal.Available() {
+		ctx.journalLog = journal.NewLogger(journal.PriWarning)
+		ctx.Server.ErrorLog = ctx.journalLog
+	} else if toJournal() {
+		ctx.Server.ErrorLog = journalOnce.errorLog
+	}
+	if cfg.fdNames != nil || a.srvOpts.proxyProtocol {
+		ctx.Server.ConnContext = connContext
+	}
+	// Also covers ctx.Server.Shutdown called directly
+	ctx.Server.RegisterOnShutdown(func() {
+		ctx.draining.Store(true)
+		ctx.notifyStopping("Shutting down")
+	})
+	var getCerts []func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	if hosts, ok := h.(Hosts); ok {
+		hostCerts, err := hosts.certificates()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		if len(hostCerts) > 0 {
+			getCerts = append(getCerts, hosts.getCertificate(hostCerts))
+		}
+	}
+	if a.srvOpts.certDir != "" {
+		certs, err := loadCertDir(a.srvOpts.certDir, ctx.logf)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		getCerts = append(getCerts, certs.getCertificate)
+		ctx.certs = append(ctx.certs, certs)
+	}
+	if cfg.tlsConfig != nil {
+		ctx.Server.TLSConfig = cfg.tlsConfig.Clone()
+		if cfg.tlsConfig.GetCertificate != nil {
+			getCerts = append(getCerts, cfg.tlsConfig.GetCertificate)
+		}
+	}
+	if certFile != "" {
+		// Instead of passing the files to ServeTLS, which loads them once
+		pair, err := loadCertPair(certFile, keyFile)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		getCerts = append(getCerts, pair.getCertificate)
+		ctx.certs = append(ctx.certs, pair)
+	}
+	if len(ctx.certs) > 0 {
+		interval := a.srvOpts.certRescan
+		if interval == 0 {
+			interval = defaultCertRescan
+		}
+		go rescanCerts(ctx.certs, interval, ctx.stopped, ctx.logf)
+	}
+	if len(getCerts) > 0 {
+		if ctx.Server.TLSConfig == nil {
+			ctx.Server.TLSConfig = &tls.Config{}
+		}
+		ctx.Server.TLSConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			for _, getCert := range getCerts {
+				if cert, err := getCert(hello); cert != nil || err != nil {
+					return cert, err
+				}
+			}
+			// Certificates in WithTLSConfig
+			return nil, nil
+		}
+	}
+	if a.srvOpts.clientCA != "" || a.srvOpts.clientAuth != "" {
+		if err := a.srvOpts.configureClientAuth(ctx.Server.TLSConfig); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+	if cfg.configure != nil {
+		cfg.configure(ctx.Server)
+	}
+	if a.handoff != "" {
+		if err := ctx.serveHandoff(a.handoff, listeners[0]); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+	if cfg.warmup != nil {
+		ctx.startWarmup(cfg.warmup)
+	}
+	if a.srvOpts.readyFile != "" {
+		if err := ctx.startReadyFile(a.srvOpts.readyFile); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+	if !a.srvOpts.noSdNotify {
+		ctx.notifySocket = os.Getenv("NOTIFY_SOCKET")
+	}
+	if ctx.notifySocket != "" && !cfg.deferNotify {
+		ctx.notifyReady()
+	}
+	// Pinged during warmup too, it could take longer than WatchdogSec
+	if interval := watchdogInterval(); ctx.notifySocket != "" && interval > 0 {
+		ctx.startWatchdog(interval, cfg.health)
+	}
+	if cfg.reload != nil || cfg.reloadSignal {
+		ctx.reload = cfg.reload
+		ctx.handleReloadSignal()
+	}
+	// TLSConfig is set for cert_dir and Hosts with certificates. For ServeNamed, only on the fds with tls
+	useTLS = certFile != "" || ctx.Server.TLSConfig != nil
+	ctx.https = useTLS && (cfg.fdTLS == nil || cfg.fdTLS[0])
+
+	if isIdle {
+		waitErrChan := make(chan error, 1)
 		go func() {
-			waitErrChan <- serveFn(&ctx)
+			waitErrChan <- ctx.serveErr(ctx.serveAll(serveFn))
 		}()
 		go func() {
 			select {
 			case err := <-waitErrChan:
-				errChan <- err
+				idler.Close()
+				served <- err
 			case <-ctx.Idler.Chan():
-				errChan <- ctx.Server.Shutdown(context.TODO())
+				err := ctx.idleShutdown()
+				if code := ctx.SysdConfig.IdleExitCode; code != nil {
+					// serveAll is done, e.g. ready_file is removed
+					<-waitErrChan
+					ctx.runOnShutdown()
+					osExit(*code)
+					return
+				}
+				served <- err
 			}
 		}()
 	} else {
-		ctx.Server = &http.Server{Handler: h}
 		go func() {
-			errChan <- serveFn(&ctx)
+			served <- ctx.serveErr(ctx.serveAll(serveFn))
 		}()
 	}
+	go func() {
+		var err error
+		select {
+		case err = <-served:
+		case <-ctx.drainReq:
+			err = ctx.drain()
+			<-served
+		}
+		ctx.finish(err)
+	}()
+	if cfg.shutdownSignals {
+		if cfg.drainTimeout > 0 {
+			ctx.shutdownTimeout = cfg.drainTimeout
+		}
+		ctx.handleShutdownSignals()
+	}
 	return &ctx, nil
 }
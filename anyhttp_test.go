@@ -2,9 +2,21 @@ package anyhttp
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"go.balki.me/anyhttp/devca"
 )
 
 func Test_parseAddress(t *testing.T) {
@@ -25,6 +37,22 @@ func Test_parseAddress(t *testing.T) {
 			wantSysc:     nil,
 			wantErr:      false,
 		},
+		{
+			name:         "tcp port with socket options",
+			addr:         ":8080?rcvbuf=65536&nodelay=false",
+			wantAddrType: TCP,
+			wantUsc:      nil,
+			wantSysc:     nil,
+			wantErr:      false,
+		},
+		{
+			name:         "tcp port with bad option",
+			addr:         ":8080?foo=bar",
+			wantAddrType: TCP,
+			wantUsc:      nil,
+			wantSysc:     nil,
+			wantErr:      true,
+		},
 		{
 			name:         "unix address",
 			addr:         "unix?path=/run/foo.sock&mode=660",
@@ -87,6 +115,24 @@ func Test_parseAddress(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:         "systemd accepted connection",
+			addr:         "sysd?accepted=true",
+			wantAddrType: SystemdFD,
+			wantUsc:      nil,
+			wantSysc: &SysdConfig{
+				CheckPID: true,
+				UnsetEnv: true,
+				Accepted: true,
+			},
+			wantErr: false,
+		},
+		{
+			name:         "systemd accepted connection with all",
+			addr:         "sysd?accepted=true&all=true",
+			wantAddrType: SystemdFD,
+			wantErr:      true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -120,6 +166,25 @@ func Test_parseAddress(t *testing.T) {
 	}
 }
 
+func TestStrict(t *testing.T) {
+	if _, err := parseAddr("unixx"); err != nil {
+		t.Errorf("parseAddr() should treat unknown address as tcp when not Strict, err: %v", err)
+	}
+
+	Strict = true
+	defer func() { Strict = false }()
+	for _, addr := range []string{"127.0.0.1:8080", ":http", "", "[::1]:8080?nodelay=true"} {
+		if _, err := parseAddr(addr); err != nil {
+			t.Errorf("parseAddr(%q) failed in Strict mode: %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"unixx", "sysdd?name=foo", ":8080?%%"} {
+		if _, err := parseAddr(addr); err == nil {
+			t.Errorf("parseAddr(%q) should fail in Strict mode", addr)
+		}
+	}
+}
+
 func TestServe(t *testing.T) {
 	ctx, err := Serve("unix?path=/tmp/foo.sock", nil)
 	if err != nil {
@@ -131,6 +196,376 @@ func TestServe(t *testing.T) {
 	ctx.Shutdown(context.TODO())
 }
 
+func TestPortURL(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if ctx.Port() == 0 || ctx.Port() != ctx.Addr().(*net.TCPAddr).Port {
+		t.Errorf("Port() = %v, want %v", ctx.Port(), ctx.Addr())
+	}
+	if _, body := get(t, ctx.URL()); body != "ok" {
+		t.Errorf("got %q from %v", body, ctx.URL())
+	}
+
+	wildcard, err := Serve(":0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wildcard.Server.Close()
+	if want := fmt.Sprintf("http://127.0.0.1:%d", wildcard.Port()); wildcard.URL() != want {
+		t.Errorf("URL() = %q, want %q", wildcard.URL(), want)
+	}
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	unix, err := Serve("unix?path="+path, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Server.Close()
+	if want := "http+unix://" + url.PathEscape(path); unix.URL() != want || unix.Port() != 0 {
+		t.Errorf("URL() = %q, Port() = %v, want %q", unix.URL(), unix.Port(), want)
+	}
+}
+
+func TestServeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s, err := ServeContext(ctx, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("ok"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := make(chan string)
+	go func() {
+		_, body := get(t, "http://"+s.Addr().String())
+		resp <- body
+	}()
+	<-started
+	cancel()
+	select {
+	case err := <-s.Done:
+		t.Fatalf("Done = %v before the request finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if body := <-resp; body != "ok" {
+		t.Errorf("got %q, want ok", body)
+	}
+	if err := s.Wait(); err != nil {
+		t.Errorf("Wait() = %v, want nil after drain", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ListenAndServeContext(canceled, "127.0.0.1:0", nil); err != nil {
+		t.Errorf("ListenAndServeContext() = %v, want nil", err)
+	}
+}
+
+func TestDoneWaiters(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Err(); err != nil {
+		t.Errorf("Err() = %v while serving", err)
+	}
+	errs := make(chan error, 5)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- ctx.Wait() }()
+		go func() { errs <- ctx.Shutdown(context.Background()) }()
+	}
+	go func() { errs <- <-ctx.Done }()
+	for i := 0; i < 5; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("got %v, want %v", err, http.ErrServerClosed)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait, Shutdown or Done is stuck")
+		}
+	}
+	if _, ok := <-ctx.Done; ok {
+		t.Error("Done is not closed")
+	}
+
+	failed, err := Serve("127.0.0.1:0", nil, WithWarmup(func(context.Context) error {
+		return errors.New("no db")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-failed.Done; err == nil {
+		t.Error("Done got nil, want warmup error")
+	}
+	if err := failed.Err(); err == nil || failed.Wait() != err {
+		t.Errorf("Err() = %v, Wait() = %v, want warmup error", err, failed.Wait())
+	}
+}
+
+func TestRegisterOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	ctx, err := Serve("unix?path="+path, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	ctx.RegisterOnShutdown(func() { calls = append(calls, "close db") })
+	ctx.RegisterOnShutdown(func() {
+		if _, err := os.Stat(path); err == nil {
+			t.Error("socket file not removed before the hook")
+		}
+		if err := ctx.Err(); err != nil {
+			t.Errorf("Err() = %v, Done closed before the hook", err)
+		}
+		calls = append(calls, "flush queue")
+	})
+	_ = ctx.Shutdown(context.Background())
+	if got := strings.Join(calls, ", "); got != "flush queue, close db" {
+		t.Errorf("calls = %q, want in reverse order of registration", got)
+	}
+	late := false
+	ctx.RegisterOnShutdown(func() { late = true })
+	if !late {
+		t.Error("func registered after shutdown not called")
+	}
+}
+
+func TestTLSOption(t *testing.T) {
+	if _, err := Serve("127.0.0.1:0?tls=true", nil); err == nil {
+		t.Error("Serve() should fail for address with tls and no certificates")
+	}
+	for addr, want := range map[string]bool{"sysd?name=https&tls=1": true, "sysd?name=http&tls=false": false} {
+		a, err := parseAddr(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.requireTLS != want {
+			t.Errorf("parseAddr(%q) requireTLS = %v, want %v", addr, a.requireTLS, want)
+		}
+	}
+	if _, err := parseAddr(":8443?tls=maybe"); err == nil {
+		t.Error("parseAddr() should fail for bad tls")
+	}
+}
+
+func TestCertOption(t *testing.T) {
+	ca, err := devca.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile, err := ca.CertFiles("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := Serve("127.0.0.1:0?tls=true&cert="+certFile+"&key="+keyFile, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if ctx.TLSConfig == nil || ctx.TLSConfig.CertFile != certFile || ctx.TLSConfig.KeyFile != keyFile {
+		t.Errorf("TLSConfig = %+v", ctx.TLSConfig)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	resp, err := client.Get("https://" + ctx.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for _, addr := range []string{":0?key=" + keyFile, ":0?cert=", ":0?cert=" + certFile + "&cert=" + certFile} {
+		if _, err := Serve(addr, nil); err == nil {
+			t.Errorf("Serve(%q) should fail", addr)
+		}
+	}
+	if _, err := ServeTLS(":0?cert="+certFile, nil, certFile, keyFile); err == nil {
+		t.Error("ServeTLS should fail when cert is also in address")
+	}
+}
+
+func TestCertCredOption(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := devca.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// devca writes localhost.pem and localhost-key.pem, like credentials loaded with LoadCredential=
+	certFile, keyFile, err := ca.CertFiles("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Serve("127.0.0.1:0?cert_cred="+filepath.Base(certFile), nil); err == nil {
+		t.Error("Serve should fail without $CREDENTIALS_DIRECTORY")
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", filepath.Dir(certFile))
+	ctx, err := Serve("127.0.0.1:0?cert_cred="+filepath.Base(certFile)+"&key_cred="+filepath.Base(keyFile), text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if ctx.TLSConfig == nil || ctx.TLSConfig.CertFile != certFile || ctx.TLSConfig.KeyFile != keyFile {
+		t.Errorf("TLSConfig = %+v", ctx.TLSConfig)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	resp, err := client.Get("https://" + ctx.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for _, addr := range []string{":0?cert_cred=", ":0?cert_cred=../cert.pem", ":0?key_cred=..", ":0?cert_cred=tls.crt&cert=" + certFile, ":0?key_cred=tls.key"} {
+		if _, err := Serve(addr, nil); err == nil {
+			t.Errorf("Serve(%q) should fail", addr)
+		}
+	}
+}
+
+func TestPortEnv(t *testing.T) {
+	t.Setenv("PORT", "9090")
+	t.Setenv("APP_PORT", "")
+	tests := []struct {
+		addr     string
+		wantType AddressType
+		wantTCP  string
+		wantErr  bool
+	}{
+		{addr: "port_env", wantType: TCP, wantTCP: ":9090"},
+		{addr: "port_env?nodelay=false&fallback=sysd?idx=0", wantType: TCP, wantTCP: ":9090"},
+		{addr: "port_env?env=APP_PORT&fallback=sysd?idx=0", wantType: SystemdFD},
+		{addr: "port_env?env=APP_PORT&fallback=127.0.0.1:8080", wantType: TCP, wantTCP: "127.0.0.1:8080"},
+		{addr: "port_env?env=APP_PORT", wantErr: true},
+		{addr: "port_env?env=APP_PORT&fallback=port_env", wantErr: true},
+	}
+	for _, tt := range tests {
+		a, err := parseAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAddr(%q) err: %v, wantErr: %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if a.addrType != tt.wantType || a.tcpAddr != tt.wantTCP {
+			t.Errorf("parseAddr(%q) = %v %q, want %v %q", tt.addr, a.addrType, a.tcpAddr, tt.wantType, tt.wantTCP)
+		}
+	}
+
+	t.Setenv("PORT", "http")
+	if _, err := parseAddr("port_env"); err == nil {
+		t.Error("parseAddr() should fail for non numeric $PORT")
+	}
+}
+
+func TestMaxJobs(t *testing.T) {
+	for addr, wantErr := range map[string]bool{
+		"sysd?idx=0&idle_timeout=1m&max_jobs=2": false,
+		"sysd?idx=0&max_jobs=0":                 false,
+		"sysd?idx=0&max_jobs=2":                 true,
+		"127.0.0.1:0?max_jobs=2":                true,
+		"127.0.0.1:0?max_requests=2":            false,
+	} {
+		if _, err := parseAddr(addr); (err != nil) != wantErr {
+			t.Errorf("parseAddr(%q) err: %v, wantErr: %v", addr, err, wantErr)
+		}
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	blocked := make(chan struct{})
+	defer close(blocked)
+	ctx, err := Serve("127.0.0.1:0?shutdown_timeout=50ms", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-blocked
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		resp, err := http.Get("http://" + ctx.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	start := time.Now()
+	if err := ctx.drain(); err != nil {
+		t.Errorf("drain() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("drain() should close connections after shutdown_timeout, took: %v", elapsed)
+	}
+	if err := <-ctx.Done; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Done = %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "dial.sock")
+	l, _, _, err := GetListener("unix?path=" + sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	c, err := DialContext(context.Background(), "unix?path="+sockPath)
+	if err != nil {
+		t.Fatalf("DialContext() failed: %v", err)
+	}
+	c.Close()
+
+	if _, err := DialContext(context.Background(), "sysd?idx=0"); err == nil {
+		t.Error("DialContext() should fail for systemd address")
+	}
+}
+
+func TestListenerAccessors(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0?rcvbuf=65536", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if _, ok := ctx.TCPListener(); !ok {
+		t.Errorf("TCPListener() should unwrap %T", ctx.Listener)
+	}
+	if _, ok := ctx.UnixListener(); ok {
+		t.Error("UnixListener() should fail for tcp")
+	}
+	if err := ctx.SetUnlinkOnClose(false); err == nil {
+		t.Error("SetUnlinkOnClose() should fail for tcp")
+	}
+
+	sockPath := filepath.Join(t.TempDir(), "app.sock")
+	uctx, err := Serve("unix?path="+sockPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uctx.SetUnlinkOnClose(false); err != nil {
+		t.Fatal(err)
+	}
+	uctx.Server.Close()
+	if _, err := os.Stat(sockPath); err != nil {
+		t.Errorf("socket file should be kept, err: %v", err)
+	}
+}
+
 // Helpers
 
 // print value instead of pointer
@@ -0,0 +1,99 @@
+// Package anyhttptest provides utilities for integration tests against real anyhttp listeners
+package anyhttptest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.balki.me/anyhttp"
+)
+
+// Server is a running anyhttp server started by NewServer
+type Server struct {
+	// Base URL of the server, e.g. http://127.0.0.1:45321. For unix sockets the host is always "unix", i.e. http://unix
+	URL string
+
+	// Client configured to connect to the server, including over unix sockets
+	Client *http.Client
+
+	// Underlying anyhttp server
+	Ctx *anyhttp.ServerCtx
+}
+
+// NewServer starts serving h on addrPattern and shuts it down at the end of the test.
+//
+// addrPattern is an anyhttp address. A few shortcuts are supported:
+//   - "" or "tcp" listens on a free port on 127.0.0.1
+//   - "unix" or "unix?mode=600" without path listens on a socket in a new temp directory
+func NewServer(t testing.TB, addrPattern string, h http.Handler) *Server {
+	t.Helper()
+
+	addr := addrPattern
+	base, rawQuery, _ := strings.Cut(addrPattern, "?")
+	switch base {
+	case "", "tcp":
+		addr = "127.0.0.1:0"
+		if rawQuery != "" {
+			addr += "?" + rawQuery
+		}
+	case "unix":
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			t.Fatalf("anyhttptest: bad address pattern: %q, err: %v", addrPattern, err)
+		}
+		if !query.Has("path") {
+			query.Set("path", filepath.Join(tempDir(t), "server.sock"))
+			addr = "unix?" + query.Encode()
+		}
+	}
+
+	ctx, err := anyhttp.Serve(addr, h)
+	if err != nil {
+		t.Fatalf("anyhttptest: failed to serve %q: %v", addr, err)
+	}
+
+	s := &Server{Ctx: ctx}
+	s.Client, s.URL = NewClient(ctx.Addr())
+
+	t.Cleanup(func() {
+		s.Client.CloseIdleConnections()
+		if err := ctx.Shutdown(context.Background()); err != nil && err != http.ErrServerClosed {
+			t.Errorf("anyhttptest: shutdown failed: %v", err)
+		}
+	})
+	return s
+}
+
+// NewClient returns a client that connects to addr and the base URL to use with it.
+// For unix sockets, the base URL is always http://unix
+func NewClient(addr net.Addr) (client *http.Client, baseURL string) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if addr.Network() == "unix" {
+		socketPath := addr.String()
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		}
+		baseURL = "http://unix"
+	} else {
+		baseURL = "http://" + addr.String()
+	}
+	return &http.Client{Transport: transport}, baseURL
+}
+
+// tempDir creates a short temp dir. t.TempDir() paths can be longer than the unix socket path limit of ~108 bytes
+func tempDir(t testing.TB) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "anyhttptest")
+	if err != nil {
+		t.Fatalf("anyhttptest: failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
@@ -0,0 +1,66 @@
+package anyhttptest
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"go.balki.me/anyhttp"
+)
+
+func TestNewServer(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello " + r.URL.Path))
+	})
+	for _, addrPattern := range []string{"tcp", "unix", "unix?mode=600"} {
+		t.Run(addrPattern, func(t *testing.T) {
+			s := NewServer(t, addrPattern, h)
+			resp, err := s.Client.Get(s.URL + "/foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(body), "hello /foo"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRunSysd(t *testing.T) {
+	sockets := []SysdSocket{{Name: "web"}, {Name: "admin", Network: "unix"}}
+	RunSysd(t, sockets,
+		func(ctx context.Context, t *testing.T) {
+			for _, addr := range []string{"sysd?name=web&unset_env=false", "sysd?idx=1"} {
+				addr := addr
+				srv, err := anyhttp.Serve(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(addr))
+				}))
+				if err != nil {
+					t.Fatalf("Serve(%q) failed: %v", addr, err)
+				}
+				defer srv.Shutdown(context.Background())
+			}
+			<-ctx.Done()
+		},
+		func(t *testing.T, addrs []net.Addr) {
+			for i, want := range []string{"sysd?name=web&unset_env=false", "sysd?idx=1"} {
+				client, baseURL := NewClient(addrs[i])
+				resp, err := client.Get(baseURL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != want {
+					t.Errorf("got %q, want %q", body, want)
+				}
+			}
+		})
+}
@@ -0,0 +1,162 @@
+package anyhttptest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// childEnv is set to the test name when the test binary is run as the socket activated child
+const childEnv = "ANYHTTPTEST_SYSD_CHILD"
+
+// SysdSocket is a listening socket passed to the child process as a systemd socket activated fd
+type SysdSocket struct {
+	// FileDescriptorName, passed in LISTEN_FDNAMES. Defaults to "unknown"
+	Name string
+	// "tcp", "unix", or "udp" and "unixgram" for datagram sockets. Defaults to "tcp"
+	Network string
+}
+
+// RunSysd emulates systemd socket activation for testing. It creates the sockets and runs the current test again in a
+// child process of the test binary with the sockets passed as fds starting at 3, and LISTEN_FDS, LISTEN_PID and LISTEN_FDNAMES set.
+//
+// In the child process, only child is called, typically serving using a sysd address. ctx is canceled once parent returns.
+// In the parent process, parent is called with the addresses of the sockets while the child is running. If parent is nil,
+// RunSysd waits for the child to exit by itself, e.g. with idle_timeout.
+// The test fails if the child fails.
+//
+//	func TestActivation(t *testing.T) {
+//		anyhttptest.RunSysd(t, []anyhttptest.SysdSocket{{Name: "web"}},
+//			func(ctx context.Context, t *testing.T) {
+//				srv, err := anyhttp.Serve("sysd?name=web", handler)
+//				...
+//				<-ctx.Done()
+//				srv.Shutdown(context.Background())
+//			},
+//			func(t *testing.T, addrs []net.Addr) {
+//				client, baseURL := anyhttptest.NewClient(addrs[0])
+//				...
+//			})
+//	}
+func RunSysd(t *testing.T, sockets []SysdSocket, child func(ctx context.Context, t *testing.T), parent func(t *testing.T, addrs []net.Addr)) {
+	t.Helper()
+
+	if os.Getenv(childEnv) == t.Name() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			// parent closes stdin when done
+			_, _ = io.Copy(io.Discard, os.Stdin)
+			cancel()
+		}()
+		child(ctx, t)
+		return
+	}
+
+	var files []*os.File
+	var addrs []net.Addr
+	var names []string
+	for idx, s := range sockets {
+		f, addr := listenFile(t, s)
+		defer f.Close()
+		files = append(files, f)
+		addrs = append(addrs, addr)
+		name := s.Name
+		if name == "" {
+			name = "unknown"
+		}
+		names = append(names, name)
+		t.Logf("anyhttptest: fd %v, name: %v, addr: %v", 3+idx, name, addr)
+	}
+
+	var runPattern []string
+	for _, part := range strings.Split(t.Name(), "/") {
+		runPattern = append(runPattern, "^"+regexp.QuoteMeta(part)+"$")
+	}
+
+	// LISTEN_PID has to be the pid of the child, which is known only after fork. Same trick as systemd-socket-activate
+	cmd := exec.Command("/bin/sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`, os.Args[0], "-test.run="+strings.Join(runPattern, "/"), "-test.v")
+	cmd.Env = append(os.Environ(),
+		childEnv+"="+t.Name(),
+		fmt.Sprintf("LISTEN_FDS=%d", len(files)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+	)
+	cmd.ExtraFiles = files
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("anyhttptest: failed to create stdin pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("anyhttptest: failed to start child: %v", err)
+	}
+
+	if parent != nil {
+		func() {
+			defer stdin.Close()
+			parent(t, addrs)
+		}()
+	}
+	err = cmd.Wait()
+	stdin.Close()
+	if err != nil {
+		t.Errorf("anyhttptest: child failed: %v, output:\n%s", err, output.Bytes())
+	}
+}
+
+func listenFile(t *testing.T, s SysdSocket) (*os.File, net.Addr) {
+	t.Helper()
+	var l interface {
+		Close() error
+		File() (*os.File, error)
+	}
+	var addr net.Addr
+	var err error
+	switch s.Network {
+	case "", "tcp":
+		var tl *net.TCPListener
+		if tl, err = net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}); err == nil {
+			l, addr = tl, tl.Addr()
+		}
+	case "unix":
+		var ul *net.UnixListener
+		ul, err = net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(tempDir(t), "sysd.sock"), Net: "unix"})
+		if err == nil {
+			// Socket file is still used by the child
+			ul.SetUnlinkOnClose(false)
+			l, addr = ul, ul.Addr()
+		}
+	case "udp":
+		var uc *net.UDPConn
+		if uc, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}); err == nil {
+			l, addr = uc, uc.LocalAddr()
+		}
+	case "unixgram":
+		var uc *net.UnixConn
+		if uc, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(tempDir(t), "sysd.sock"), Net: "unixgram"}); err == nil {
+			l, addr = uc, uc.LocalAddr()
+		}
+	default:
+		t.Fatalf("anyhttptest: unsupported network: %q", s.Network)
+	}
+	if err != nil {
+		t.Fatalf("anyhttptest: failed to listen: %v", err)
+	}
+	// File returns a dup, the listener is not needed in the parent anymore
+	defer l.Close()
+	f, err := l.File()
+	if err != nil {
+		t.Fatalf("anyhttptest: failed to get listener file: %v", err)
+	}
+	return f, addr
+}
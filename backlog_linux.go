@@ -0,0 +1,41 @@
+package anyhttp
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// pendingConns is true if any of the listeners has connections waiting to be accepted
+func pendingConns(listeners []net.Listener) bool {
+	for _, l := range listeners {
+		sc, ok := l.(syscall.Conn)
+		if !ok {
+			continue
+		}
+		rc, err := sc.SyscallConn()
+		if err != nil {
+			continue
+		}
+		pending := false
+		_ = rc.Control(func(fd uintptr) {
+			pending = readable(int(fd))
+		})
+		if pending {
+			return true
+		}
+	}
+	return false
+}
+
+// readable polls fd without blocking. A listening socket is readable when a connection is in the accept queue
+func readable(fd int) bool {
+	var set syscall.FdSet
+	bits := int(unsafe.Sizeof(set.Bits[0])) * 8
+	if fd >= len(set.Bits)*bits {
+		return false
+	}
+	set.Bits[fd/bits] |= 1 << (fd % bits)
+	n, err := syscall.Select(fd+1, &set, nil, nil, &syscall.Timeval{})
+	return err == nil && n > 0
+}
@@ -0,0 +1,30 @@
+package anyhttp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPendingConns(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if pendingConns([]net.Listener{l}) {
+		t.Error("pendingConns() = true without connections")
+	}
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !pendingConns([]net.Listener{l}) {
+		if time.Now().After(deadline) {
+			t.Fatal("pendingConns() = false with a connection in the accept queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
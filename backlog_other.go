@@ -0,0 +1,10 @@
+//go:build !linux
+
+package anyhttp
+
+import "net"
+
+// pendingConns is always false, the accept queue is checked only on linux
+func pendingConns([]net.Listener) bool {
+	return false
+}
@@ -0,0 +1,293 @@
+package anyhttp
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryParams is an ordered list of address query parameters
+type queryParams struct {
+	keys []string
+	vals map[string]string
+}
+
+func (q *queryParams) set(key, val string) {
+	if q.vals == nil {
+		q.vals = map[string]string{}
+	}
+	if _, ok := q.vals[key]; !ok {
+		q.keys = append(q.keys, key)
+	}
+	q.vals[key] = val
+}
+
+// queryValueEscaper keeps characters that are valid in a query unescaped for readability, e.g. unix?path=/run/app.sock
+var queryValueEscaper = strings.NewReplacer("%2F", "/", "%3A", ":", "%40", "@")
+
+func (q *queryParams) encode(base string) string {
+	var sb strings.Builder
+	sb.WriteString(base)
+	for i, key := range q.keys {
+		if i == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(key))
+		sb.WriteByte('=')
+		sb.WriteString(queryValueEscaper.Replace(url.QueryEscape(q.vals[key])))
+	}
+	return sb.String()
+}
+
+// UnixAddrBuilder builds unix socket addresses, e.g. UnixAddr("/run/app.sock").Mode(0660).String()
+type UnixAddrBuilder struct {
+	params queryParams
+}
+
+// UnixAddr starts building a unix socket address with the socket path
+func UnixAddr(path string) *UnixAddrBuilder {
+	b := &UnixAddrBuilder{}
+	b.params.set("path", path)
+	return b
+}
+
+// AbstractUnixAddr starts building a unix socket address in the linux abstract namespace, e.g. AbstractUnixAddr("myapp")
+func AbstractUnixAddr(name string) *UnixAddrBuilder {
+	b := &UnixAddrBuilder{}
+	b.params.set("abstract", name)
+	return b
+}
+
+// Mode sets the socket file permission
+func (b *UnixAddrBuilder) Mode(mode fs.FileMode) *UnixAddrBuilder {
+	b.params.set("mode", fmt.Sprintf("%o", uint32(mode)))
+	return b
+}
+
+// RemoveExisting sets whether to delete existing socket before creating new one
+func (b *UnixAddrBuilder) RemoveExisting(removeExisting bool) *UnixAddrBuilder {
+	b.params.set("remove_existing", strconv.FormatBool(removeExisting))
+	return b
+}
+
+// Watch sets the interval to check the socket file exists, and create it again if removed
+func (b *UnixAddrBuilder) Watch(interval time.Duration) *UnixAddrBuilder {
+	b.params.set("watch", interval.String())
+	return b
+}
+
+// Type sets the socket type, stream, seqpacket or dgram
+func (b *UnixAddrBuilder) Type(socketType string) *UnixAddrBuilder {
+	b.params.set("type", socketType)
+	return b
+}
+
+// Param sets any other option, e.g. Param("rcvbuf", "262144")
+func (b *UnixAddrBuilder) Param(key, val string) *UnixAddrBuilder {
+	b.params.set(key, val)
+	return b
+}
+
+// String returns the address to pass to Serve, ListenAndServe, GetListener etc.
+func (b *UnixAddrBuilder) String() string {
+	return b.params.encode("unix")
+}
+
+// SysdAddrBuilder builds systemd socket activated fd addresses, e.g. SysdAddr().Name("app.socket").IdleTimeout(30*time.Minute).String()
+type SysdAddrBuilder struct {
+	params queryParams
+}
+
+// SysdAddr starts building a systemd socket activated fd address. One of Name, Index, All or Accepted has to be set
+func SysdAddr() *SysdAddrBuilder {
+	return &SysdAddrBuilder{}
+}
+
+// Name sets the name configured via FileDescriptorName or the default socket file name
+func (b *SysdAddrBuilder) Name(name string) *SysdAddrBuilder {
+	b.params.set("name", name)
+	return b
+}
+
+// Index sets the fd index starting at 0
+func (b *SysdAddrBuilder) Index(idx int) *SysdAddrBuilder {
+	b.params.set("idx", strconv.Itoa(idx))
+	return b
+}
+
+// CheckPID sets whether to check process PID matches LISTEN_PID
+func (b *SysdAddrBuilder) CheckPID(checkPID bool) *SysdAddrBuilder {
+	b.params.set("check_pid", strconv.FormatBool(checkPID))
+	return b
+}
+
+// UnsetEnv sets whether to unset the LISTEN* environment variables
+func (b *SysdAddrBuilder) UnsetEnv(unsetEnv bool) *SysdAddrBuilder {
+	b.params.set("unset_env", strconv.FormatBool(unsetEnv))
+	return b
+}
+
+// IdleTimeout sets the duration after which the server is shutdown if no requests are received
+func (b *SysdAddrBuilder) IdleTimeout(timeout time.Duration) *SysdAddrBuilder {
+	b.params.set("idle_timeout", timeout.String())
+	return b
+}
+
+// IdleExit exits the process with code after shutting down on idle timeout
+func (b *SysdAddrBuilder) IdleExit(code int) *SysdAddrBuilder {
+	b.params.set("idle_exit", strconv.Itoa(code))
+	return b
+}
+
+// IdleNotify sets whether STOPPING=1 is sent to systemd before shutting down on idle timeout
+func (b *SysdAddrBuilder) IdleNotify(notify bool) *SysdAddrBuilder {
+	b.params.set("idle_notify", strconv.FormatBool(notify))
+	return b
+}
+
+// All serves on all socket activated fds, instead of Name or Index
+func (b *SysdAddrBuilder) All() *SysdAddrBuilder {
+	b.params.set("all", "true")
+	return b
+}
+
+// Accepted serves the connection accepted by systemd with Accept=yes. Name and Index are optional
+func (b *SysdAddrBuilder) Accepted() *SysdAddrBuilder {
+	b.params.set("accepted", "true")
+	return b
+}
+
+// Network verifies the fd is a socket of this network, e.g. tcp or unix
+func (b *SysdAddrBuilder) Network(network string) *SysdAddrBuilder {
+	b.params.set("network", network)
+	return b
+}
+
+// Param sets any other option, e.g. Param("max_conns", "auto")
+func (b *SysdAddrBuilder) Param(key, val string) *SysdAddrBuilder {
+	b.params.set(key, val)
+	return b
+}
+
+// String returns the address to pass to Serve, ListenAndServe, GetListener etc.
+func (b *SysdAddrBuilder) String() string {
+	return b.params.encode("sysd")
+}
+
+// TCPAddrBuilder builds tcp addresses with options, e.g. TCPAddr(":8080").Param("nodelay", "false").String()
+type TCPAddrBuilder struct {
+	hostPort string
+	params   queryParams
+}
+
+// TCPAddr starts building a tcp address with host:port, e.g. 127.0.0.1:8080 or :http
+func TCPAddr(hostPort string) *TCPAddrBuilder {
+	return &TCPAddrBuilder{hostPort: hostPort}
+}
+
+// Param sets an option, e.g. Param("rcvbuf", "262144")
+func (b *TCPAddrBuilder) Param(key, val string) *TCPAddrBuilder {
+	b.params.set(key, val)
+	return b
+}
+
+// String returns the address to pass to Serve, ListenAndServe, GetListener etc.
+func (b *TCPAddrBuilder) String() string {
+	return b.params.encode(b.hostPort)
+}
+
+// String returns the canonical address of the config, which can be passed back to Serve, GetListener etc.
+func (u UnixSocketConfig) String() string {
+	q := u.params()
+	return q.encode("unix")
+}
+
+func (u UnixSocketConfig) params() queryParams {
+	var q queryParams
+	q.set("path", u.SocketPath)
+	q.set("mode", fmt.Sprintf("%o", uint32(u.SocketMode)))
+	q.set("remove_existing", strconv.FormatBool(u.RemoveExisting))
+	if u.WatchInterval > 0 {
+		q.set("watch", u.WatchInterval.String())
+	}
+	if u.SocketType != "" {
+		q.set("type", u.SocketType)
+	}
+	return q
+}
+
+// String returns the canonical address of the config, which can be passed back to Serve, GetListener etc.
+func (s SysdConfig) String() string {
+	q := s.params()
+	return q.encode("sysd")
+}
+
+func (s SysdConfig) params() queryParams {
+	var q queryParams
+	if s.FDName != nil {
+		q.set("name", *s.FDName)
+	}
+	if s.FDIndex != nil {
+		q.set("idx", strconv.Itoa(*s.FDIndex))
+	}
+	q.set("check_pid", strconv.FormatBool(s.CheckPID))
+	q.set("unset_env", strconv.FormatBool(s.UnsetEnv))
+	if s.IdleTimeout != nil {
+		q.set("idle_timeout", s.IdleTimeout.String())
+	}
+	if s.IdleExitCode != nil {
+		q.set("idle_exit", strconv.Itoa(*s.IdleExitCode))
+	}
+	if s.IdleNotify != nil {
+		q.set("idle_notify", strconv.FormatBool(*s.IdleNotify))
+	}
+	if s.All {
+		q.set("all", "true")
+	}
+	if s.Accepted {
+		q.set("accepted", "true")
+	}
+	if s.Network != "" {
+		q.set("network", s.Network)
+	}
+	return q
+}
+
+// Address is an address string validated when set. Implements flag.Value and encoding.TextMarshaler, so that
+// CLIs and config files get errors for bad addresses early, e.g.
+//
+//	listen := anyhttp.Address(":8080")
+//	flag.Var(&listen, "listen", "address to listen on, e.g. unix?path=/run/app.sock")
+type Address string
+
+// String returns the address
+func (a Address) String() string {
+	return string(a)
+}
+
+// Set validates and sets the address
+func (a *Address) Set(addr string) error {
+	if _, err := parseAddr(addr); err != nil {
+		return err
+	}
+	*a = Address(addr)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (a Address) MarshalText() ([]byte, error) {
+	return []byte(a), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (a *Address) UnmarshalText(text []byte) error {
+	if err := a.Set(string(text)); err != nil {
+		return fmt.Errorf("invalid address %q: %w", text, err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package anyhttp
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestAddrBuilders(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want string
+	}{
+		{
+			name: "unix",
+			addr: UnixAddr("/run/app.sock").Mode(0660).String(),
+			want: "unix?path=/run/app.sock&mode=660",
+		},
+		{
+			name: "unix escaped path",
+			addr: UnixAddr("/run/my app&co.sock").RemoveExisting(false).Param("rcvbuf", "65536").String(),
+			want: "unix?path=/run/my+app%26co.sock&remove_existing=false&rcvbuf=65536",
+		},
+		{
+			name: "sysd",
+			addr: SysdAddr().Name("app.socket").IdleTimeout(30 * time.Minute).String(),
+			want: "sysd?name=app.socket&idle_timeout=30m0s",
+		},
+		{
+			name: "sysd index",
+			addr: SysdAddr().Index(1).CheckPID(false).UnsetEnv(false).String(),
+			want: "sysd?idx=1&check_pid=false&unset_env=false",
+		},
+		{
+			name: "tcp",
+			addr: TCPAddr(":8080").Param("nodelay", "false").String(),
+			want: ":8080?nodelay=false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.addr != tt.want {
+				t.Errorf("got %q, want %q", tt.addr, tt.want)
+			}
+			if _, err := parseAddr(tt.addr); err != nil {
+				t.Errorf("parseAddr(%q) failed: %v", tt.addr, err)
+			}
+		})
+	}
+
+	a, err := parseAddr(UnixAddr("/run/my app&co.sock").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.usc.SocketPath != "/run/my app&co.sock" {
+		t.Errorf("SocketPath = %q, want %q", a.usc.SocketPath, "/run/my app&co.sock")
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	usc := NewUnixSocketConfig("/run/my app.sock")
+	usc.SocketMode = 0600
+	if got, want := usc.String(), "unix?path=/run/my+app.sock&mode=600&remove_existing=true"; got != want {
+		t.Errorf("UnixSocketConfig.String() = %q, want %q", got, want)
+	}
+	a, err := parseAddr(usc.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *a.usc != usc {
+		t.Errorf("round trip failed, got %v, want %v", *a.usc, usc)
+	}
+
+	for _, sysc := range []SysdConfig{
+		NewSysDConfigWithFDName("app.socket"),
+		func() SysdConfig {
+			sysc := NewSysDConfigWithFDIdx(2)
+			sysc.CheckPID = false
+			sysc.IdleTimeout = ptr(90 * time.Second)
+			return sysc
+		}(),
+	} {
+		a, err := parseAddr(sysc.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := *a.sysc
+		if got.String() != sysc.String() || !check(got.FDName, sysc.FDName) || !check(got.FDIndex, sysc.FDIndex) ||
+			!check(got.IdleTimeout, sysc.IdleTimeout) || got.CheckPID != sysc.CheckPID || got.UnsetEnv != sysc.UnsetEnv {
+			t.Errorf("round trip failed, got %v, want %v", got, sysc)
+		}
+	}
+	if got, want := NewSysDConfigWithFDName("app.socket").String(), "sysd?name=app.socket&check_pid=true&unset_env=true"; got != want {
+		t.Errorf("SysdConfig.String() = %q, want %q", got, want)
+	}
+}
+
+func TestAddressFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	listen := Address(":8080")
+	fs.Var(&listen, "listen", "address to listen on")
+	if err := fs.Parse([]string{"-listen", "unix?path=/run/app.sock"}); err != nil {
+		t.Fatal(err)
+	}
+	if listen != "unix?path=/run/app.sock" {
+		t.Errorf("listen = %q", listen)
+	}
+	if err := fs.Parse([]string{"-listen", "unix?mode=600"}); err == nil {
+		t.Error("parsing unix address without path should fail")
+	}
+
+	var cfg struct {
+		Listen Address `json:"listen"`
+	}
+	if err := json.Unmarshal([]byte(`{"listen": "sysd?name=app.socket"}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != "sysd?name=app.socket" {
+		t.Errorf("listen = %q", cfg.Listen)
+	}
+	if err := json.Unmarshal([]byte(`{"listen": "sysd?idx=0&name=app.socket"}`), &cfg); err == nil {
+		t.Error("unmarshalling bad sysd address should fail")
+	}
+}
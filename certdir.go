@@ -0,0 +1,128 @@
+package anyhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCertRescan is the interval to rescan cert_dir for added, removed or renewed certificates
+const defaultCertRescan = time.Minute
+
+// certDir has the certificates in a directory, selected by SNI. Each certificate is a <name>.crt or <name>.pem file with the
+// key in <name>.key or <name>-key.pem, or both in the same .pem file
+type certDir struct {
+	dir  string
+	logf func(format string, args ...any)
+
+	mu sync.RWMutex
+	// by dns name in certificate, including wildcard names like *.example.com
+	byName map[string]*tls.Certificate
+	// used when no certificate matches the name, e.g. clients without SNI. First one by file name
+	fallback *tls.Certificate
+}
+
+func loadCertDir(dir string, logf func(format string, args ...any)) (*certDir, error) {
+	c := &certDir{dir: dir, logf: logf}
+	if err := c.scan(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// scan loads all certificates in the directory. Current certificates are kept if there is none
+func (c *certDir) scan() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("cert_dir error. err: %w", err)
+	}
+	byName := map[string]*tls.Certificate{}
+	var fallback *tls.Certificate
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".crt" && ext != ".pem") || strings.HasSuffix(e.Name(), "-key.pem") {
+			continue
+		}
+		certFile := filepath.Join(c.dir, e.Name())
+		keyFile := certFile
+		for _, kf := range []string{strings.TrimSuffix(certFile, ext) + ".key", strings.TrimSuffix(certFile, ext) + "-key.pem"} {
+			if _, err := os.Stat(kf); err == nil {
+				keyFile = kf
+				break
+			}
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			// e.g. CA bundles or a renewal in progress
+			c.logf("anyhttp: cert_dir: skipping %v: %v", certFile, err)
+			continue
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			c.logf("anyhttp: cert_dir: skipping %v: %v", certFile, err)
+			continue
+		}
+		cert.Leaf = leaf
+		for _, name := range leaf.DNSNames {
+			byName[strings.ToLower(name)] = &cert
+		}
+		for _, ip := range leaf.IPAddresses {
+			byName[ip.String()] = &cert
+		}
+		if fallback == nil {
+			fallback = &cert
+		}
+	}
+	if fallback == nil {
+		return fmt.Errorf("cert_dir error. No certificates found in %v", c.dir)
+	}
+	c.mu.Lock()
+	c.byName, c.fallback = byName, fallback
+	c.mu.Unlock()
+	return nil
+}
+
+// certSource is a cert_dir or cert file, reloaded every cert_rescan and on Reload
+type certSource interface {
+	scan() error
+	getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// rescanCerts scans the sources every interval until stop is closed
+func rescanCerts(sources []certSource, interval time.Duration, stop <-chan struct{}, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, c := range sources {
+				if err := c.scan(); err != nil {
+					logf("anyhttp: %v, keeping current certificates", err)
+				}
+			}
+		}
+	}
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (c *certDir) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if cert, ok := c.byName[name]; ok {
+		return cert, nil
+	}
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		if cert, ok := c.byName["*."+rest]; ok {
+			return cert, nil
+		}
+	}
+	return c.fallback, nil
+}
@@ -0,0 +1,45 @@
+package anyhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"go.balki.me/anyhttp/devca"
+)
+
+func TestCertDir(t *testing.T) {
+	ca, err := devca.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, _, err := ca.CertFiles("a.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ca.CertFiles("*.b.test"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := Serve("127.0.0.1:0?cert_dir="+filepath.Dir(certFile), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	for _, serverName := range []string{"a.test", "x.b.test"} {
+		conn, err := tls.Dial("tcp", ctx.Addr().String(), &tls.Config{RootCAs: roots, ServerName: serverName})
+		if err != nil {
+			t.Errorf("handshake with %v failed: %v", serverName, err)
+			continue
+		}
+		conn.Close()
+	}
+
+	if _, err := Serve("127.0.0.1:0?cert_dir="+t.TempDir(), nil); err == nil {
+		t.Error("Serve should fail for cert_dir without certificates")
+	}
+}
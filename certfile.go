@@ -0,0 +1,85 @@
+package anyhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// certPair is a certificate and key file, reloaded when either changes, e.g. renewed by certbot or an ACME client
+type certPair struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	// size and modification time of the files when loaded
+	stamp string
+}
+
+func loadCertPair(certFile, keyFile string) (*certPair, error) {
+	c := &certPair{certFile: certFile, keyFile: keyFile}
+	if err := c.scan(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// scan loads the certificate again if the files changed. The current one is kept if loading fails, e.g. while renewal
+// is in progress
+func (c *certPair) scan() error {
+	var stamp string
+	for _, name := range []string{c.certFile, c.keyFile} {
+		// Follows symlinks, e.g. certbot's live directory
+		fi, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("cert error. err: %w", err)
+		}
+		stamp += fmt.Sprintf("%v:%v;", fi.Size(), fi.ModTime().UnixNano())
+	}
+	c.mu.RLock()
+	unchanged := c.stamp == stamp
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("cert error. Bad %v: %w", c.certFile, err)
+	}
+	c.mu.Lock()
+	c.cert, c.stamp = &cert, stamp
+	c.mu.Unlock()
+	return nil
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (c *certPair) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// clientAuthTypes are the values of client_auth, named after tls.ClientAuthType
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// loadClientCAs reads the PEM encoded CA certificates that client certificates are verified with
+func loadClientCAs(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cert error. Bad client_ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("cert error. Bad client_ca: no certificates in %v", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,173 @@
+package anyhttp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.balki.me/anyhttp/devca"
+)
+
+func TestCertFileReload(t *testing.T) {
+	ca, err := devca.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile, err := ca.CertFiles("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := Serve("127.0.0.1:0?cert_rescan=1h&cert="+certFile+"&key="+keyFile, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	serial := func() string {
+		conn, err := tls.Dial("tcp", ctx.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "localhost"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+	}
+	before := serial()
+
+	// Renewed, with a different modification time
+	time.Sleep(10 * time.Millisecond)
+	if _, _, err := ca.CertFiles("localhost"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if after := serial(); after == before {
+		t.Error("renewed certificate not served after Reload")
+	}
+
+	if _, err := Serve("127.0.0.1:0?cert="+certFile+".missing", nil); err == nil {
+		t.Error("Serve should fail for a missing cert file")
+	}
+}
+
+// clientCert creates a CA in dir/ca.pem and a client certificate signed by it
+func clientCert(t *testing.T, dir string) (caFile string, cert tls.Certificate) {
+	t.Helper()
+	newCert := func(tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c, key
+	}
+	now := time.Now()
+	ca, caKey := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test client CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	leaf, key := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	caFile = filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile, tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientCA(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := devca.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile, err := ca.CertFiles("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile, cert := clientCert(t, dir)
+	tlsOpts := "&cert=" + certFile + "&key=" + keyFile
+	ctx, err := Serve("127.0.0.1:0?client_ca="+caFile+tlsOpts, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if ctx.TLSConfig.ClientCAFile != caFile {
+		t.Errorf("TLSConfig = %+v", ctx.TLSConfig)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	get := func(certs ...tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs},
+		}}
+		resp, err := client.Get("https://" + ctx.Addr().String())
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	if err := get(); err == nil {
+		t.Error("request succeeded without a client certificate")
+	}
+	if err := get(cert); err != nil {
+		t.Errorf("request with client certificate: %v", err)
+	}
+
+	l := ListenerConfig{TCP: &TCPConfig{Addr: "127.0.0.1:0"}, TLS: &TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}}
+	lctx, err := l.Serve(text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lctx.Server.Close()
+	if lctx.Server.TLSConfig.ClientAuth != tls.RequireAndVerifyClientCert || l.Options != nil {
+		t.Errorf("ListenerConfig.Serve: ClientAuth = %v, Options = %v", lctx.Server.TLSConfig.ClientAuth, l.Options)
+	}
+
+	for _, addr := range []string{
+		"127.0.0.1:0?client_ca=" + caFile,
+		"127.0.0.1:0?client_ca=" + certFile + ".missing" + tlsOpts,
+		"127.0.0.1:0?client_ca=" + keyFile + tlsOpts,
+		"127.0.0.1:0?client_auth=require_and_verify" + tlsOpts,
+		"127.0.0.1:0?client_auth=always" + tlsOpts,
+	} {
+		if ctx, err := Serve(addr, nil); err == nil {
+			ctx.Server.Close()
+			t.Errorf("Serve(%q) should fail", addr)
+		}
+	}
+}
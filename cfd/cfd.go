@@ -0,0 +1,186 @@
+// Package cfd adds the cfd address scheme to anyhttp, to serve through a Cloudflare Tunnel without opening any ports.
+// cloudflared is run connecting the tunnel to a private unix socket that anyhttp listens on. cloudflared has to be
+// installed and the tunnel created, e.g. using cloudflared tunnel create. Import for side effects
+//
+// The tunnel is not connected in process with the cloudflared library, which has no stable Go API and would add its
+// large dependency tree to this module. So the cloudflared binary is needed at runtime: Serve fails if it can not be
+// started, and the server stops with an error if it exits, e.g. when the tunnel is deleted
+//
+//	import _ "go.balki.me/anyhttp/cfd"
+//
+//	anyhttp.Serve("cfd?tunnel=mytunnel", h)
+//	anyhttp.Serve("cfd?token_file=/etc/app/tunnel.token&shutdown_timeout=30s", h)
+package cfd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.balki.me/anyhttp"
+)
+
+// Scheme is the address scheme, also the anyhttp.AddressType of the listeners
+const Scheme = "cfd"
+
+func init() {
+	anyhttp.RegisterScheme(Scheme, listen)
+}
+
+// Config has the options of the cfd scheme
+type Config struct {
+	// Name or UUID of the tunnel, as in cloudflared tunnel run <tunnel>. Not needed with TokenFile
+	Tunnel string
+	// File with the tunnel token, passed in TUNNEL_TOKEN, for remotely managed tunnels
+	TokenFile string
+	// cloudflared binary. Defaults to cloudflared in PATH
+	Cloudflared string
+}
+
+// Listen starts cloudflared serving the tunnel from a unix socket in a private temporary directory and returns the
+// listener of the socket. cloudflared is stopped when the listener is closed, and killed if it is still running after
+// shutdown_timeout, see SetShutdownTimeout. If cloudflared exits, Accept fails
+func Listen(cfg Config) (net.Listener, error) {
+	if cfg.Tunnel == "" && cfg.TokenFile == "" {
+		return nil, errors.New("cfd address error. One of tunnel or token_file has to be set")
+	}
+	bin := cfg.Cloudflared
+	if bin == "" {
+		bin = "cloudflared"
+	}
+	env := os.Environ()
+	if cfg.TokenFile != "" {
+		token, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cfd address error. Failed to read token_file, err: %w", err)
+		}
+		// Not in args, which are visible to other users
+		env = append(env, "TUNNEL_TOKEN="+strings.TrimSpace(string(token)))
+	}
+
+	dir, err := os.MkdirTemp("", "anyhttp-cfd-")
+	if err != nil {
+		return nil, err
+	}
+	sockPath := filepath.Join(dir, "origin.sock")
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("cfd address error. Failed to listen on %v, err: %w", sockPath, err)
+	}
+
+	args := []string{"tunnel", "--no-autoupdate", "run", "--unix-socket", sockPath}
+	if cfg.Tunnel != "" {
+		args = append(args, cfg.Tunnel)
+	}
+	cmd := exec.Command(bin, args...)
+	cmd.Env = env
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		l.Close()
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("cfd address error. Failed to start cloudflared, err: %w", err)
+	}
+	tl := &listener{Listener: l, cmd: cmd, dir: dir, exited: make(chan struct{})}
+	go tl.wait()
+	return tl, nil
+}
+
+func listen(query url.Values) (net.Listener, error) {
+	var cfg Config
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("cfd address error. Multiple %v found: %v", key, val)
+		}
+		switch key {
+		case "tunnel":
+			cfg.Tunnel = val[0]
+		case "token_file":
+			cfg.TokenFile = val[0]
+		case "cloudflared":
+			cfg.Cloudflared = val[0]
+		default:
+			return nil, fmt.Errorf("cfd address error. Bad option; key: %v, val: %v", key, val)
+		}
+	}
+	return Listen(cfg)
+}
+
+type listener struct {
+	net.Listener
+	cmd *exec.Cmd
+	dir string
+	// cloudflared is killed if it does not exit in time after Close. 0 waits for it to exit by itself
+	stopTimeout time.Duration
+
+	// closed after cloudflared exits, waitErr is set before
+	exited  chan struct{}
+	waitErr error
+
+	closeOnce sync.Once
+	closed    atomic.Bool
+}
+
+func (l *listener) wait() {
+	l.waitErr = l.cmd.Wait()
+	close(l.exited)
+	// Unblocks Accept
+	l.Listener.Close()
+	os.RemoveAll(l.dir)
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		select {
+		case <-l.exited:
+			if !l.closed.Load() {
+				if l.waitErr != nil {
+					return nil, fmt.Errorf("cloudflared exited, err: %w", l.waitErr)
+				}
+				return nil, errors.New("cloudflared exited")
+			}
+		default:
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetShutdownTimeout is called by anyhttp with shutdown_timeout of the address, before serving
+func (l *listener) SetShutdownTimeout(timeout time.Duration) {
+	l.stopTimeout = timeout
+}
+
+// Close stops cloudflared, which finishes the requests in progress before exiting, within its --grace-period. Does not
+// wait for it, so that the server keeps serving those requests meanwhile
+func (l *listener) Close() error {
+	l.closed.Store(true)
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() {
+		if serr := l.cmd.Process.Signal(syscall.SIGTERM); serr != nil {
+			_ = l.cmd.Process.Kill()
+			return
+		}
+		if l.stopTimeout > 0 {
+			go func() {
+				select {
+				case <-l.exited:
+				case <-time.After(l.stopTimeout):
+					_ = l.cmd.Process.Kill()
+				}
+			}()
+		}
+	})
+	return err
+}
@@ -0,0 +1,166 @@
+package cfd
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.balki.me/anyhttp"
+)
+
+// fakeCloudflared writes its args and TUNNEL_TOKEN to a file and waits until terminated
+const fakeCloudflared = `#!/bin/sh
+echo "$@" > "$0.args"
+echo "$TUNNEL_TOKEN" >> "$0.args"
+trap 'echo stopped >> "$0.args"; exit 0' TERM
+while true; do sleep 0.01; done
+`
+
+func fake(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	bin := filepath.Join(t.TempDir(), "cloudflared")
+	if err := os.WriteFile(bin, []byte(fakeCloudflared), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+// waitArgs returns the lines written by the fake cloudflared once it has at least n lines
+func waitArgs(t *testing.T, bin string, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(bin + ".args")
+		// Not yet written is also one empty line
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) >= n && lines[0] != "" {
+			return lines
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cloudflared did not write %v lines, got: %q", n, data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServe(t *testing.T) {
+	bin := fake(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := anyhttp.Serve("cfd?tunnel=mytunnel&token_file="+tokenFile+"&cloudflared="+bin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tunneled"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.AddressType != Scheme {
+		t.Errorf("AddressType = %v, want %v", ctx.AddressType, Scheme)
+	}
+
+	lines := waitArgs(t, bin, 2)
+	args := strings.Fields(lines[0])
+	if len(args) != 6 || strings.Join(args[:4], " ") != "tunnel --no-autoupdate run --unix-socket" || args[5] != "mytunnel" {
+		t.Fatalf("unexpected args: %q", args)
+	}
+	if lines[1] != "secret" {
+		t.Errorf("TUNNEL_TOKEN = %q, want secret", lines[1])
+	}
+
+	// Same as cloudflared connecting to the origin
+	sockPath := args[4]
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sockPath)
+		},
+	}}
+	resp, err := client.Get("http://app.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "tunneled" {
+		t.Errorf("got %q, want tunneled", body)
+	}
+
+	if err := ctx.Shutdown(context.Background()); err != http.ErrServerClosed {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if lines := waitArgs(t, bin, 3); lines[2] != "stopped" {
+		t.Errorf("cloudflared should be stopped, got: %q", lines)
+	}
+	waitRemoved(t, filepath.Dir(sockPath))
+}
+
+// waitRemoved waits for the socket dir to be removed, once cloudflared exits
+func waitRemoved(t *testing.T, dir string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("socket dir should be removed, err: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	bin := fake(t)
+	// Ignores SIGTERM, e.g. stuck requests
+	stuck := strings.Replace(fakeCloudflared, "trap 'echo stopped >> \"$0.args\"; exit 0' TERM", "trap '' TERM", 1)
+	if err := os.WriteFile(bin, []byte(stuck), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := anyhttp.Serve("cfd?tunnel=mytunnel&shutdown_timeout=100ms&cloudflared="+bin, http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sockPath := strings.Fields(waitArgs(t, bin, 1)[0])[4]
+	start := time.Now()
+	if err := ctx.Shutdown(context.Background()); err != http.ErrServerClosed {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("Shutdown took %v, should not wait for cloudflared", took)
+	}
+	waitRemoved(t, filepath.Dir(sockPath))
+}
+
+func TestCloudflaredExit(t *testing.T) {
+	ctx, err := anyhttp.Serve("cfd?tunnel=mytunnel&cloudflared=false", http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-ctx.Done:
+		if err == nil || !strings.Contains(err.Error(), "cloudflared exited") {
+			t.Errorf("Done = %v, want cloudflared exited error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server should stop when cloudflared exits")
+	}
+}
+
+func TestListenErrors(t *testing.T) {
+	for _, addr := range []string{"cfd", "cfd?tunnel=a&foo=bar", "cfd?token_file=/nonexistent", "cfd?tunnel=a&cloudflared=/nonexistent"} {
+		if _, err := anyhttp.Serve(addr, http.NotFoundHandler()); err == nil {
+			t.Errorf("Serve(%q) should fail", addr)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Command anyhttp serves a static directory, reverse proxies or forwards raw streams using anyhttp addresses for both sides.
+// Handy to test socket activation setups.
+//
+//	anyhttp static -listen 'unix?path=/run/web.sock' -dir /srv/www
+//	anyhttp proxy -listen 'sysd?name=web.socket&idle_timeout=10m' -to 127.0.0.1:8080
+//	anyhttp forward -listen :2222 -to 'unix?path=/run/app.sock'
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+
+	"go.balki.me/anyhttp"
+	"go.balki.me/anyhttp/idle"
+)
+
+const usage = `Usage: anyhttp <command> [flags]
+
+Commands:
+  static   serve a static directory
+  proxy    reverse proxy http requests to another address
+  forward  forward raw streams to another address
+
+Run 'anyhttp <command> -h' for flags of a command
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	var err error
+	switch cmd {
+	case "static":
+		err = static(args)
+	case "proxy":
+		err = proxy(args)
+	case "forward":
+		err = forward(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func static(args []string) error {
+	fs := flag.NewFlagSet("static", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "anyhttp address to listen on")
+	dir := fs.String("dir", ".", "directory to serve")
+	_ = fs.Parse(args)
+
+	log.Printf("serving %v on %v", *dir, *listen)
+	return anyhttp.ListenAndServe(*listen, http.FileServer(http.Dir(*dir)))
+}
+
+func proxy(args []string) error {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "anyhttp address to listen on")
+	to := fs.String("to", "", "anyhttp address of the upstream http server, unix or tcp")
+	_ = fs.Parse(args)
+	if *to == "" {
+		return fmt.Errorf("proxy: -to is required")
+	}
+
+	// Host is not used for dialing, upstream is always dialed using the anyhttp address
+	rp := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: "upstream"})
+	rp.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return anyhttp.DialContext(ctx, *to)
+		},
+	}
+	log.Printf("proxying %v to %v", *listen, *to)
+	return anyhttp.ListenAndServe(*listen, rp)
+}
+
+func forward(args []string) error {
+	fs := flag.NewFlagSet("forward", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "anyhttp address to listen on")
+	to := fs.String("to", "", "anyhttp address to forward connections to, unix or tcp")
+	_ = fs.Parse(args)
+	if *to == "" {
+		return fmt.Errorf("forward: -to is required")
+	}
+
+	l, addrType, cfg, err := anyhttp.GetListener(*listen)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	var idler idle.Idler
+	if sysc, ok := cfg.(*anyhttp.SysdConfig); ok && sysc.IdleTimeout != nil {
+		idler = idle.CreateIdler(*sysc.IdleTimeout)
+		go func() {
+			idler.Wait()
+			log.Printf("idle for %v, exiting", *sysc.IdleTimeout)
+			l.Close()
+		}()
+	}
+
+	log.Printf("forwarding %v (%v) to %v", *listen, addrType, *to)
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if idler != nil && isClosed(idler) {
+				return nil
+			}
+			return err
+		}
+		if idler != nil {
+			idler.Enter()
+		}
+		go func() {
+			if idler != nil {
+				defer idler.Exit()
+			}
+			pipe(c, *to)
+		}()
+	}
+}
+
+func isClosed(idler idle.Idler) bool {
+	select {
+	case <-idler.Chan():
+		return true
+	default:
+		return false
+	}
+}
+
+func pipe(c net.Conn, to string) {
+	defer c.Close()
+	upstream, err := anyhttp.DialContext(context.Background(), to)
+	if err != nil {
+		log.Printf("failed to connect to %v: %v", to, err)
+		return
+	}
+	defer upstream.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	cp := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+		// Propagate EOF so that the other direction can finish
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go cp(upstream, c)
+	go cp(c, upstream)
+	wg.Wait()
+}
@@ -0,0 +1,429 @@
+package anyhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TCPConfig has the configuration for tcp listener
+type TCPConfig struct {
+	// host:port to listen on, e.g. :8080 or 127.0.0.1:http. Defaults to :http
+	Addr string `json:"addr" yaml:"addr"`
+}
+
+// GetListener returns the tcp listener
+func (t *TCPConfig) GetListener() (net.Listener, error) {
+	addr := t.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	return net.Listen("tcp", addr)
+}
+
+// TLSConfig has the certificate and key files to serve https
+type TLSConfig struct {
+	// PEM encoded certificate file, may contain intermediate certificates
+	CertFile string `json:"cert" yaml:"cert"`
+	// PEM encoded private key file
+	KeyFile string `json:"key" yaml:"key"`
+	// PEM encoded CA certificates to verify client certificates with, for mutual TLS. Same as the client_ca option
+	ClientCAFile string `json:"client_ca,omitempty" yaml:"client_ca,omitempty"`
+	// Client certificate policy, same as the client_auth option, e.g. require_and_verify
+	ClientAuth string `json:"client_auth,omitempty" yaml:"client_auth,omitempty"`
+}
+
+// options adds ClientCAFile and ClientAuth to the address options
+func (t *TLSConfig) options(opts map[string]string) map[string]string {
+	if t.ClientCAFile == "" && t.ClientAuth == "" {
+		return opts
+	}
+	merged := map[string]string{}
+	for key, val := range opts {
+		merged[key] = val
+	}
+	if t.ClientCAFile != "" {
+		merged["client_ca"] = t.ClientCAFile
+	}
+	if t.ClientAuth != "" {
+		merged["client_auth"] = t.ClientAuth
+	}
+	return merged
+}
+
+// ListenerConfig has exactly one of Unix, Sysd, S6, Vsock or TCP configs, with optional TLS. Meant to be embedded in application config files.
+// It can be decoded from an address string or from an object, e.g. in json
+//
+//	"unix?path=/run/app.sock&mode=660"
+//	{"unix": {"path": "/run/app.sock", "mode": "660"}, "tls": {"cert": "app.pem", "key": "app.key"}}
+//	{"sysd": {"name": "app.socket", "idle_timeout": "30m"}, "options": {"max_conns": "auto"}}
+type ListenerConfig struct {
+	Unix  *UnixSocketConfig `json:"unix,omitempty" yaml:"unix,omitempty"`
+	Sysd  *SysdConfig       `json:"sysd,omitempty" yaml:"sysd,omitempty"`
+	S6    *S6Config         `json:"s6,omitempty" yaml:"s6,omitempty"`
+	Vsock *VsockConfig      `json:"vsock,omitempty" yaml:"vsock,omitempty"`
+	TCP   *TCPConfig        `json:"tcp,omitempty" yaml:"tcp,omitempty"`
+	TLS   *TLSConfig        `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// Options supported by all address types, e.g. {"rcvbuf": "262144"}
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// String returns the address of the listener. TLS is not part of the address
+func (l ListenerConfig) String() string {
+	var q queryParams
+	var base string
+	switch {
+	case l.Unix != nil:
+		q, base = l.Unix.params(), "unix"
+	case l.Sysd != nil:
+		q, base = l.Sysd.params(), "sysd"
+	case l.S6 != nil:
+		q, base = l.S6.params(), "s6"
+	case l.Vsock != nil:
+		q, base = l.Vsock.params(), "vsock"
+	case l.TCP != nil:
+		base = l.TCP.Addr
+	}
+	keys := make([]string, 0, len(l.Options))
+	for key := range l.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		q.set(key, l.Options[key])
+	}
+	return q.encode(base)
+}
+
+// GetListener returns the listener. See GetListener
+func (l ListenerConfig) GetListener() (net.Listener, AddressType, any /* cfg */, error) {
+	return GetListener(l.String())
+}
+
+// Serve creates and serves a HTTP server, or HTTPS if TLS is set
+func (l ListenerConfig) Serve(h http.Handler) (*ServerCtx, error) {
+	if l.TLS != nil {
+		l.Options = l.TLS.options(l.Options)
+		return ServeTLS(l.String(), h, l.TLS.CertFile, l.TLS.KeyFile)
+	}
+	return Serve(l.String(), h)
+}
+
+func (l *ListenerConfig) validate() error {
+	n := 0
+	for _, set := range []bool{l.Unix != nil, l.Sysd != nil, l.S6 != nil, l.Vsock != nil, l.TCP != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("listener config error. Exactly one of unix, sysd, s6, vsock and tcp has to be set, found: %v", n)
+	}
+	if _, err := parseAddr(l.String()); err != nil {
+		return fmt.Errorf("listener config error. %w", err)
+	}
+	return nil
+}
+
+// UnmarshalText sets the config from an address string. Implements encoding.TextUnmarshaler
+func (l *ListenerConfig) UnmarshalText(text []byte) error {
+	a, err := parseAddr(string(text))
+	if err != nil {
+		return err
+	}
+	*l = ListenerConfig{Unix: a.usc, Sysd: a.sysc, S6: a.s6c, Vsock: a.vsc, Options: a.common}
+	if a.addrType == TCP {
+		l.TCP = &TCPConfig{Addr: a.tcpAddr}
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts either an address string or an object
+func (l *ListenerConfig) UnmarshalJSON(data []byte) error {
+	if isJSONString(data) {
+		return unmarshalJSONText(data, l)
+	}
+	type plain ListenerConfig
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*l = ListenerConfig(p)
+	return l.validate()
+}
+
+// UnmarshalYAML accepts either an address string or a mapping. Works with both gopkg.in/yaml.v2 and gopkg.in/yaml.v3
+func (l *ListenerConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	var addr string
+	if unmarshal(&addr) == nil {
+		return l.UnmarshalText([]byte(addr))
+	}
+	type plain ListenerConfig
+	var p plain
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	*l = ListenerConfig(p)
+	return l.validate()
+}
+
+// UnmarshalText sets the config from a unix socket address string. Implements encoding.TextUnmarshaler
+func (u *UnixSocketConfig) UnmarshalText(text []byte) error {
+	a, err := parseAddr(string(text))
+	if err != nil {
+		return err
+	}
+	if a.usc == nil {
+		return fmt.Errorf("unix socket config error. Not a unix socket address: %q", text)
+	}
+	if err := noCommonOptions(a); err != nil {
+		return fmt.Errorf("unix socket config error. %w", err)
+	}
+	*u = *a.usc
+	return nil
+}
+
+// MarshalJSON writes mode in octal and watch as a duration string, e.g. {"path": "/run/app.sock", "mode": "660", "remove_existing": true}
+func (u UnixSocketConfig) MarshalJSON() ([]byte, error) {
+	type plain UnixSocketConfig
+	aux := struct {
+		plain
+		Mode  string `json:"mode"`
+		Watch string `json:"watch,omitempty"`
+	}{plain: plain(u), Mode: fmt.Sprintf("%o", uint32(u.SocketMode))}
+	if u.WatchInterval > 0 {
+		aux.Watch = u.WatchInterval.String()
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON accepts either an address string or an object. Missing fields are set to defaults.
+// mode is an octal string, e.g. "660", watch can be a duration string or nanoseconds
+func (u *UnixSocketConfig) UnmarshalJSON(data []byte) error {
+	if isJSONString(data) {
+		return unmarshalJSONText(data, u)
+	}
+	type plain UnixSocketConfig
+	usc := DefaultUnixSocketConfig
+	aux := struct {
+		*plain
+		Mode  json.RawMessage `json:"mode"`
+		Watch json.RawMessage `json:"watch"`
+	}{plain: (*plain)(&usc)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Mode != nil {
+		// A number would be read as decimal, e.g. 660 is not 0660
+		if !isJSONString(aux.Mode) {
+			return fmt.Errorf("unix socket config error. Bad mode: %s, must be an octal string, e.g. \"660\"", aux.Mode)
+		}
+		var mode string
+		if err := json.Unmarshal(aux.Mode, &mode); err != nil {
+			return err
+		}
+		if err := parseSocketMode(mode, &usc.SocketMode); err != nil {
+			return err
+		}
+	}
+	if aux.Watch != nil && !bytes.Equal(aux.Watch, []byte("null")) {
+		interval, err := unmarshalJSONDuration(aux.Watch)
+		if err != nil {
+			return fmt.Errorf("unix socket config error. Bad watch: %s, err: %w", aux.Watch, err)
+		}
+		usc.WatchInterval = interval
+	}
+	if usc.SocketPath == "" {
+		return errors.New("unix socket config error. Missing path")
+	}
+	*u = usc
+	return nil
+}
+
+// parseSocketMode parses the octal mode of config files, same as the mode option
+func parseSocketMode(mode string, fileMode *fs.FileMode) error {
+	if _, err := fmt.Sscanf(mode, "%o", fileMode); err != nil {
+		return fmt.Errorf("unix socket config error. Bad mode: %v, err: %w", mode, err)
+	}
+	return nil
+}
+
+// MarshalYAML writes mode as an octal string, same as MarshalJSON
+func (u UnixSocketConfig) MarshalYAML() (any, error) {
+	type plain UnixSocketConfig
+	return struct {
+		plain `yaml:",inline"`
+		Mode  string `yaml:"mode"`
+	}{plain: plain(u), Mode: fmt.Sprintf("%o", uint32(u.SocketMode))}, nil
+}
+
+// UnmarshalYAML accepts either an address string or a mapping. Missing fields are set to defaults. mode is an octal
+// string, same as UnmarshalJSON
+func (u *UnixSocketConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	var addr string
+	if unmarshal(&addr) == nil {
+		return u.UnmarshalText([]byte(addr))
+	}
+	type plain UnixSocketConfig
+	usc := DefaultUnixSocketConfig
+	if err := unmarshal((*plain)(&usc)); err != nil {
+		return err
+	}
+	var aux struct {
+		Mode any `yaml:"mode"`
+	}
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+	if aux.Mode != nil {
+		// Unquoted 660 is a number, read as decimal
+		mode, ok := aux.Mode.(string)
+		if !ok {
+			return fmt.Errorf("unix socket config error. Bad mode: %v, must be an octal string, e.g. \"660\"", aux.Mode)
+		}
+		if err := parseSocketMode(mode, &usc.SocketMode); err != nil {
+			return err
+		}
+	}
+	if usc.SocketPath == "" {
+		return errors.New("unix socket config error. Missing path")
+	}
+	*u = usc
+	return nil
+}
+
+// UnmarshalText sets the config from a systemd address string. Implements encoding.TextUnmarshaler
+func (s *SysdConfig) UnmarshalText(text []byte) error {
+	a, err := parseAddr(string(text))
+	if err != nil {
+		return err
+	}
+	if a.sysc == nil {
+		return fmt.Errorf("systemd socket fd config error. Not a systemd address: %q", text)
+	}
+	if err := noCommonOptions(a); err != nil {
+		return fmt.Errorf("systemd socket fd config error. %w", err)
+	}
+	*s = *a.sysc
+	return nil
+}
+
+// MarshalJSON writes idle_timeout as a duration string, e.g. {"name": "app.socket", "idle_timeout": "30m0s", ...}
+func (s SysdConfig) MarshalJSON() ([]byte, error) {
+	type plain SysdConfig
+	aux := struct {
+		plain
+		IdleTimeout string `json:"idle_timeout,omitempty"`
+	}{plain: plain(s)}
+	if s.IdleTimeout != nil {
+		aux.IdleTimeout = s.IdleTimeout.String()
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON accepts either an address string or an object. Missing fields are set to defaults.
+// idle_timeout can be a duration string or nanoseconds
+func (s *SysdConfig) UnmarshalJSON(data []byte) error {
+	if isJSONString(data) {
+		return unmarshalJSONText(data, s)
+	}
+	type plain SysdConfig
+	sysc := DefaultSysdConfig
+	aux := struct {
+		*plain
+		IdleTimeout json.RawMessage `json:"idle_timeout"`
+	}{plain: (*plain)(&sysc)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.IdleTimeout != nil && !bytes.Equal(aux.IdleTimeout, []byte("null")) {
+		timeout, err := unmarshalJSONDuration(aux.IdleTimeout)
+		if err != nil {
+			return fmt.Errorf("systemd socket fd config error. Bad idle_timeout: %s, err: %w", aux.IdleTimeout, err)
+		}
+		sysc.IdleTimeout = &timeout
+	}
+	if err := sysc.validate(); err != nil {
+		return err
+	}
+	*s = sysc
+	return nil
+}
+
+// UnmarshalYAML accepts either an address string or a mapping. Missing fields are set to defaults
+func (s *SysdConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	var addr string
+	if unmarshal(&addr) == nil {
+		return s.UnmarshalText([]byte(addr))
+	}
+	type plain SysdConfig
+	sysc := DefaultSysdConfig
+	if err := unmarshal((*plain)(&sysc)); err != nil {
+		return err
+	}
+	if err := sysc.validate(); err != nil {
+		return err
+	}
+	*s = sysc
+	return nil
+}
+
+func (s *SysdConfig) validate() error {
+	if s.All {
+		if s.FDIndex != nil || s.FDName != nil || s.Accepted {
+			return fmt.Errorf("systemd socket fd config error. name, idx and accepted can not be set with all. name: %v, idx: %v", s.FDName, s.FDIndex)
+		}
+		return nil
+	}
+	if s.FDIndex != nil && s.FDName != nil || s.FDIndex == nil && s.FDName == nil && !s.Accepted {
+		return fmt.Errorf("systemd socket fd config error. Exactly only one of name and idx has to be set. name: %v, idx: %v", s.FDName, s.FDIndex)
+	}
+	return nil
+}
+
+func noCommonOptions(a *address) error {
+	if len(a.common) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(a.common))
+	for key := range a.common {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("options not supported here, use ListenerConfig: %v", strings.Join(keys, ", "))
+}
+
+func isJSONString(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '"'
+}
+
+// unmarshalJSONDuration accepts a duration string or nanoseconds
+func unmarshalJSONDuration(data []byte) (time.Duration, error) {
+	var d time.Duration
+	if !isJSONString(data) {
+		err := json.Unmarshal(data, &d)
+		return d, err
+	}
+	var ds string
+	if err := json.Unmarshal(data, &ds); err != nil {
+		return 0, err
+	}
+	return time.ParseDuration(ds)
+}
+
+func unmarshalJSONText(data []byte, tu interface{ UnmarshalText([]byte) error }) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	return tu.UnmarshalText([]byte(text))
+}
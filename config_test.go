@@ -0,0 +1,188 @@
+package anyhttp
+
+import (
+	"encoding/json"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListenerConfigJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		wantAddr string
+		wantTLS  *TLSConfig
+		wantErr  bool
+	}{
+		{
+			name:     "address string",
+			json:     `"unix?path=/run/app.sock&mode=600&rcvbuf=65536"`,
+			wantAddr: "unix?path=/run/app.sock&mode=600&remove_existing=true&rcvbuf=65536",
+		},
+		{
+			name:     "tcp address string",
+			json:     `":8080?nodelay=false"`,
+			wantAddr: ":8080?nodelay=false",
+		},
+		{
+			name:     "unix object",
+			json:     `{"unix": {"path": "/run/app.sock", "mode": "660"}, "tls": {"cert": "app.pem", "key": "app.key"}}`,
+			wantAddr: "unix?path=/run/app.sock&mode=660&remove_existing=true",
+			wantTLS:  &TLSConfig{CertFile: "app.pem", KeyFile: "app.key"},
+		},
+		{
+			name:    "unix object with numeric mode",
+			json:    `{"unix": {"path": "/run/app.sock", "mode": 660}}`,
+			wantErr: true,
+		},
+		{
+			name:     "unix config as string",
+			json:     `{"unix": "unix?path=/run/app.sock"}`,
+			wantAddr: "unix?path=/run/app.sock&mode=666&remove_existing=true",
+		},
+		{
+			name:     "sysd object",
+			json:     `{"sysd": {"name": "app.socket", "idle_timeout": "30m"}, "options": {"max_conns": "100"}}`,
+			wantAddr: "sysd?name=app.socket&check_pid=true&unset_env=true&idle_timeout=30m0s&max_conns=100",
+		},
+		{
+			name:     "tcp object",
+			json:     `{"tcp": {"addr": "127.0.0.1:8080"}}`,
+			wantAddr: "127.0.0.1:8080",
+		},
+		{
+			name:    "sysd object with both idx and name",
+			json:    `{"sysd": {"name": "app.socket", "idx": 0}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unix config with options",
+			json:    `{"unix": "unix?path=/run/app.sock&rcvbuf=1"}`,
+			wantErr: true,
+		},
+		{
+			name:    "multiple listeners",
+			json:    `{"unix": {"path": "/run/app.sock"}, "tcp": {"addr": ":8080"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "bad option",
+			json:    `{"tcp": {"addr": ":8080"}, "options": {"foo": "bar"}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lc ListenerConfig
+			err := json.Unmarshal([]byte(tt.json), &lc)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("json.Unmarshal() failed: %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("json.Unmarshal() succeeded unexpectedly")
+			}
+			if got := lc.String(); got != tt.wantAddr {
+				t.Errorf("String() = %q, want %q", got, tt.wantAddr)
+			}
+			if !check(lc.TLS, tt.wantTLS) {
+				t.Errorf("TLS = %v, want %v", lc.TLS, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestConfigJSONRoundTrip(t *testing.T) {
+	sysc := NewSysDConfigWithFDName("app.socket")
+	sysc.IdleTimeout = ptr(10 * time.Minute)
+	lc := ListenerConfig{
+		Sysd:    &sysc,
+		TLS:     &TLSConfig{CertFile: "app.pem", KeyFile: "app.key"},
+		Options: map[string]string{"rcvbuf": "65536"},
+	}
+	data, err := json.Marshal(lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"sysd":{"name":"app.socket","check_pid":true,"unset_env":true,"idle_timeout":"10m0s"},"tls":{"cert":"app.pem","key":"app.key"},"options":{"rcvbuf":"65536"}}`
+	if string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+	var got ListenerConfig
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != lc.String() || *got.TLS != *lc.TLS {
+		t.Errorf("round trip failed, got %v, want %v", got, lc)
+	}
+
+	usc := NewUnixSocketConfig("/run/app.sock")
+	data, err = json.Marshal(usc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"path":"/run/app.sock","remove_existing":true,"mode":"666"}`; string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestListenerConfigYAML(t *testing.T) {
+	// Emulates yaml decoders calling UnmarshalYAML for a scalar and a mapping
+	var lc ListenerConfig
+	scalar := func(v any) error {
+		if s, ok := v.(*string); ok {
+			*s = "sysd?idx=0"
+			return nil
+		}
+		return &json.UnsupportedValueError{}
+	}
+	if err := lc.UnmarshalYAML(scalar); err != nil {
+		t.Fatal(err)
+	}
+	if lc.Sysd == nil || *lc.Sysd.FDIndex != 0 {
+		t.Errorf("UnmarshalYAML() scalar, got %v", lc)
+	}
+
+	var usc UnixSocketConfig
+	mapping := func(v any) error {
+		return json.Unmarshal([]byte(`{"path": "/run/app.sock"}`), v)
+	}
+	if err := usc.UnmarshalYAML(mapping); err != nil {
+		t.Fatal(err)
+	}
+	if usc != NewUnixSocketConfig("/run/app.sock") {
+		t.Errorf("UnmarshalYAML() mapping should apply defaults, got %v", usc)
+	}
+
+	for _, tt := range []struct {
+		mode    any
+		want    fs.FileMode
+		wantErr bool
+	}{
+		{mode: "660", want: 0660},
+		{mode: 660, wantErr: true},
+		{mode: "rw", wantErr: true},
+	} {
+		// Fills only the mode field, as a yaml decoder would with the field tagged yaml:"-"
+		modeMapping := func(v any) error {
+			if rv := reflect.ValueOf(v).Elem(); rv.Kind() == reflect.Struct {
+				if f := rv.FieldByName("Mode"); f.IsValid() && f.Kind() == reflect.Interface {
+					f.Set(reflect.ValueOf(tt.mode))
+					return nil
+				}
+			}
+			return mapping(v)
+		}
+		var usc UnixSocketConfig
+		err := usc.UnmarshalYAML(modeMapping)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalYAML() mode %v, err: %v, wantErr: %v", tt.mode, err, tt.wantErr)
+		} else if err == nil && usc.SocketMode != tt.want {
+			t.Errorf("UnmarshalYAML() mode %v, got %o, want %o", tt.mode, usc.SocketMode, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package anyhttp
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// connTracker keeps track of open connections of the http server using http.Server.ConnState
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]*connInfo
+
+	// Oldest idle connections are closed when open connections reach maxConns. 0 disables
+	maxConns int
+	// Connections are closed after this age, once the request in progress finishes. 0 disables
+	maxAge time.Duration
+	// When set, hijacked connections are tracked until closed, and shutdown waits for them upto this duration. 0 disables
+	hijackTimeout time.Duration
+	hijacked      map[net.Conn]time.Time
+	logf          func(format string, args ...any)
+}
+
+type connInfo struct {
+	state http.ConnState
+	// time of last state change
+	since time.Time
+	// closes the connection after maxAge
+	ageTimer *time.Timer
+	// maxAge reached while a request was in progress, closed when it goes idle
+	expired bool
+}
+
+func newConnTracker(maxConns int, logf func(format string, args ...any)) *connTracker {
+	return &connTracker{
+		conns:    map[net.Conn]*connInfo{},
+		hijacked: map[net.Conn]time.Time{},
+		maxConns: maxConns,
+		logf:     logf,
+	}
+}
+
+func (t *connTracker) connState(c net.Conn, state http.ConnState) {
+	var reap []net.Conn
+	t.mu.Lock()
+	switch state {
+	case http.StateNew, http.StateActive, http.StateIdle:
+		ci, ok := t.conns[c]
+		if !ok {
+			ci = &connInfo{}
+			t.conns[c] = ci
+			if t.maxAge > 0 {
+				ci.ageTimer = time.AfterFunc(t.maxAge, func() { t.expire(c) })
+			}
+		}
+		ci.state = state
+		ci.since = time.Now()
+		if state == http.StateNew && t.maxConns > 0 && len(t.conns) >= t.maxConns {
+			reap = t.oldestIdle(len(t.conns) - t.maxConns + 1)
+		}
+		if state == http.StateIdle && ci.expired {
+			reap = append(reap, c)
+		}
+	case http.StateHijacked:
+		// ageTimer is left running, so that leaked hijacked connections are closed too
+		delete(t.conns, c)
+		if t.hijackTimeout > 0 {
+			t.hijacked[netConn(c)] = time.Now()
+		}
+	case http.StateClosed:
+		if ci, ok := t.conns[c]; ok && ci.ageTimer != nil {
+			ci.ageTimer.Stop()
+		}
+		delete(t.conns, c)
+	}
+	t.mu.Unlock()
+
+	// Closed outside the lock; the server reports StateClosed for these from their own goroutines
+	for _, rc := range reap {
+		_ = rc.Close()
+	}
+	if len(reap) > 0 {
+		t.logf("anyhttp: open connections reached max_conns: %v, closed %v idle connections", t.maxConns, len(reap))
+	}
+}
+
+// wrap returns a listener whose connections report Close to the tracker. Needed only to track hijacked connections
+func (t *connTracker) wrap(l net.Listener) net.Listener {
+	if t.hijackTimeout <= 0 {
+		return l
+	}
+	return &trackedListener{Listener: l, tracker: t}
+}
+
+// waitHijacked waits for hijacked connections to be closed upto hijackTimeout and closes the remaining.
+// http.Server.Shutdown does not wait for them
+func (t *connTracker) waitHijacked() {
+	if t.hijackTimeout <= 0 {
+		return
+	}
+	deadline := time.Now().Add(t.hijackTimeout)
+	for interval := 10 * time.Millisecond; ; {
+		t.mu.Lock()
+		n := len(t.hijacked)
+		t.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(interval)
+		if interval < 500*time.Millisecond {
+			interval *= 2
+		}
+	}
+	n := t.closeHijacked()
+	t.logf("anyhttp: hijack_timeout %v exceeded, closed %v hijacked connections", t.hijackTimeout, n)
+}
+
+// closeHijacked closes the hijacked connections not closed yet, returns how many
+func (t *connTracker) closeHijacked() int {
+	t.mu.Lock()
+	remaining := make([]net.Conn, 0, len(t.hijacked))
+	for c := range t.hijacked {
+		remaining = append(remaining, c)
+	}
+	t.mu.Unlock()
+	for _, c := range remaining {
+		_ = c.Close()
+	}
+	return len(remaining)
+}
+
+func (t *connTracker) closed(c net.Conn) {
+	t.mu.Lock()
+	delete(t.hijacked, c)
+	t.mu.Unlock()
+}
+
+// netConn returns the connection returned by the tracked listener, unwrapping tls and other wrappers
+func netConn(c net.Conn) net.Conn {
+	for {
+		if _, ok := c.(*trackedConn); ok {
+			return c
+		}
+		nc, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			return c
+		}
+		c = nc.NetConn()
+	}
+}
+
+type trackedListener struct {
+	net.Listener
+	tracker *connTracker
+}
+
+func (l *trackedListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &trackedConn{Conn: c, tracker: l.tracker}, nil
+}
+
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+}
+
+func (c *trackedConn) Close() error {
+	c.tracker.closed(c)
+	return c.Conn.Close()
+}
+
+// NetConn returns the accepted connection, e.g. to get *net.TCPConn after hijacking
+func (c *trackedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// expire closes the connection once it reaches maxAge. Connections with a request in progress are closed when the request finishes
+func (t *connTracker) expire(c net.Conn) {
+	t.mu.Lock()
+	ci, ok := t.conns[c]
+	if ok && ci.state == http.StateActive {
+		ci.expired = true
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+	// New, idle or hijacked
+	_ = c.Close()
+}
+
+// oldestIdle returns upto n idle connections, oldest first. Must be called with mu held
+func (t *connTracker) oldestIdle(n int) []net.Conn {
+	var idle []net.Conn
+	for c, ci := range t.conns {
+		if ci.state == http.StateIdle {
+			idle = append(idle, c)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return t.conns[idle[i]].since.Before(t.conns[idle[j]].since)
+	})
+	if len(idle) > n {
+		idle = idle[:n]
+	}
+	return idle
+}
+
+// progress returns the connection counts and the age of the oldest active request at now
+func (t *connTracker) progress(now time.Time) DrainProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := DrainProgress{Conns: len(t.conns), Hijacked: len(t.hijacked)}
+	for _, ci := range t.conns {
+		if ci.state != http.StateActive {
+			continue
+		}
+		p.Active++
+		if age := now.Sub(ci.since); age > p.OldestRequest {
+			p.OldestRequest = age
+		}
+	}
+	return p
+}
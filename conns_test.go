@@ -0,0 +1,162 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type closeRecorder struct {
+	net.Conn
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestConnTrackerReap(t *testing.T) {
+	tracker := newConnTracker(2, t.Logf)
+	c1, c2, c3 := &closeRecorder{}, &closeRecorder{}, &closeRecorder{}
+
+	tracker.connState(c1, http.StateNew)
+	tracker.connState(c1, http.StateIdle)
+	tracker.connState(c2, http.StateNew)
+	tracker.connState(c2, http.StateActive)
+	if !c1.closed {
+		t.Fatal("oldest idle connection should be closed when max_conns is reached")
+	}
+	tracker.connState(c1, http.StateClosed)
+
+	tracker.connState(c3, http.StateNew)
+	if c2.closed {
+		t.Error("active connection should not be closed")
+	}
+	if got := len(tracker.conns); got != 2 {
+		t.Errorf("open connections = %v, want 2", got)
+	}
+}
+
+func TestMaxConnsAuto(t *testing.T) {
+	a, err := parseAddr(":8080?max_conns=auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.srvOpts.maxConns <= 0 {
+		t.Errorf("max_conns=auto should derive limit from RLIMIT_NOFILE, got: %v", a.srvOpts.maxConns)
+	}
+}
+
+func TestConnTrackerMaxAge(t *testing.T) {
+	tracker := newConnTracker(0, t.Logf)
+	tracker.maxAge = time.Hour
+	idle, active, hijacked := &closeRecorder{}, &closeRecorder{}, &closeRecorder{}
+	for _, c := range []net.Conn{idle, active, hijacked} {
+		tracker.connState(c, http.StateNew)
+	}
+	tracker.connState(idle, http.StateIdle)
+	tracker.connState(active, http.StateActive)
+	tracker.connState(hijacked, http.StateActive)
+	tracker.connState(hijacked, http.StateHijacked)
+
+	for _, c := range []net.Conn{idle, active, hijacked} {
+		tracker.expire(c)
+	}
+	if !idle.closed || !hijacked.closed {
+		t.Errorf("idle and hijacked connections should be closed at max_conn_age, idle: %v, hijacked: %v", idle.closed, hijacked.closed)
+	}
+	if active.closed {
+		t.Fatal("active connection should be closed only after the request finishes")
+	}
+	tracker.connState(active, http.StateIdle)
+	if !active.closed {
+		t.Error("expired connection should be closed when it goes idle")
+	}
+	for _, ci := range tracker.conns {
+		ci.ageTimer.Stop()
+	}
+}
+
+func TestHijackTimeout(t *testing.T) {
+	hijacked := make(chan struct{})
+	ctx, err := Serve("127.0.0.1:0?hijack_timeout=50ms", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = c.Write([]byte("hijacked"))
+		close(hijacked)
+		// Leaked, never closed
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.Dial("tcp", ctx.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	<-hijacked
+	if p := ctx.conns.progress(time.Now()); p.Hijacked != 1 {
+		t.Errorf("hijacked = %v, want 1", p.Hijacked)
+	}
+
+	if err := ctx.Shutdown(context.Background()); !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("Shutdown() = %v, want %v", err, http.ErrServerClosed)
+	}
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(c); err != nil {
+		t.Errorf("hijacked connection should be closed after hijack_timeout, err: %v", err)
+	}
+}
+
+func TestShutdownOrClose(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	ctx, err := Serve("127.0.0.1:0?hijack_timeout=1h", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+				t.Error(err)
+			}
+			started <- struct{}{}
+			return
+		}
+		started <- struct{}{}
+		<-release
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/ws", "/stuck"} {
+		c, err := net.Dial("tcp", ctx.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := c.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		<-started
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = ctx.ShutdownOrClose(shutdownCtx)
+	var closed *ForceClosedError
+	if !errors.As(err, &closed) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ShutdownOrClose() = %v, want ForceClosedError", err)
+	}
+	if closed.Conns != 1 || closed.Hijacked != 1 {
+		t.Errorf("got %+v, want 1 connection and 1 hijacked", closed)
+	}
+}
@@ -0,0 +1,290 @@
+// Package devca manages a local certificate authority to test https during development.
+// The CA is created once and reused, so it has to be trusted only once. Host certificates are minted on demand.
+//
+//	ca, err := devca.Default()
+//	...
+//	ca.PrintTrustInstructions(os.Stderr)
+//	certFile, keyFile, err := ca.CertFiles("localhost", "127.0.0.1")
+//	...
+//	anyhttp.ListenAndServeTLS(":8443", certFile, keyFile, nil)
+//
+// Never use in production, the CA key is stored unencrypted
+package devca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	caCertName = "ca.pem"
+	caKeyName  = "ca-key.pem"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// Below the 825 days limit of Apple platforms
+	hostValidity = 365 * 24 * time.Hour
+)
+
+// CA is a local certificate authority
+type CA struct {
+	dir  string
+	cert *x509.Certificate
+	key  crypto.Signer
+
+	mu    sync.Mutex
+	certs map[string]*tls.Certificate
+}
+
+// Default loads the CA from anyhttp/devca in the user's config dir, e.g. ~/.config/anyhttp/devca, creating it if needed
+func Default() (*CA, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return Load(filepath.Join(configDir, "anyhttp", "devca"))
+}
+
+// Load loads the CA from dir, creating it if needed
+func Load(dir string) (*CA, error) {
+	ca := &CA{dir: dir, certs: map[string]*tls.Certificate{}}
+	certPEM, err := os.ReadFile(ca.CertFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := ca.create(); err != nil {
+			return nil, fmt.Errorf("devca: failed to create CA in %v, err: %w", dir, err)
+		}
+		return ca, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, caKeyName))
+	if err != nil {
+		return nil, err
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("devca: bad CA in %v, err: %w", dir, err)
+	}
+	if ca.cert, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		return nil, err
+	}
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("devca: unsupported CA key type: %T", pair.PrivateKey)
+	}
+	ca.key = signer
+	return ca, nil
+}
+
+func (ca *CA) create() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"anyhttp development CA"}, CommonName: "anyhttp devca " + owner()},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	if ca.cert, err = x509.ParseCertificate(der); err != nil {
+		return err
+	}
+	ca.key = key
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ca.dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(ca.dir, caKeyName), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(ca.CertFile(), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// CertFile returns the path of the CA certificate, to be trusted by browsers and clients
+func (ca *CA) CertFile() string {
+	return filepath.Join(ca.dir, caCertName)
+}
+
+// Certificate returns the CA certificate
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// Mint creates a certificate valid for hosts. Hosts can be domain names, wildcards like *.example.test, or IP addresses
+func (ca *CA) Mint(hosts ...string) (*tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("devca: no hosts to mint certificate")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"anyhttp development certificate"}, CommonName: hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(hostValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// GetCertificate mints certificates on demand for the requested server name. Use as tls.Config.GetCertificate.
+// When SNI is not sent, e.g. when connecting using IP address, the local IP of the connection is used
+func (ca *CA) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := hello.ServerName
+	if host == "" {
+		host = "localhost"
+		if hello.Conn != nil {
+			if addr, ok := hello.Conn.LocalAddr().(*net.TCPAddr); ok {
+				host = addr.IP.String()
+			}
+		}
+	}
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if cert, ok := ca.certs[host]; ok && time.Now().Before(cert.Leaf.NotAfter) {
+		return cert, nil
+	}
+	cert, err := ca.Mint(host)
+	if err != nil {
+		return nil, err
+	}
+	ca.certs[host] = cert
+	return cert, nil
+}
+
+// TLSConfig returns a tls.Config that mints certificates on demand
+func (ca *CA) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: ca.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// CertFiles mints a certificate for hosts and writes it under the CA dir. The returned files can be passed to anyhttp.ServeTLS
+func (ca *CA) CertFiles(hosts ...string) (certFile, keyFile string, err error) {
+	cert, err := ca.Mint(hosts...)
+	if err != nil {
+		return "", "", err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return "", "", err
+	}
+	var certPEM []byte
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	hostsDir := filepath.Join(ca.dir, "hosts")
+	if err := os.MkdirAll(hostsDir, 0700); err != nil {
+		return "", "", err
+	}
+	name := strings.NewReplacer("*", "_wildcard", ":", "_", "/", "_").Replace(hosts[0])
+	certFile = filepath.Join(hostsDir, name+".pem")
+	keyFile = filepath.Join(hostsDir, name+"-key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// PrintTrustInstructions writes the commands to trust the CA on the current platform
+func (ca *CA) PrintTrustInstructions(w io.Writer) {
+	certFile := ca.CertFile()
+	fmt.Fprintf(w, "anyhttp development CA certificate: %v\n", certFile)
+	fmt.Fprintln(w, "To trust it, run:")
+	switch runtime.GOOS {
+	case "darwin":
+		fmt.Fprintf(w, "  sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain %q\n", certFile)
+	case "windows":
+		fmt.Fprintf(w, "  certutil -addstore -f ROOT %q\n", certFile)
+	default:
+		fmt.Fprintln(w, "  # Debian, Ubuntu")
+		fmt.Fprintf(w, "  sudo cp %q /usr/local/share/ca-certificates/anyhttp-devca.crt && sudo update-ca-certificates\n", certFile)
+		fmt.Fprintln(w, "  # Fedora, RHEL, Arch")
+		fmt.Fprintf(w, "  sudo trust anchor --store %q\n", certFile)
+	}
+	fmt.Fprintln(w, "Firefox uses its own store: Settings > Privacy & Security > Certificates > View Certificates > Authorities > Import")
+	fmt.Fprintf(w, "Or pass it to individual clients, e.g. curl --cacert %q\n", certFile)
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func owner() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+package devca
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.balki.me/anyhttp"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Certificate().Equal(ca.Certificate()) {
+		t.Error("Load() should reuse existing CA")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate())
+	cert, err := reloaded.GetCertificate(&tls.ClientHelloInfo{ServerName: "app.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "app.test", Roots: pool}); err != nil {
+		t.Errorf("minted certificate should be verified by CA, err: %v", err)
+	}
+	again, _ := reloaded.GetCertificate(&tls.ClientHelloInfo{ServerName: "app.test"})
+	if again != cert {
+		t.Error("GetCertificate() should cache certificates")
+	}
+
+	var sb strings.Builder
+	ca.PrintTrustInstructions(&sb)
+	if !strings.Contains(sb.String(), ca.CertFile()) {
+		t.Errorf("instructions should contain CA cert file, got: %v", sb.String())
+	}
+}
+
+func TestCertFiles(t *testing.T) {
+	ca, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile, err := ca.CertFiles("localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := anyhttp.ServeTLS("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure"))
+	}), certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "secure" {
+		t.Errorf("got %q, want %q", body, "secure")
+	}
+}
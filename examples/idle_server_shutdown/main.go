@@ -9,7 +9,7 @@ import (
 )
 
 func main() {
-	idler := idle.CreateIdler(10 * time.Second)
+	idler := idle.CreateIdler(10 * time.Second).(idle.Controller)
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		idler.Tick()
@@ -17,12 +17,12 @@ func main() {
 	})
 
 	http.HandleFunc("/job", func(w http.ResponseWriter, r *http.Request) {
-		go func() {
-			idler.Enter()
-			defer idler.Exit()
-
-			time.Sleep(15 * time.Second)
-		}()
+		idler.Go(func(ctx context.Context) {
+			select {
+			case <-time.After(15 * time.Second):
+			case <-ctx.Done():
+			}
+		})
 		w.Write([]byte("Job scheduled\n"))
 	})
 
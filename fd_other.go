@@ -0,0 +1,31 @@
+//go:build !unix
+
+package anyhttp
+
+import (
+	"errors"
+	"net"
+)
+
+// No inherited fds to worry about, socket activation is not supported
+func closeOnExec(fd int) {}
+
+func fdLimit() (uint64, error) {
+	return 0, errors.New("open files limit is not supported on this platform")
+}
+
+func setBacklog(fd uintptr, backlog int) error {
+	return errors.New("backlog is not supported on this platform")
+}
+
+func isDatagram(fd int) bool {
+	return false
+}
+
+func makeFdConnListener(fd int, name string) (net.Listener, error) {
+	return nil, errors.New("accepted connections are not supported on this platform")
+}
+
+func dupFD(fd int) (int, error) {
+	return 0, errors.New("passing fds is not supported on this platform")
+}
@@ -0,0 +1,73 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
+
+// dupConnFD returns a close-on-exec dup of the socket fd of sc. Not using File() as Fd() of it sets the shared
+// socket to blocking mode, which breaks Accept of the current server
+func dupConnFD(sc syscall.Conn) (int, error) {
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	fd, derr := -1, error(nil)
+	if err := rc.Control(func(sfd uintptr) {
+		fd, derr = dupFD(int(sfd))
+	}); err != nil {
+		return 0, err
+	}
+	return fd, derr
+}
+
+// fdLimit returns the soft limit of open file descriptors
+func fdLimit() (uint64, error) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, err
+	}
+	return uint64(rlimit.Cur), nil
+}
+
+// setBacklog calls listen again on the listening socket fd to change its backlog
+func setBacklog(fd uintptr, backlog int) error {
+	return syscall.Listen(int(fd), backlog)
+}
+
+// isDatagram checks if fd is a SOCK_DGRAM socket. False if it can not be checked
+func isDatagram(fd int) bool {
+	sotype, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_TYPE)
+	return err == nil && sotype == syscall.SOCK_DGRAM
+}
+
+// makeFdConnListener returns a listener that returns the connection fd once, e.g. from a systemd socket with Accept=yes
+func makeFdConnListener(fd int, name string) (net.Listener, error) {
+	fdFile := os.NewFile(uintptr(fd), name)
+	c, err := net.FileConn(fdFile)
+	if err != nil {
+		return nil, err
+	}
+	// FileConn dups the fd
+	fdFile.Close()
+	return newSingleConnListener(c), nil
+}
+
+// dupFD returns a copy of fd with close-on-exec set
+func dupFD(fd int) (int, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	nfd, err := syscall.Dup(fd)
+	if err != nil {
+		return 0, err
+	}
+	syscall.CloseOnExec(nfd)
+	return nfd, nil
+}
@@ -0,0 +1,40 @@
+package anyhttp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// storedListener returns the listener for the fd passed back by systemd from its fd store with name. Returns nil listener
+// if there is no such fd, e.g. on first start
+func storedListener(name string) (net.Listener, error) {
+	envData, err := parse()
+	if err != nil || envData.pid != os.Getpid() {
+		return nil, nil
+	}
+	for idx := 0; idx < envData.numFds && idx < len(envData.fdNames); idx++ {
+		if envData.fdNames[idx] == name {
+			l, err := makeFdListener(StartFD+idx, name)
+			if err != nil {
+				return nil, fmt.Errorf("fd_store error. Bad stored fd %v, name: %q, err: %w", StartFD+idx, name, err)
+			}
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// validFDName checks the name can be used as FDNAME, i.e. upto 255 printable ASCII characters without colons
+func validFDName(name string) bool {
+	if name == "" || len(name) > 255 || strings.Contains(name, ":") {
+		return false
+	}
+	for _, r := range name {
+		if r < ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package anyhttp
+
+import (
+	"errors"
+	"net"
+)
+
+func storeListener(name string, l net.Listener) error {
+	return errors.New("fd_store error. Not supported on this platform")
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// storeListener sends the listening socket to the systemd fd store with name, so that it is passed back after a restart
+// and connections waiting in the backlog are not dropped. No-op when not run by systemd
+func storeListener(name string, l net.Listener) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	l = unwrapListener(l)
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("fd_store error. Listener %T can not be stored", l)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("fd_store error. %w", err)
+	}
+	// Plain sendmsg, net.UnixConn does not send fds on connected datagram sockets. Abstract names starting with @ are handled
+	sock, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return fmt.Errorf("fd_store error. %w", err)
+	}
+	defer syscall.Close(sock)
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		serr = syscall.Sendmsg(sock, []byte("FDSTORE=1\nFDNAME="+name), syscall.UnixRights(int(fd)), &syscall.SockaddrUnix{Name: path}, 0)
+	}); err != nil {
+		return fmt.Errorf("fd_store error. %w", err)
+	}
+	if serr != nil {
+		return fmt.Errorf("fd_store error. Failed to send fd to %v, err: %w", path, serr)
+	}
+	// Socket file has to stay for the stored fd to be useful after restart
+	if ul, ok := l.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	return nil
+}
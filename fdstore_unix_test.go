@@ -0,0 +1,77 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// resetSysdEnv makes parse read the LISTEN* environment variables again
+func resetSysdEnv(t *testing.T) {
+	sysdEnvParser.sysdOnce = sync.Once{}
+	t.Cleanup(func() { sysdEnvParser.sysdOnce = sync.Once{} })
+}
+
+func TestFDStore(t *testing.T) {
+	conn := notifySocket(t)
+	l, _, _, err := GetListener("127.0.0.1:0?fd_store=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 256)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "FDSTORE=1\nFDNAME=web" {
+		t.Errorf("got %q", got)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("msgs: %v, err: %v", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("fds: %v, err: %v", fds, err)
+	}
+
+	// After restart, systemd passes the stored fd with its name. Owned by the stored listener once taken
+	resetSysdEnv(t)
+	idx := fds[0] - StartFD
+	names := make([]string, idx+1)
+	names[idx] = "web"
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", strconv.Itoa(idx+1))
+	t.Setenv("LISTEN_FDNAMES", strings.Join(names, ":"))
+	stored, _, _, err := GetListener("127.0.0.1:0?fd_store=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stored.Close()
+	if stored.Addr().String() != l.Addr().String() {
+		t.Errorf("got %v, want the stored listener on %v", stored.Addr(), l.Addr())
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := conn.Read(buf); err == nil {
+		t.Error("stored listener should not be stored again")
+	}
+}
+
+func TestFDStoreErrors(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:0?fd_store=", "127.0.0.1:0?fd_store=a:b", "sysd?name=web&fd_store=web", "127.0.0.1:0?fd_store=web&lock=true"} {
+		if l, _, _, err := GetListener(addr); err == nil {
+			l.Close()
+			t.Errorf("GetListener(%q) should fail", addr)
+		}
+	}
+}
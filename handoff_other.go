@@ -0,0 +1,18 @@
+//go:build !unix
+
+package anyhttp
+
+import (
+	"errors"
+	"net"
+)
+
+var errHandoffUnsupported = errors.New("handoff error. Not supported on this platform")
+
+func takeover(path string) (net.Listener, error) {
+	return nil, errHandoffUnsupported
+}
+
+func (s *ServerCtx) serveHandoff(path string, raw net.Listener) error {
+	return errHandoffUnsupported
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHandoff(t *testing.T) {
+	handoffPath := filepath.Join(t.TempDir(), "handoff.sock")
+	serveName := func(name string) (*ServerCtx, error) {
+		return Serve("127.0.0.1:0?handoff="+handoffPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}))
+	}
+	oldCtx, err := serveName("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + oldCtx.Addr().String()
+	if _, body := get(t, url); body != "old" {
+		t.Fatalf("got %q, want old", body)
+	}
+
+	newCtx, err := serveName("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newCtx.Server.Close()
+	if newCtx.Addr().String() != oldCtx.Addr().String() {
+		t.Errorf("new instance should listen on %v, got: %v", oldCtx.Addr(), newCtx.Addr())
+	}
+	select {
+	case err := <-oldCtx.Done:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("old Done = %v, want %v", err, http.ErrServerClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("old instance should drain after handoff")
+	}
+	http.DefaultClient.CloseIdleConnections()
+	if _, body := get(t, url); body != "new" {
+		t.Errorf("got %q, want new", body)
+	}
+}
@@ -0,0 +1,116 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+)
+
+// handoff messages
+const (
+	handoffFD  = 'L'
+	handoffAck = 'K'
+)
+
+// takeover receives the listening socket from the instance serving the handoff socket at path.
+// Returns nil listener if there is no such instance
+func takeover(path string) (net.Listener, error) {
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("handoff error. Failed to connect to %v, err: %w", path, err)
+	}
+	defer c.Close()
+	uc := c.(*net.UnixConn)
+
+	buf := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := uc.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, fmt.Errorf("handoff error. Failed to receive listener, err: %w", err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 || buf[0] != handoffFD {
+		return nil, fmt.Errorf("handoff error. Unexpected message from %v, err: %v", path, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		return nil, fmt.Errorf("handoff error. Unexpected fds from %v, err: %v", path, err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "handoff")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("handoff error. Bad listener fd, err: %w", err)
+	}
+	// Old instance starts draining after this
+	if _, err := uc.Write([]byte{handoffAck}); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("handoff error. Failed to acknowledge, err: %w", err)
+	}
+	return l, nil
+}
+
+// serveHandoff listens on the handoff socket at path. When a new instance connects, the listening socket is passed to it
+// and the server is drained
+func (s *ServerCtx) serveHandoff(path string, raw net.Listener) error {
+	sc, ok := unwrapListener(raw).(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("handoff error. Listener %T can not be handed off", raw)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("handoff error. Failed to remove %v, err: %w", path, err)
+	}
+	hl, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("handoff error. Failed to listen on %v, err: %w", path, err)
+	}
+	s.Server.RegisterOnShutdown(func() { hl.Close() })
+	go func() {
+		for {
+			c, err := hl.AcceptUnix()
+			if err != nil {
+				return
+			}
+			if err := handoff(c, sc); err != nil {
+				s.logf("anyhttp: %v", err)
+				continue
+			}
+			s.infof("anyhttp: listener handed off to new instance, draining")
+			// Socket file of the new instance should stay
+			hl.SetUnlinkOnClose(false)
+			if ul, ok := unwrapListener(raw).(*net.UnixListener); ok {
+				ul.SetUnlinkOnClose(false)
+			}
+			if err := s.drain(); err != nil {
+				s.logf("anyhttp: drain failed after handoff: %v", err)
+			}
+			return
+		}
+	}()
+	return nil
+}
+
+func handoff(c *net.UnixConn, sc syscall.Conn) error {
+	defer c.Close()
+	fd, err := dupConnFD(sc)
+	if err != nil {
+		return fmt.Errorf("handoff error. Failed to dup listener fd, err: %w", err)
+	}
+	defer syscall.Close(fd)
+	if _, _, err := c.WriteMsgUnix([]byte{handoffFD}, syscall.UnixRights(fd), nil); err != nil {
+		return fmt.Errorf("handoff error. Failed to send listener, err: %w", err)
+	}
+	ack := make([]byte, 1)
+	if _, err := c.Read(ack); err != nil || ack[0] != handoffAck {
+		return fmt.Errorf("handoff error. New instance did not take over, err: %v", err)
+	}
+	return nil
+}
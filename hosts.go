@@ -0,0 +1,93 @@
+package anyhttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Hosts routes requests by host name, for serving many sites on a single listener, e.g.
+//
+//	anyhttp.Serve("sysd?name=web.socket", anyhttp.Hosts{
+//		"a.example.com": anyhttp.HostTLS(hA, "a.pem", "a.key"),
+//		"*.b.example.com": hB,
+//		"*": fallback,
+//	})
+//
+// Keys are lowercase host names without port. Lookup is by exact name, then wildcard *.<parent domain>, then "*".
+// Unknown hosts get 404. With TLS, requests for a host different from the one in SNI get 421 Misdirected Request.
+// If a handler has certificates (see HostTLS), Serve serves https and selects the certificate by SNI
+type Hosts map[string]http.Handler
+
+// ServeHTTP implements http.Handler
+func (hs Hosts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, h := hs.lookup(r.Host)
+	if h == nil {
+		http.Error(w, "Unknown host", http.StatusNotFound)
+		return
+	}
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		if sniKey, _ := hs.lookup(r.TLS.ServerName); sniKey != key {
+			http.Error(w, "Host does not match TLS server name", http.StatusMisdirectedRequest)
+			return
+		}
+	}
+	h.ServeHTTP(w, r)
+}
+
+// lookup returns the matching key and its handler
+func (hs Hosts) lookup(host string) (string, http.Handler) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if h, ok := hs[host]; ok {
+		return host, h
+	}
+	if _, parent, ok := strings.Cut(host, "."); ok {
+		if h, ok := hs["*."+parent]; ok {
+			return "*." + parent, h
+		}
+	}
+	if h, ok := hs["*"]; ok {
+		return "*", h
+	}
+	return "", nil
+}
+
+// HostTLS attaches the certificate and key files to use for the host of h in Hosts
+func HostTLS(h http.Handler, certFile, keyFile string) http.Handler {
+	return &hostTLS{Handler: h, certFile: certFile, keyFile: keyFile}
+}
+
+type hostTLS struct {
+	http.Handler
+	certFile, keyFile string
+}
+
+// certificates loads the certificates of hosts created with HostTLS
+func (hs Hosts) certificates() (map[string]*tls.Certificate, error) {
+	certs := map[string]*tls.Certificate{}
+	for host, h := range hs {
+		ht, ok := h.(*hostTLS)
+		if !ok {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(ht.certFile, ht.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("hosts error. Bad certificate for %v, err: %w", host, err)
+		}
+		certs[strings.ToLower(host)] = &cert
+	}
+	return certs, nil
+}
+
+// getCertificate selects the certificate by SNI using the same lookup as requests. Returns nil if the host has no certificate
+func (hs Hosts) getCertificate(certs map[string]*tls.Certificate) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		key, _ := hs.lookup(hello.ServerName)
+		return certs[key], nil
+	}
+}
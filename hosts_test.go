@@ -0,0 +1,90 @@
+package anyhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.balki.me/anyhttp/devca"
+)
+
+func text(s string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(s))
+	})
+}
+
+func TestHostsRouting(t *testing.T) {
+	hosts := Hosts{
+		"a.test":   text("a"),
+		"*.b.test": text("b"),
+	}
+	for host, want := range map[string]int{"a.test": 200, "A.TEST:8080": 200, "x.b.test": 200, "b.test": 404, "c.test": 404} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		hosts.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("host %v: got %v, want %v", host, rec.Code, want)
+		}
+	}
+	hosts["*"] = text("default")
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "c.test"
+	hosts.ServeHTTP(rec, req)
+	if rec.Body.String() != "default" {
+		t.Errorf("got %q, want default", rec.Body.String())
+	}
+}
+
+func TestHostsTLS(t *testing.T) {
+	ca, err := devca.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	aCert, aKey, err := ca.CertFiles("a.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bCert, bKey, err := ca.CertFiles("b.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := Serve("127.0.0.1:0", Hosts{
+		"a.test": HostTLS(text("a"), aCert, aKey),
+		"b.test": HostTLS(text("b"), bCert, bKey),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	get := func(sni, host string) (int, string) {
+		t.Helper()
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: sni}}}
+		req, _ := http.NewRequest(http.MethodGet, "https://"+ctx.Addr().String(), nil)
+		req.Host = host
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if code, body := get("a.test", "a.test"); code != 200 || body != "a" {
+		t.Errorf("got %v %q, want 200 a", code, body)
+	}
+	if code, body := get("b.test", "b.test"); code != 200 || body != "b" {
+		t.Errorf("got %v %q, want 200 b", code, body)
+	}
+	if code, _ := get("a.test", "b.test"); code != http.StatusMisdirectedRequest {
+		t.Errorf("got %v, want 421", code)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build go1.24
+
+package anyhttp
+
+import "net/http"
+
+// configureHTTP2 sets h2c and the HTTP/2 settings on srv
+func (o *serverOptions) configureHTTP2(srv *http.Server) error {
+	if o.h2c {
+		// HTTP/2 with prior knowledge on plain connections, along with HTTP/1 and HTTP/2 over TLS
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = &protocols
+	}
+	if o.h2MaxStreams == 0 && o.h2MaxFrameSize == 0 && o.h2PingTimeout == 0 {
+		return nil
+	}
+	srv.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams: o.h2MaxStreams,
+		MaxReadFrameSize:     o.h2MaxFrameSize,
+		SendPingTimeout:      o.h2PingTimeout,
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !go1.24
+
+package anyhttp
+
+import (
+	"errors"
+	"net/http"
+)
+
+func (o *serverOptions) configureHTTP2(*http.Server) error {
+	switch {
+	case o.h2c:
+		return errors.New("Bad h2c: needs Go 1.24 or newer")
+	case o.h2MaxStreams != 0 || o.h2MaxFrameSize != 0 || o.h2PingTimeout != 0:
+		return errors.New("Bad h2_* options: needs Go 1.24 or newer")
+	}
+	return nil
+}
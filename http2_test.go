@@ -0,0 +1,109 @@
+//go:build go1.24
+
+package anyhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+func TestH2C(t *testing.T) {
+	proto := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	ctx, err := Serve("127.0.0.1:0?h2c=true", proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	resp, err := client.Get(ctx.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("got %v, want HTTP/2", resp.Proto)
+	}
+	// HTTP/1 still works
+	if _, got := get(t, ctx.URL()); got != "HTTP/1.1" {
+		t.Errorf("got %q, want HTTP/1.1", got)
+	}
+
+	if _, err := parseAddr("127.0.0.1:0?h2c=maybe"); err == nil {
+		t.Error("parseAddr should fail for bad h2c")
+	}
+}
+
+func TestDrainGoaway(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0?h2c=true", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	// reused reports whether the request was sent on an existing connection
+	reused := func() bool {
+		var info httptrace.GotConnInfo
+		trace := &httptrace.ClientTrace{GotConn: func(i httptrace.GotConnInfo) { info = i }}
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, ctx.URL(), nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+			t.Fatalf("got %v %v, want HTTP/2 200", resp.Proto, resp.StatusCode)
+		}
+		return info.Reused
+	}
+	reused()
+	if !reused() {
+		t.Fatal("connection not reused before drain")
+	}
+
+	ctx.draining.Store(true)
+	// GOAWAY races with the response, so a few more requests may still go on the old connection
+	for range 50 {
+		if !reused() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("client kept using the connection after drain, GOAWAY not sent")
+}
+
+func TestHTTP2Options(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0?h2_max_streams=10&h2_max_frame_size=32768&h2_ping_timeout=30s", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	h2 := ctx.Server.HTTP2
+	if h2 == nil || h2.MaxConcurrentStreams != 10 || h2.MaxReadFrameSize != 32768 || h2.SendPingTimeout != 30*time.Second {
+		t.Errorf("HTTP2 = %+v", h2)
+	}
+
+	for _, addr := range []string{
+		"127.0.0.1:0?h2_max_streams=0",
+		"127.0.0.1:0?h2_max_streams=-1",
+		"127.0.0.1:0?h2_max_frame_size=1024",
+		"127.0.0.1:0?h2_max_frame_size=16777216",
+		"127.0.0.1:0?h2_ping_timeout=0s",
+	} {
+		if _, err := parseAddr(addr); err == nil {
+			t.Errorf("parseAddr(%q) should fail", addr)
+		}
+	}
+}
@@ -2,8 +2,16 @@
 package idle
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -12,17 +20,94 @@ var (
 	// For simple servers without backgroud jobs, global singleton for simpler API
 	// Enter/Exit worn't work for global idler as Enter may be called before Wait, use CreateIdler in those cases
 	gIdler atomic.Pointer[idler]
+
+	registryMu sync.Mutex
+	registry   = map[string]Idler{}
+
+	// ErrReset is returned by Wait and WaitContext when Reset is called while waiting
+	ErrReset = errors.New("idler reset")
+
+	// ErrClosed is returned by Controller.WaitContext when the idler is closed before it is idle
+	ErrClosed = errors.New("idler closed")
 )
 
-// Wait waits till the server is idle and returns. i.e. no Ticks in last <timeout> duration
+// Register makes i available to other packages in the process as Get(name), e.g. "http" or "worker". Fails if name is
+// already registered
+func Register(name string, i Idler) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("idler %q already registered", name)
+	}
+	registry[name] = i
+	return nil
+}
+
+// Get returns the idler registered with name, nil if none
+func Get(name string) Idler {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return registry[name]
+}
+
+// Wait waits till the server is idle and returns. i.e. no Ticks in last <timeout> duration. Fails if called while
+// another Wait is waiting, can be called again once it returns
 func Wait(timeout time.Duration) error {
+	i, err := setGlobal(timeout)
+	if err != nil {
+		return err
+	}
+	return i.waitGlobal(context.Background())
+}
+
+// WaitContext is Wait, but returns ctx.Err() if ctx is done before the server is idle, e.g. on SIGTERM
+func WaitContext(ctx context.Context, timeout time.Duration) error {
+	i, err := setGlobal(timeout)
+	if err != nil {
+		return err
+	}
+	err = i.waitGlobal(ctx)
+	if err != nil {
+		// So that Wait can be called again
+		i.Close()
+	}
+	return err
+}
+
+// Reset stops the global idler, so that Wait can be called again, e.g. between tests or when a server is restarted. A
+// Wait in progress returns ErrReset
+func Reset() {
+	if i := gIdler.Swap(nil); i != nil {
+		i.Close()
+	}
+}
+
+// waitGlobal is WaitContext, but returns ErrReset once stopped by Reset
+func (i *idler) waitGlobal(ctx context.Context) error {
+	select {
+	case <-i.c:
+		if !i.idle.Load() {
+			return ErrReset
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setGlobal replaces the global idler with a new one, unless the current one is still waiting
+func setGlobal(timeout time.Duration) (*idler, error) {
 	i := CreateIdler(timeout).(*idler)
-	ok := gIdler.CompareAndSwap(nil, i)
-	if !ok {
-		return fmt.Errorf("idler already waiting")
+	for {
+		old := gIdler.Load()
+		if old != nil && !old.done() {
+			i.Close()
+			return nil, fmt.Errorf("idler already waiting")
+		}
+		if gIdler.CompareAndSwap(old, i) {
+			return i, nil
+		}
 	}
-	i.Wait()
-	return nil
 }
 
 // Tick records the current time. This will make the server not idle until next Tick or timeout
@@ -44,39 +129,362 @@ func WrapHandler(h http.Handler) http.Handler {
 	})
 }
 
-// WrapIdlerHandler calls idler.Tick() before processing passing request to http.Handler
+// WrapIdlerHandler calls idler.Tick() before processing passing request to http.Handler, and again once done, even if the handler panics
+// Hijacked connections, e.g. websockets, keep the server from being idle until they are closed
 func WrapIdlerHandler(i Idler, h http.Handler) http.Handler {
 	if h == nil {
 		h = http.DefaultServeMux
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		i.Tick()
+		defer i.Tick()
+		h.ServeHTTP(&idleWriter{ResponseWriter: w, i: i}, r)
+	})
+}
+
+// WrapConnState returns a http.Server.ConnState hook that keeps the server from being idle while connections are open,
+// e.g. keep-alive, long-poll and streaming connections even without new requests. Connections are not counted as
+// background jobs for ActiveJobs and WrapLoadShedHandler, for idlers from CreateIdler. Hijacked connections, e.g.
+// websockets, are not tracked after the hijack. next is called too, if not nil
+func WrapConnState(i Idler, next func(net.Conn, http.ConnState)) func(net.Conn, http.ConnState) {
+	enter, exit := connCounter(i)
+	return func(c net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			enter()
+		case http.StateHijacked, http.StateClosed:
+			exit()
+		}
+		if next != nil {
+			next(c, state)
+		}
+	}
+}
+
+// connCounter returns funcs to record a connection opening and closing. For idlers from CreateIdler, connections are
+// not counted as background jobs. Closing does not Tick, e.g. net/http closes idle keep-alive connections after
+// Server.IdleTimeout, which would restart the countdown. The last request on it already Ticked
+func connCounter(i Idler) (enter, exit func()) {
+	if ii, ok := i.(*idler); ok {
+		return func() { ii.conns.Add(1) }, func() {
+			if ii.conns.Add(-1) == 0 {
+				ii.wakeUp()
+			}
+		}
+	}
+	return i.Enter, i.Exit
+}
+
+// WrapListener Ticks on every Accept and keeps the server from being idle while accepted connections are open, for
+// non-http servers, e.g. smtp or grpc using anyhttp.GetListener. Accepted connections are wrapped, so type assertions
+// to e.g. *net.TCPConn do not work
+func WrapListener(l net.Listener, i Idler) net.Listener {
+	enter, exit := connCounter(i)
+	return &idleListener{Listener: l, i: i, enter: enter, exit: exit}
+}
+
+type idleListener struct {
+	net.Listener
+	i           Idler
+	enter, exit func()
+}
+
+func (l *idleListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.i.Tick()
+	l.enter()
+	return &idleConn{Conn: c, i: l.i, exit: l.exit}, nil
+}
+
+// idleConn records closing once, Close could be called again. Ticks on Close, as there are no requests to Tick on
+type idleConn struct {
+	net.Conn
+	i    Idler
+	exit func()
+	once sync.Once
+}
+
+func (c *idleConn) Close() error {
+	c.once.Do(func() {
+		c.i.Tick()
+		c.exit()
+	})
+	return c.Conn.Close()
+}
+
+// Except lists requests that do not Tick, e.g. health checks and metrics scrapes that would keep an otherwise idle
+// server running forever
+type Except struct {
+	// e.g. /healthz. Paths ending with / match all paths under it, e.g. /debug/
+	Paths []string
+	// e.g. HEAD or OPTIONS
+	Methods []string
+}
+
+func (e Except) match(r *http.Request) bool {
+	for _, m := range e.Methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	for _, p := range e.Paths {
+		if r.URL.Path == p || strings.HasSuffix(p, "/") && strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// WrapHandlerExcept is WrapHandler, but requests matching except do not Tick
+func WrapHandlerExcept(except Except, h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !except.match(r) {
+			Tick()
+		}
 		h.ServeHTTP(w, r)
 	})
 }
 
+// WrapIdlerHandlerExcept is WrapIdlerHandler, but requests matching except do not Tick
+func WrapIdlerHandlerExcept(i Idler, except Except, h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	wrapped := WrapIdlerHandler(i, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if except.match(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		wrapped.ServeHTTP(w, r)
+	})
+}
+
+// WrapIdlerResponseHandler is WrapIdlerHandler, but the ResponseWriter is wrapped to Tick on each Write and Flush too,
+// so that the idle countdown starts once the response is fully written, not when the request arrived. Useful for long
+// downloads that take longer than the timeout. Use http.NewResponseController to get the underlying ResponseWriter
+func WrapIdlerResponseHandler(i Idler, h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i.Tick()
+		defer i.Tick()
+		h.ServeHTTP(&idleWriter{ResponseWriter: w, i: i, tick: true}, r)
+	})
+}
+
+// idleWriter tracks hijacked connections until they are closed. With tick, it Ticks on each Write, Flush and ReadFrom
+// too
+type idleWriter struct {
+	http.ResponseWriter
+	i    Idler
+	tick bool
+}
+
+func (w *idleWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	if w.tick {
+		w.i.Tick()
+	}
+	return n, err
+}
+
+func (w *idleWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+	if w.tick {
+		w.i.Tick()
+	}
+}
+
+func (w *idleWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	enter, exit := connCounter(w.i)
+	enter()
+	return &idleConn{Conn: c, i: w.i, exit: exit}, rw, nil
+}
+
+// ReadFrom keeps sendfile of the underlying ResponseWriter, e.g. for http.ServeContent. With tick, the server is not
+// idle during the transfer, as there are no Writes to Tick on
+func (w *idleWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.tick {
+		enter, exit := connCounter(w.i)
+		enter()
+		defer func() {
+			w.i.Tick()
+			exit()
+		}()
+	}
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{w}, r)
+}
+
+// writerOnly hides ReadFrom, so that io.Copy does not call it back
+type writerOnly struct {
+	io.Writer
+}
+
+func (w *idleWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (w *idleWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status is the JSON response of StatusHandler
+type Status struct {
+	LastActivity time.Time `json:"last_activity"`
+	ActiveJobs   int64     `json:"active_jobs"`
+	// Zero while busy, see Controller.IdleDeadline
+	IdleDeadline time.Time `json:"idle_deadline"`
+	// e.g. 30m0s, empty for idlers not from CreateIdler or CreateRateIdler
+	Timeout string `json:"timeout,omitempty"`
+	Idle    bool   `json:"idle"`
+}
+
+// StatusHandler responds with the Status of i as JSON, to see why a service is not scaling to zero. Mount it under a
+// path excluded from ticking, see Except
+func StatusHandler(i Controller) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status := Status{
+			LastActivity: i.LastActivity(),
+			ActiveJobs:   i.ActiveJobs(),
+			IdleDeadline: i.IdleDeadline(),
+		}
+		select {
+		case <-i.Chan():
+			status.Idle = true
+		default:
+		}
+		if ii, ok := i.(*idler); ok {
+			status.Timeout = ii.timeout.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
+
+// WrapLoadShedHandler responds with 503 Service Unavailable when there are more than maxJobs active background jobs
+// (see Idler.Enter) or more than maxRequests requests in progress, to protect small servers from being overwhelmed, e.g.
+// right after socket activation. 0 disables the respective limit. maxJobs needs i, it is ignored if i is nil
+func WrapLoadShedHandler(i Controller, maxJobs, maxRequests int, h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	var inFlight atomic.Int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if maxRequests > 0 && n > int64(maxRequests) {
+			overloaded(w)
+			return
+		}
+		if i != nil && maxJobs > 0 && i.ActiveJobs() > int64(maxJobs) {
+			overloaded(w)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func overloaded(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "Server overloaded", http.StatusServiceUnavailable)
+}
+
 // Idler helps manage idle servers
 type Idler interface {
 	// Tick records the current time. This will make the server not idle until next Tick or timeout
 	Tick()
 
-	// Wait waits till the server is idle and returns. i.e. no Ticks in last <timeout> duration
+	// Wait waits till the server is idle and returns. i.e. no Ticks in last <timeout> duration. Returns on
+	// Controller.Close too
 	Wait()
 
 	// For long running background jobs, use Enter to record start time. Wait will not return while there are active jobs running
 	Enter()
 
-	// Exit records end of a background job
+	// Exit records end of a background job. Use defer, so that a panicking job does not keep the server from being idle forever
 	Exit()
 
 	// Get the channel to wait yourself
 	Chan() <-chan struct{}
 }
 
+// Controller is implemented by idlers from CreateIdler and CreateRateIdler. Its methods are not in Idler, so that other
+// implementations of Idler keep working. Use a type assertion, e.g. ctx.Idler.(idle.Controller).Go(fn)
+type Controller interface {
+	Idler
+
+	// WaitContext waits like Wait, but returns ctx.Err() if ctx is done first. nil once idle, ErrClosed if closed
+	WaitContext(ctx context.Context) error
+
+	// Go runs fn in a goroutine as a background job, i.e. between Enter and Exit. ctx is canceled when the idler is
+	// closed, e.g. when the server is shut down for other reasons
+	Go(fn func(ctx context.Context))
+
+	// Pause disables idle shutdown until Resume, e.g. during a migration or backup. Like Enter, but calling it again
+	// does nothing, so one Resume is enough
+	Pause()
+
+	// Resume enables idle shutdown again after Pause. The server is idle after <timeout> from now at the earliest
+	Resume()
+
+	// Close stops the idler, e.g. when the server is shut down for other reasons, so that its goroutine exits. Wait
+	// returns and Chan is closed too, so that code waiting on them does not hang. WaitContext returns ErrClosed
+	Close()
+
+	// LastActivity returns the time of the last Tick
+	LastActivity() time.Time
+
+	// IdleDeadline returns when the server becomes idle without further Ticks, i.e. LastActivity + timeout, or the end
+	// of the window for CreateRateIdler. Zero time while there are active jobs or open connections, or it is paused
+	IdleDeadline() time.Time
+
+	// ActiveJobs returns the number of background jobs running, i.e. Enter calls without Exit
+	ActiveJobs() int64
+}
+
 type idler struct {
 	lastTick atomic.Pointer[time.Time]
+	// closed once idle or closed, idle is set before if idle
 	c        chan struct{}
+	idle     atomic.Bool
+	stop     chan struct{}
+	stopOnce sync.Once
 	active   atomic.Int64
+	paused   atomic.Bool
+	timeout  time.Duration
+
+	// open connections, see WrapConnState
+	conns atomic.Int64
+
+	windows []ShutdownWindow
+	check   func() bool
+
+	// Wakes up the goroutine of CreateIdler sleeping while busy, once the last connection is closed. nil for
+	// CreateRateIdler, which counts ticks in whole windows
+	wake chan struct{}
+
+	// Rate mode, see CreateRateIdler. ticks are counted in the window ending at windowEnd
+	minTicks  int64
+	ticks     atomic.Int64
+	windowEnd atomic.Pointer[time.Time]
 }
 
 func (i *idler) Enter() {
@@ -85,43 +493,269 @@ func (i *idler) Enter() {
 
 func (i *idler) Exit() {
 	i.Tick()
-	i.active.Add(-1)
+	for {
+		n := i.active.Load()
+		// Unbalanced Exit, e.g. called again while recovering from a panic. A negative count would never be idle
+		if n <= 0 {
+			return
+		}
+		if i.active.CompareAndSwap(n, n-1) {
+			return
+		}
+	}
+}
+
+func (i *idler) Go(fn func(ctx context.Context)) {
+	// Before the goroutine starts, so that Wait does not return meanwhile
+	i.Enter()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-i.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go func() {
+		defer i.Exit()
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// busy is true while there are active jobs or open connections, or it is paused
+func (i *idler) busy() bool {
+	return i.active.Load() != 0 || i.conns.Load() != 0 || i.paused.Load()
+}
+
+func (i *idler) Pause() {
+	i.paused.Store(true)
+}
+
+func (i *idler) Resume() {
+	i.Tick()
+	i.paused.Store(false)
+}
+
+// ShutdownWindow is a daily time range in local time, as offsets from midnight, e.g. ShutdownWindow{Start: 2 *
+// time.Hour, End: 5 * time.Hour} for 02:00-05:00. End before Start spans midnight
+type ShutdownWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Option configures an Idler created with CreateIdler or CreateRateIdler
+type Option func(*idler)
+
+// WithShutdownWindows limits when the server can be idle to these daily windows, e.g. at night when a restart is cheap.
+// Outside them, the server becomes idle once the next window starts, if there are no Ticks meanwhile. No windows means
+// anytime, the default
+func WithShutdownWindows(windows ...ShutdownWindow) Option {
+	return func(i *idler) {
+		i.windows = windows
+	}
+}
+
+// WithIdleCheck sets check to be called before becoming idle, e.g. to look for connections waiting to be accepted. If
+// it returns false, the server is not idle, as if Tick was called
+func WithIdleCheck(check func() bool) Option {
+	return func(i *idler) {
+		i.check = check
+	}
+}
+
+// checkIdle calls the check set with WithIdleCheck, and Ticks if it fails
+func (i *idler) checkIdle() bool {
+	if i.check == nil || i.check() {
+		return true
+	}
+	i.Tick()
+	return false
+}
+
+// nextAllowed returns t if it is in a shutdown window, else the start of the next one
+func (i *idler) nextAllowed(t time.Time) time.Time {
+	if len(i.windows) == 0 {
+		return t
+	}
+	var next time.Time
+	y, m, d := t.Date()
+	for _, w := range i.windows {
+		// Yesterday's window could span midnight
+		for day := -1; day <= 1; day++ {
+			midnight := time.Date(y, m, d+day, 0, 0, 0, 0, t.Location())
+			start, end := midnight.Add(w.Start), midnight.Add(w.End)
+			if w.End <= w.Start {
+				end = end.AddDate(0, 0, 1)
+			}
+			if !t.Before(start) && t.Before(end) {
+				return t
+			}
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
 }
 
 // CreateIdler creates an Idler with given timeout
-func CreateIdler(timeout time.Duration) Idler {
-	i := &idler{}
+func CreateIdler(timeout time.Duration, opts ...Option) Idler {
+	i := &idler{timeout: timeout}
+	for _, opt := range opts {
+		opt(i)
+	}
 	i.c = make(chan struct{})
+	i.stop = make(chan struct{})
+	i.wake = make(chan struct{}, 1)
 	i.Tick()
 	go func() {
 		for {
-			if i.active.Load() != 0 {
-				time.Sleep(timeout)
+			if i.busy() {
+				if !i.sleep(timeout) {
+					close(i.c)
+					return
+				}
 				continue
 			}
 			t := *i.lastTick.Load()
 			now := time.Now()
-			dur := t.Add(timeout).Sub(now)
+			dur := i.nextAllowed(t.Add(timeout)).Sub(now)
 			if dur == dur.Abs() {
-				time.Sleep(dur)
+				if !i.sleep(dur) {
+					close(i.c)
+					return
+				}
+				continue
+			}
+			if !i.checkIdle() {
 				continue
 			}
 			break
 		}
+		i.idle.Store(true)
 		close(i.c)
 	}()
 	return i
 }
 
+// CreateRateIdler creates an Idler that is idle when there are fewer than minTicks Ticks in a window, instead of none,
+// e.g. so that occasional health probes do not keep the server running. Note that WrapIdlerHandler ticks twice per
+// request and WrapHandler once. minTicks <= 1 is the same as CreateIdler(window)
+func CreateRateIdler(window time.Duration, minTicks int64, opts ...Option) Idler {
+	if minTicks <= 1 {
+		return CreateIdler(window, opts...)
+	}
+	i := &idler{timeout: window, minTicks: minTicks}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.c = make(chan struct{})
+	i.stop = make(chan struct{})
+	i.Tick()
+	// Stored before returning, so IdleDeadline works right away
+	end := time.Now().Add(window)
+	i.windowEnd.Store(&end)
+	go func() {
+		for {
+			if !i.sleep(window) {
+				close(i.c)
+				return
+			}
+			ticks := i.ticks.Swap(0)
+			if !i.busy() && ticks < minTicks && !i.nextAllowed(time.Now()).After(time.Now()) && i.checkIdle() {
+				break
+			}
+			end := time.Now().Add(window)
+			i.windowEnd.Store(&end)
+		}
+		i.idle.Store(true)
+		close(i.c)
+	}()
+	return i
+}
+
+// done is true once idle or closed
+func (i *idler) done() bool {
+	select {
+	case <-i.c:
+		return true
+	case <-i.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (i *idler) wakeUp() {
+	select {
+	case i.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sleep returns false if the idler is closed meanwhile. Returns early when woken up
+func (i *idler) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-i.wake:
+		return true
+	case <-i.stop:
+		return false
+	}
+}
+
+func (i *idler) Close() {
+	i.stopOnce.Do(func() { close(i.stop) })
+}
+
 func (i *idler) Tick() {
 	now := time.Now()
 	i.lastTick.Store(&now)
+	i.ticks.Add(1)
 }
 
 func (i *idler) Wait() {
 	<-i.c
 }
 
+func (i *idler) WaitContext(ctx context.Context) error {
+	select {
+	case <-i.c:
+		if !i.idle.Load() {
+			return ErrClosed
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *idler) LastActivity() time.Time {
+	return *i.lastTick.Load()
+}
+
+func (i *idler) IdleDeadline() time.Time {
+	if i.busy() {
+		return time.Time{}
+	}
+	if i.minTicks > 0 {
+		end := *i.windowEnd.Load()
+		if i.ticks.Load() >= i.minTicks {
+			return i.nextAllowed(end.Add(i.timeout))
+		}
+		return i.nextAllowed(end)
+	}
+	return i.nextAllowed(i.LastActivity().Add(i.timeout))
+}
+
+func (i *idler) ActiveJobs() int64 {
+	return i.active.Load()
+}
+
 func (i *idler) Chan() <-chan struct{} {
 	return i.c
 }
@@ -1,6 +1,15 @@
 package idle
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -11,13 +20,504 @@ func TestIdlerChan(_ *testing.T) {
 }
 
 func TestGlobalIdler(t *testing.T) {
+	t.Cleanup(Reset)
 	err := Wait(10 * time.Millisecond)
 	if err != nil {
 		t.Fatalf("idle.Wait failed, %v", err)
 	}
 	err = Wait(10 * time.Millisecond)
-	if err == nil {
-		t.Fatal("idle.Wait should fail when called second time")
+	if err != nil {
+		t.Fatalf("idle.Wait should work again after the previous one returned, %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := make(chan error)
+	go func() {
+		waiting <- WaitContext(ctx, time.Hour)
+	}()
+	for gIdler.Load().timeout != time.Hour {
+		time.Sleep(time.Millisecond)
+	}
+	if err := Wait(10 * time.Millisecond); err == nil {
+		t.Error("idle.Wait should fail while another Wait is waiting")
+	}
+	cancel()
+	if err := <-waiting; !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitContext() = %v, want canceled", err)
+	}
+
+	Reset()
+	if gIdler.Load() != nil {
+		t.Error("Reset should clear the global idler")
+	}
+
+	// A Wait in progress returns on Reset
+	go func() {
+		waiting <- Wait(time.Hour)
+	}()
+	for gIdler.Load() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	Reset()
+	select {
+	case err := <-waiting:
+		if !errors.Is(err, ErrReset) {
+			t.Errorf("Wait() = %v, want ErrReset", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait in progress did not return on Reset")
+	}
+	if err := Wait(10 * time.Millisecond); err != nil {
+		t.Errorf("idle.Wait should work after Reset, %v", err)
+	}
+}
+
+func TestIdlerWaitContext(t *testing.T) {
+	i := CreateIdler(time.Hour).(Controller)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := i.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitContext() = %v, want deadline exceeded", err)
+	}
+
+	i = CreateIdler(10 * time.Millisecond).(Controller)
+	if err := i.WaitContext(context.Background()); err != nil {
+		t.Errorf("WaitContext() = %v, want nil once idle", err)
+	}
+}
+
+func TestIdlerPause(t *testing.T) {
+	i := CreateIdler(10 * time.Millisecond).(Controller)
+	i.Pause()
+	i.Pause()
+	select {
+	case <-i.Chan():
+		t.Fatal("idle while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	i.Resume()
+	select {
+	case <-i.Chan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("not idle after Resume")
+	}
+}
+
+func TestWrapConnState(t *testing.T) {
+	i := CreateIdler(time.Hour).(*idler)
+	var states []http.ConnState
+	hook := WrapConnState(i, func(_ net.Conn, state http.ConnState) { states = append(states, state) })
+	hook(nil, http.StateNew)
+	hook(nil, http.StateActive)
+	hook(nil, http.StateIdle)
+	if i.conns.Load() != 1 || !i.IdleDeadline().IsZero() {
+		t.Errorf("conns = %v, want 1 while the connection is open", i.conns.Load())
+	}
+	if i.ActiveJobs() != 0 {
+		t.Errorf("ActiveJobs() = %v, connections are not jobs", i.ActiveJobs())
+	}
+	hook(nil, http.StateClosed)
+	if i.conns.Load() != 0 {
+		t.Errorf("conns = %v, want 0 after close", i.conns.Load())
+	}
+	if len(states) != 4 {
+		t.Errorf("next called %v times, want 4", len(states))
+	}
+}
+
+func TestIdlerIntrospection(t *testing.T) {
+	before := time.Now()
+	i := CreateIdler(time.Hour).(Controller)
+	i.Tick()
+	last := i.LastActivity()
+	if last.Before(before) {
+		t.Errorf("LastActivity() = %v, before Tick", last)
+	}
+	if got := i.IdleDeadline(); !got.Equal(last.Add(time.Hour)) {
+		t.Errorf("IdleDeadline() = %v, want %v", got, last.Add(time.Hour))
+	}
+	i.Enter()
+	if got := i.ActiveJobs(); got != 1 {
+		t.Errorf("ActiveJobs() = %v, want 1", got)
+	}
+	if got := i.IdleDeadline(); !got.IsZero() {
+		t.Errorf("IdleDeadline() = %v, want zero with active jobs", got)
+	}
+	i.Exit()
+	if got := i.ActiveJobs(); got != 0 {
+		t.Errorf("ActiveJobs() = %v, want 0", got)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test")
+		registryMu.Unlock()
+	})
+	if Get("test") != nil {
+		t.Fatal("Get should return nil before Register")
+	}
+	i := CreateIdler(time.Hour)
+	if err := Register("test", i); err != nil {
+		t.Fatal(err)
+	}
+	if Get("test") != i {
+		t.Error("Get should return the registered idler")
+	}
+	if err := Register("test", CreateIdler(time.Hour)); err == nil {
+		t.Error("Register should fail for a registered name")
+	}
+}
+
+func TestRateIdler(t *testing.T) {
+	i := CreateRateIdler(50*time.Millisecond, 3)
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				i.Tick()
+			}
+		}
+	}()
+	select {
+	case <-i.Chan():
+		t.Fatal("idle while ticks are above the threshold")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(stop)
+	// A tick per window is still idle
+	i.Tick()
+	select {
+	case <-i.Chan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("not idle after ticks dropped below the threshold")
+	}
+}
+
+func TestRateIdlerDeadline(t *testing.T) {
+	before := time.Now()
+	i := CreateRateIdler(time.Hour, 5).(Controller)
+	defer i.Close()
+	// Right after construction, before the first window ends
+	if got := i.IdleDeadline(); got.Before(before.Add(time.Hour)) {
+		t.Errorf("IdleDeadline() = %v, want the end of the first window", got)
+	}
+}
+
+func TestIdlerClose(t *testing.T) {
+	for _, create := range []func() Controller{
+		func() Controller { return CreateIdler(10 * time.Millisecond).(Controller) },
+		func() Controller { return CreateRateIdler(10*time.Millisecond, 2).(Controller) },
+	} {
+		i := create()
+		i.Tick()
+		waited := make(chan struct{})
+		go func() {
+			i.Wait()
+			close(waited)
+		}()
+		i.Close()
+		i.Close()
+		select {
+		case <-waited:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait in progress did not return on Close")
+		}
+		<-i.Chan()
+		if err := i.WaitContext(context.Background()); !errors.Is(err, ErrClosed) {
+			t.Errorf("WaitContext() = %v, want ErrClosed", err)
+		}
+	}
+}
+
+func TestWrapIdlerResponseHandler(t *testing.T) {
+	i := CreateIdler(time.Hour).(Controller)
+	var lastWrite time.Time
+	h := WrapIdlerResponseHandler(i, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("chunk"))
+		lastWrite = i.LastActivity()
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("ResponseWriter should be a Flusher")
+		}
+	}))
+	before := i.LastActivity()
+	time.Sleep(time.Millisecond)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !lastWrite.After(before) {
+		t.Error("Write should Tick")
+	}
+	if rec.Body.String() != "chunk" {
+		t.Errorf("got %q, want chunk", rec.Body.String())
+	}
+
+	// Busy during ReadFrom, e.g. sendfile of a long download, and Ticks once done
+	var busy bool
+	h = WrapIdlerResponseHandler(i, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.(io.ReaderFrom).ReadFrom(readerFunc(func(p []byte) (int, error) {
+			busy = i.IdleDeadline().IsZero()
+			return 0, io.EOF
+		}))
+		lastWrite = i.LastActivity()
+	}))
+	before = i.LastActivity()
+	time.Sleep(time.Millisecond)
+	w := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !w.readFrom || !busy || !lastWrite.After(before) {
+		t.Errorf("readFrom: %v, busy: %v, ticked: %v, want ReadFrom passed on while busy", w.readFrom, busy, lastWrite.After(before))
+	}
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestIdlerGo(t *testing.T) {
+	i := CreateIdler(10 * time.Millisecond).(Controller)
+	release := make(chan struct{})
+	canceled := make(chan struct{})
+	i.Go(func(context.Context) { <-release })
+	select {
+	case <-i.Chan():
+		t.Fatal("idle while a job is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-i.Chan()
+
+	i = CreateIdler(time.Hour).(Controller)
+	i.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	})
+	i.Close()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ctx not canceled on Close")
+	}
+}
+
+func TestWrapIdlerHandlerExcept(t *testing.T) {
+	i := CreateIdler(time.Hour).(Controller)
+	h := WrapIdlerHandlerExcept(i, Except{Paths: []string{"/healthz", "/debug/"}, Methods: []string{http.MethodOptions}}, nil)
+	tests := []struct {
+		method, path string
+		tick         bool
+	}{
+		{http.MethodGet, "/", true},
+		{http.MethodGet, "/healthz", false},
+		{http.MethodGet, "/healthz/x", true},
+		{http.MethodGet, "/debug/pprof", false},
+		{http.MethodOptions, "/", false},
+	}
+	for _, tt := range tests {
+		before := i.LastActivity()
+		time.Sleep(time.Millisecond)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		if ticked := i.LastActivity().After(before); ticked != tt.tick {
+			t.Errorf("%v %v: ticked = %v, want %v", tt.method, tt.path, ticked, tt.tick)
+		}
+	}
+}
+
+func TestShutdownWindows(t *testing.T) {
+	i := CreateIdler(time.Hour, WithShutdownWindows(ShutdownWindow{Start: 2 * time.Hour, End: 5 * time.Hour},
+		ShutdownWindow{Start: 23 * time.Hour, End: time.Hour})).(*idler)
+	defer i.Close()
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 10, h, m, 0, 0, time.Local) }
+	tests := []struct {
+		t, want time.Time
+	}{
+		{at(3, 0), at(3, 0)},
+		{at(1, 30), at(2, 0)},
+		{at(12, 0), at(23, 0)},
+		{at(23, 30), at(23, 30)},
+		{at(0, 30), at(0, 30)},
+		{at(5, 0), at(23, 0)},
+	}
+	for _, tt := range tests {
+		if got := i.nextAllowed(tt.t); !got.Equal(tt.want) {
+			t.Errorf("nextAllowed(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	later := now.Add(time.Hour).Sub(midnight)
+	idler := CreateIdler(10*time.Millisecond, WithShutdownWindows(ShutdownWindow{Start: later, End: later + time.Hour})).(Controller)
+	defer idler.Close()
+	select {
+	case <-idler.Chan():
+		t.Fatal("idle outside shutdown window")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := idler.IdleDeadline(); got.Before(now.Add(59 * time.Minute)) {
+		t.Errorf("IdleDeadline() = %v, want the window start", got)
+	}
+
+	// In the window now
+	earlier := now.Add(-time.Hour).Sub(midnight)
+	inWindow := CreateRateIdler(10*time.Millisecond, 2, WithShutdownWindows(ShutdownWindow{Start: earlier, End: earlier + 2*time.Hour}))
+	select {
+	case <-inWindow.Chan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("not idle in shutdown window")
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	i := CreateIdler(time.Hour).(Controller)
+	i.Enter()
+	rec := httptest.NewRecorder()
+	StatusHandler(i).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/idle", nil))
+	var status Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.ActiveJobs != 1 || status.Timeout != "1h0m0s" || status.Idle || !status.IdleDeadline.IsZero() ||
+		!status.LastActivity.Equal(i.LastActivity()) {
+		t.Errorf("got %+v", status)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestIdlerIdleCheck(t *testing.T) {
+	var checks atomic.Int32
+	i := CreateIdler(10*time.Millisecond, WithIdleCheck(func() bool { return checks.Add(1) > 2 }))
+	select {
+	case <-i.Chan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("not idle once the check passes")
+	}
+	if got := checks.Load(); got != 3 {
+		t.Errorf("checked %v times, want 3", got)
+	}
+}
+
+func TestWrapListener(t *testing.T) {
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := CreateIdler(time.Hour).(*idler)
+	l := WrapListener(tl, i)
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	before := i.LastActivity()
+	time.Sleep(time.Millisecond)
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !i.LastActivity().After(before) {
+		t.Error("Accept should Tick")
+	}
+	if i.conns.Load() != 1 {
+		t.Errorf("conns = %v, want 1 while the connection is open", i.conns.Load())
+	}
+	conn.Close()
+	conn.Close()
+	if i.conns.Load() != 0 {
+		t.Errorf("conns = %v, want 0 after close", i.conns.Load())
+	}
+}
+
+func TestWrapIdlerHandlerHijack(t *testing.T) {
+	i := CreateIdler(time.Hour).(*idler)
+	hijacked := make(chan net.Conn, 1)
+	srv := httptest.NewServer(WrapIdlerHandler(i, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		c, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		hijacked <- c
+	})))
+	defer srv.Close()
+	client, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	c := <-hijacked
+	if i.conns.Load() != 1 {
+		t.Errorf("conns = %v, want 1 while the hijacked connection is open", i.conns.Load())
+	}
+	c.Close()
+	if i.conns.Load() != 0 {
+		t.Errorf("conns = %v, want 0 after close", i.conns.Load())
+	}
+}
+
+// fullWriter is a ResponseWriter with the optional interfaces of net/http
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+	pushed   string
+}
+
+func (w *fullWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+func (w *fullWriter) Push(target string, _ *http.PushOptions) error {
+	w.pushed = target
+	return nil
+}
+
+func TestWrapIdlerHandlerInterfaces(t *testing.T) {
+	i := CreateIdler(time.Hour).(Controller)
+	defer i.Close()
+	h := WrapIdlerHandler(i, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		rf, ok := w.(io.ReaderFrom)
+		if !ok {
+			t.Fatal("io.ReaderFrom not kept")
+		}
+		if _, err := rf.ReadFrom(strings.NewReader("ok")); err != nil {
+			t.Error(err)
+		}
+		if err := w.(http.Pusher).Push("/app.js", nil); err != nil {
+			t.Error(err)
+		}
+	}))
+	w := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !w.readFrom || w.pushed != "/app.js" || w.Body.String() != "ok" {
+		t.Errorf("readFrom: %v, pushed: %q, body: %q, want calls passed on", w.readFrom, w.pushed, w.Body.String())
+	}
+
+	// Falls back to Write, and Push is not supported, for writers without them
+	rec := httptest.NewRecorder()
+	WrapIdlerHandler(i, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("ok")); err != nil {
+			t.Error(err)
+		}
+		if err := w.(http.Pusher).Push("/app.js", nil); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("Push() = %v, want ErrNotSupported", err)
+		}
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != "ok" {
+		t.Errorf("body = %q, want ok", rec.Body.String())
 	}
 }
 
@@ -32,3 +532,57 @@ func TestIdlerEnterExit(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestLoadShed(t *testing.T) {
+	i := CreateIdler(time.Hour).(Controller)
+	started := make(chan struct{})
+	blocked := make(chan struct{})
+	h := WrapLoadShedHandler(i, 1, 1, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-blocked
+		}
+	}))
+	serve := func(path string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	if code := serve("/"); code != http.StatusOK {
+		t.Errorf("got %v, want 200", code)
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- serve("/slow")
+	}()
+	<-started
+	if code := serve("/"); code != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want 503 when requests in progress exceed limit", code)
+	}
+	close(blocked)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("got %v, want 200", code)
+	}
+
+	i.Enter()
+	i.Enter()
+	if code := serve("/"); code != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want 503 when jobs exceed limit", code)
+	}
+	i.Exit()
+	if code := serve("/"); code != http.StatusOK {
+		t.Errorf("got %v, want 200", code)
+	}
+}
+
+func TestIdlerUnbalancedExit(t *testing.T) {
+	i := CreateIdler(10 * time.Millisecond).(*idler)
+	i.Enter()
+	i.Exit()
+	i.Exit()
+	if i.active.Load() != 0 {
+		t.Fatalf("active = %v, extra Exit should be ignored", i.active.Load())
+	}
+	<-i.Chan()
+}
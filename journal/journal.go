@@ -0,0 +1,153 @@
+// Package journal writes log entries to the systemd journal with its native protocol, so that they have the right
+// priority and structured fields, e.g. for filtering with journalctl -p warning or journalctl REQUEST_ID=...
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Priority of the entry, same as syslog levels
+type Priority int
+
+const (
+	PriEmerg Priority = iota
+	PriAlert
+	PriCrit
+	PriErr
+	PriWarning
+	PriNotice
+	PriInfo
+	PriDebug
+)
+
+// socketPath is where journald listens for entries in the native protocol
+var socketPath = "/run/systemd/journal/socket"
+
+// identifier is the SYSLOG_IDENTIFIER of entries, shown by journalctl instead of the process name
+var identifier = filepath.Base(os.Args[0])
+
+var conn struct {
+	sync.Mutex
+	c *net.UnixConn
+}
+
+// Available checks if the journal socket can be written to, i.e. running on a system with journald
+func Available() bool {
+	conn.Lock()
+	defer conn.Unlock()
+	_, err := dial()
+	return err == nil
+}
+
+// dial connects to the journal socket once, conn has to be locked
+func dial() (*net.UnixConn, error) {
+	if conn.c != nil {
+		return conn.c, nil
+	}
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	conn.c = c
+	return c, nil
+}
+
+// Send writes an entry with the message, priority and fields. Field names are converted to upper case with characters
+// other than letters, digits and underscores replaced, e.g. request-id is REQUEST_ID. Entries have to fit in a datagram,
+// upto ~200KB by default on linux
+func Send(msg string, prio Priority, fields map[string]string) error {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	appendField(&b, "MESSAGE", msg)
+	appendField(&b, "PRIORITY", strconv.Itoa(int(prio)))
+	appendField(&b, "SYSLOG_IDENTIFIER", identifier)
+	for _, key := range keys {
+		appendField(&b, FieldName(key), fields[key])
+	}
+	return send(b.Bytes())
+}
+
+func send(entry []byte) error {
+	conn.Lock()
+	defer conn.Unlock()
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	if _, err = c.Write(entry); err == nil {
+		return nil
+	}
+	// journald could have been restarted, connect again once
+	c.Close()
+	conn.c = nil
+	if c, err = dial(); err != nil {
+		return err
+	}
+	_, err = c.Write(entry)
+	return err
+}
+
+// appendField adds KEY=value, or the binary form with the length for values with new lines
+func appendField(b *bytes.Buffer, key, val string) {
+	if !strings.Contains(val, "\n") {
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(val)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteString(key)
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(val)))
+	b.WriteString(val)
+	b.WriteByte('\n')
+}
+
+// FieldName converts key to a valid journal field name, i.e. upper case letters, digits and underscores, not starting
+// with an underscore or a digit
+func FieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			name[i] = '_'
+		}
+	}
+	trimmed := strings.TrimLeft(string(name), "_")
+	if trimmed == "" || trimmed[0] >= '0' && trimmed[0] <= '9' {
+		trimmed = "F_" + trimmed
+	}
+	if len(trimmed) > 64 {
+		trimmed = trimmed[:64]
+	}
+	return trimmed
+}
+
+// Writer writes each Write as an entry with Priority, e.g. for log.New. Trailing new line is removed
+type Writer struct {
+	Priority Priority
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := Send(strings.TrimSuffix(string(p), "\n"), w.Priority, nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// NewLogger returns a logger writing entries with prio, e.g. for http.Server.ErrorLog
+func NewLogger(prio Priority) *log.Logger {
+	return log.New(&Writer{Priority: prio}, "", 0)
+}
@@ -0,0 +1,118 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJournal listens on a unixgram socket used instead of the journal socket
+func fakeJournal(t *testing.T) *net.UnixConn {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("no unixgram on windows")
+	}
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := socketPath
+	socketPath = path
+	t.Cleanup(func() {
+		c.Close()
+		conn.Lock()
+		if conn.c != nil {
+			conn.c.Close()
+			conn.c = nil
+		}
+		conn.Unlock()
+		socketPath = old
+	})
+	return c
+}
+
+// readEntry reads an entry and decodes its fields
+func readEntry(t *testing.T, c *net.UnixConn) map[string]string {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 65536)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{}
+	b := buf[:n]
+	for len(b) > 0 {
+		line, rest, _ := bytes.Cut(b, []byte("\n"))
+		if key, val, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(key)] = string(val)
+			b = rest
+			continue
+		}
+		size := binary.LittleEndian.Uint64(rest[:8])
+		fields[string(line)] = string(rest[8 : 8+size])
+		b = rest[8+size+1:]
+	}
+	return fields
+}
+
+func TestSend(t *testing.T) {
+	c := fakeJournal(t)
+	if !Available() {
+		t.Fatal("Available() = false")
+	}
+	if err := Send("line1\nline2", PriWarning, map[string]string{"request-id": "42", "_hidden": "x", "1st": "y"}); err != nil {
+		t.Fatal(err)
+	}
+	got := readEntry(t, c)
+	want := map[string]string{
+		"MESSAGE":           "line1\nline2",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": identifier,
+		"REQUEST_ID":        "42",
+		"HIDDEN":            "x",
+		"F_1ST":             "y",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for key, val := range want {
+		if got[key] != val {
+			t.Errorf("%v = %q, want %q", key, got[key], val)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	c := fakeJournal(t)
+	NewLogger(PriErr).Printf("http: TLS handshake error from %v", "127.0.0.1:1234")
+	got := readEntry(t, c)
+	if got["MESSAGE"] != "http: TLS handshake error from 127.0.0.1:1234" || got["PRIORITY"] != "3" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	for key, want := range map[string]string{"code": "CODE", "req.id": "REQ_ID", "__x": "X", "": "F_", strings.Repeat("a", 70): strings.Repeat("A", 64)} {
+		if got := FieldName(key); got != want {
+			t.Errorf("FieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestNotAvailable(t *testing.T) {
+	fakeJournal(t)
+	socketPath = filepath.Join(t.TempDir(), "missing.sock")
+	if Available() {
+		t.Error("Available() = true without the socket")
+	}
+	if err := Send("msg", PriInfo, nil); err == nil {
+		t.Error("Send should fail without the socket")
+	}
+}
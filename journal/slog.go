@@ -0,0 +1,115 @@
+//go:build go1.21
+
+package journal
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+)
+
+// Handler is a slog.Handler writing entries to the journal. Attributes are added as fields, in groups prefixed with the
+// group name, e.g. slog.Group("req", "id", 1) is REQ_ID=1. Time is not added, the journal has its own
+type Handler struct {
+	opts   slog.HandlerOptions
+	fields map[string]string
+	prefix string
+}
+
+// NewHandler returns a Handler. opts can be nil, Level defaults to info
+func NewHandler(opts *slog.HandlerOptions) *Handler {
+	h := &Handler{fields: map[string]string{}}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled reports whether level is at least the minimum level
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+// Handle sends the record as an entry
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(map[string]string, len(h.fields)+r.NumAttrs())
+	for key, val := range h.fields {
+		fields[key] = val
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.addAttr(fields, h.prefix, a)
+		return true
+	})
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		fields["CODE_FILE"] = frame.File
+		fields["CODE_LINE"] = strconv.Itoa(frame.Line)
+		fields["CODE_FUNC"] = frame.Function
+	}
+	return Send(r.Message, levelPriority(r.Level), fields)
+}
+
+// WithAttrs returns a handler adding attrs to all entries
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := h.clone()
+	for _, a := range attrs {
+		h2.addAttr(h2.fields, h2.prefix, a)
+	}
+	return h2
+}
+
+// WithGroup returns a handler prefixing the fields of later attributes with name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := h.clone()
+	h2.prefix += name + "_"
+	return h2
+}
+
+func (h *Handler) clone() *Handler {
+	fields := make(map[string]string, len(h.fields))
+	for key, val := range h.fields {
+		fields[key] = val
+	}
+	return &Handler{opts: h.opts, fields: fields, prefix: h.prefix}
+}
+
+func (h *Handler) addAttr(fields map[string]string, prefix string, a slog.Attr) {
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(nil, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "_"
+		}
+		for _, ga := range a.Value.Group() {
+			h.addAttr(fields, prefix, ga)
+		}
+		return
+	}
+	fields[FieldName(prefix+a.Key)] = a.Value.String()
+}
+
+// levelPriority maps slog levels to journal priorities
+func levelPriority(level slog.Level) Priority {
+	switch {
+	case level >= slog.LevelError:
+		return PriErr
+	case level >= slog.LevelWarn:
+		return PriWarning
+	case level >= slog.LevelInfo:
+		return PriInfo
+	}
+	return PriDebug
+}
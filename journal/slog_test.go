@@ -0,0 +1,40 @@
+//go:build go1.21
+
+package journal
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	c := fakeJournal(t)
+	logger := slog.New(NewHandler(&slog.HandlerOptions{Level: slog.LevelDebug, AddSource: true})).With("app", "web")
+	logger.WithGroup("req").Warn("slow request", "path", "/api", slog.Group("client", "ip", "10.0.0.1"))
+	got := readEntry(t, c)
+	want := map[string]string{
+		"MESSAGE":       "slow request",
+		"PRIORITY":      "4",
+		"APP":           "web",
+		"REQ_PATH":      "/api",
+		"REQ_CLIENT_IP": "10.0.0.1",
+	}
+	for key, val := range want {
+		if got[key] != val {
+			t.Errorf("%v = %q, want %q", key, got[key], val)
+		}
+	}
+	if !strings.HasSuffix(got["CODE_FILE"], "slog_test.go") || got["CODE_LINE"] == "" || !strings.HasSuffix(got["CODE_FUNC"], "TestHandler") {
+		t.Errorf("source fields: %q", got)
+	}
+
+	logger.Debug("debug")
+	if got := readEntry(t, c); got["PRIORITY"] != "7" {
+		t.Errorf("PRIORITY = %q for debug", got["PRIORITY"])
+	}
+	if NewHandler(nil).Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("debug should be disabled by default")
+	}
+}
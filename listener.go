@@ -0,0 +1,87 @@
+package anyhttp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	minAcceptBackoff = 5 * time.Millisecond
+	maxAcceptBackoff = 1 * time.Second
+)
+
+// resilientListener retries accept errors caused by a brief shortage of kernel memory with backoff. http.Server.Serve
+// already backs off on Temporary errors, e.g. EMFILE or ECONNABORTED, but ENOBUFS and ENOMEM are not Temporary, so
+// Serve would fail and the server would exit under memory pressure that passes in a moment
+type resilientListener struct {
+	net.Listener
+	logf func(format string, args ...any)
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newResilientListener(l net.Listener, logf func(format string, args ...any)) *resilientListener {
+	return &resilientListener{
+		Listener: l,
+		logf:     logf,
+		closed:   make(chan struct{}),
+	}
+}
+
+func (l *resilientListener) Accept() (net.Conn, error) {
+	var delay time.Duration
+	for {
+		c, err := l.Listener.Accept()
+		if err == nil {
+			return c, nil
+		}
+		if !isOutOfMemory(err) {
+			return nil, err
+		}
+		if delay == 0 {
+			delay = minAcceptBackoff
+		} else {
+			delay *= 2
+		}
+		if delay > maxAcceptBackoff {
+			delay = maxAcceptBackoff
+		}
+		l.logf("anyhttp: accept error: %v; retrying in %v", err, delay)
+		select {
+		case <-time.After(delay):
+		case <-l.closed:
+			return nil, net.ErrClosed
+		}
+	}
+}
+
+func (l *resilientListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+func isOutOfMemory(err error) bool {
+	return errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.ENOMEM)
+}
+
+// retryBind calls listen until it succeeds, fails with an error other than EADDRINUSE or lock held, or window passes.
+// Covers rolling restarts where the old instance has not released the address yet
+func retryBind[T any](window time.Duration, listen func() (T, error)) (T, error) {
+	deadline := time.Now().Add(window)
+	delay := minAcceptBackoff
+	for {
+		l, err := listen()
+		if err == nil || !(errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, errLocked)) || time.Now().Add(delay).After(deadline) {
+			return l, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxAcceptBackoff {
+			delay = maxAcceptBackoff
+		}
+	}
+}
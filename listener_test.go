@@ -0,0 +1,87 @@
+package anyhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type flakyListener struct {
+	net.Listener
+	errs []error
+}
+
+func (f *flakyListener) Accept() (net.Conn, error) {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return f.Listener.Accept()
+}
+
+func TestResilientListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs []string
+	rl := newResilientListener(&flakyListener{
+		Listener: l,
+		errs: []error{
+			&net.OpError{Op: "accept", Err: os.NewSyscallError("accept", syscall.ENOBUFS)},
+			&net.OpError{Op: "accept", Err: syscall.ENOMEM},
+			// Left to the backoff of http.Server.Serve
+			&net.OpError{Op: "accept", Err: syscall.EMFILE},
+		},
+	}, func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+	defer rl.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			c.Close()
+		}
+	}()
+
+	if _, err := rl.Accept(); !errors.Is(err, syscall.EMFILE) {
+		t.Fatalf("Accept() = %v, want %v", err, syscall.EMFILE)
+	}
+	if len(logs) != 2 {
+		t.Errorf("expected 2 retries logged, got: %q", logs)
+	}
+	c, err := rl.Accept()
+	if err != nil {
+		t.Fatalf("Accept() failed: %v", err)
+	}
+	c.Close()
+
+	rl.Close()
+	if _, err := rl.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept() after Close, got: %v, want: %v", err, net.ErrClosed)
+	}
+}
+
+func TestBindRetry(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := busy.Addr().String()
+	if _, _, _, err := GetListener(addr); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("GetListener() = %v, want %v", err, syscall.EADDRINUSE)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { busy.Close() })
+	l, _, _, err := GetListener(addr + "?bind_retry=10s")
+	if err != nil {
+		t.Fatalf("GetListener() should succeed once the address is released, err: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,43 @@
+package anyhttp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// isSystemdListen checks if addr is in systemd ListenStream= syntax and not already a tcp or nginx address,
+// e.g. /run/app.sock, @app or vsock:2:8080. Options can be added after ?, e.g. /run/app.sock?mode=660
+func isSystemdListen(addr string) bool {
+	return strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "@") || strings.HasPrefix(addr, "vsock:")
+}
+
+// parseSystemdListen parses the value of ListenStream= or ListenDatagram= of systemd socket units.
+// See https://www.freedesktop.org/software/systemd/man/latest/systemd.socket.html#ListenStream=
+func parseSystemdListen(addr string) (*address, error) {
+	listen, rawQuery, _ := strings.Cut(addr, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("systemd listen address error. Bad query: %q, err: %w", rawQuery, err)
+	}
+	// vsock:<cid>:<port>, cid can be empty for any
+	if vsock, ok := strings.CutPrefix(listen, "vsock:"); ok {
+		cid, port, found := strings.Cut(vsock, ":")
+		if !found {
+			return nil, fmt.Errorf("systemd listen address error. vsock needs cid and port, e.g. vsock:2:8080: %q", addr)
+		}
+		if query.Has("cid") || query.Has("port") {
+			return nil, fmt.Errorf("systemd listen address error. cid and port can not be options: %q", addr)
+		}
+		if cid != "" {
+			query.Set("cid", cid)
+		}
+		query.Set("port", port)
+		return parseAddr("vsock?" + query.Encode())
+	}
+	if query.Has("path") {
+		return nil, fmt.Errorf("systemd listen address error. path can not be an option: %q", addr)
+	}
+	query.Set("path", listen)
+	return parseAddr("unix?" + query.Encode())
+}
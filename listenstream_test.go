@@ -0,0 +1,107 @@
+package anyhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSystemdListen(t *testing.T) {
+	tests := []struct {
+		addr     string
+		wantType AddressType
+		wantTCP  string
+		wantPath string
+		wantMode string
+		wantErr  bool
+	}{
+		{addr: "0.0.0.0:8080", wantType: TCP, wantTCP: "0.0.0.0:8080"},
+		{addr: "[::]:8080", wantType: TCP, wantTCP: "[::]:8080"},
+		{addr: "8080", wantType: TCP, wantTCP: ":8080"},
+		{addr: "/run/app.sock", wantType: UnixSocket, wantPath: "/run/app.sock", wantMode: "666"},
+		{addr: "/run/app.sock?mode=660", wantType: UnixSocket, wantPath: "/run/app.sock", wantMode: "660"},
+		{addr: "@app", wantType: UnixSocket, wantPath: "@app", wantMode: "666"},
+		{addr: "/run/app.sock?path=/run/other.sock", wantErr: true},
+		{addr: "/run/app.sock?foo=bar", wantErr: true},
+		{addr: "vsock:3:5000", wantType: Vsock},
+		{addr: "vsock::5000", wantType: Vsock},
+		{addr: "vsock:3", wantErr: true},
+		{addr: "vsock:3:5000?port=80", wantErr: true},
+	}
+	for _, tt := range tests {
+		a, err := parseAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAddr(%q) err: %v, wantErr: %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var path, mode string
+		if a.usc != nil {
+			path, mode = a.usc.SocketPath, fmt.Sprintf("%o", a.usc.SocketMode)
+		}
+		if a.addrType != tt.wantType || a.tcpAddr != tt.wantTCP || path != tt.wantPath || mode != tt.wantMode {
+			t.Errorf("parseAddr(%q) = %+v, usc: %v", tt.addr, a, a.usc)
+		}
+	}
+}
+
+func TestAbstractUnixSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract unix sockets are linux only")
+	}
+	name := fmt.Sprintf("@anyhttp-test-%d", time.Now().UnixNano())
+	ctx, err := Serve(name, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return DialContext(ctx, name)
+		},
+	}}
+	resp, err := client.Get("http://abstract/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("got %q, want ok", body)
+	}
+}
+
+func TestAbstractOption(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		if _, err := parseAddr("unix?abstract=app"); err == nil {
+			t.Error("abstract should fail on non linux")
+		}
+		t.Skip("abstract unix sockets are linux only")
+	}
+	for _, addr := range []string{"unix?abstract=", "unix?abstract=app&path=/run/app.sock"} {
+		if _, err := parseAddr(addr); err == nil {
+			t.Errorf("parseAddr(%q) should fail", addr)
+		}
+	}
+	name := fmt.Sprintf("anyhttp-test-%d", time.Now().UnixNano())
+	addr := AbstractUnixAddr(name).String()
+	ctx, err := Serve(addr, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if ctx.UnixSocketConfig.SocketPath != "@"+name {
+		t.Errorf("SocketPath = %q, want @%v", ctx.UnixSocketConfig.SocketPath, name)
+	}
+	c, err := DialContext(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
@@ -0,0 +1,77 @@
+package anyhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errLocked is returned when the lock file is held by another instance
+var errLocked = errors.New("locked by another instance")
+
+// lockPath returns the lock file for the lock option. true derives it from the socket path or tcp address
+func (a *address) lockPath() (string, error) {
+	if a.lock != "true" {
+		return a.lock, nil
+	}
+	if a.usc != nil {
+		return a.usc.SocketPath + ".lock", nil
+	}
+	if a.addrType == TCP && a.schemeListen == nil {
+		tcpAddr := a.tcpAddr
+		if tcpAddr == "" {
+			tcpAddr = ":http"
+		}
+		name := strings.NewReplacer(":", "_", "/", "_", "[", "", "]", "").Replace(tcpAddr)
+		return filepath.Join(os.TempDir(), "anyhttp-"+name+".lock"), nil
+	}
+	return "", fmt.Errorf("Bad lock: true, lock file can not be derived for %v address, set a path", a.addrType)
+}
+
+// lockListen takes the lock on path and then listens. The lock is held until the listener is closed
+func lockListen(path string, listen func() (net.Listener, error)) (net.Listener, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f); err != nil {
+		defer f.Close()
+		if errors.Is(err, errLocked) {
+			if pid, rerr := os.ReadFile(path); rerr == nil && len(pid) > 0 {
+				return nil, fmt.Errorf("lock %v, pid: %s, err: %w", path, strings.TrimSpace(string(pid)), err)
+			}
+		}
+		return nil, fmt.Errorf("lock %v, err: %w", path, err)
+	}
+	// For finding the running instance. Errors are ignored, the lock is what matters
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	l, err := listen()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &lockedListener{Listener: l, lockFile: f}, nil
+}
+
+// lockedListener releases the lock after the listener is closed, so the socket file is removed before another instance can
+// take over. The lock file itself is not removed, removing it would let two instances lock different files
+type lockedListener struct {
+	net.Listener
+	lockFile *os.File
+}
+
+func (l *lockedListener) Close() error {
+	err := l.Listener.Close()
+	l.lockFile.Close()
+	return err
+}
+
+func (l *lockedListener) unwrap() net.Listener {
+	return l.Listener
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package anyhttp
+
+import (
+	"errors"
+	"os"
+)
+
+func flock(f *os.File) error {
+	return errors.New("lock is not supported on this platform")
+}
@@ -0,0 +1,78 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package anyhttp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "app.sock")
+	addr := "unix?path=" + sockPath + "&lock=true"
+	l, _, _, err := GetListener(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = GetListener(addr)
+	if !errors.Is(err, errLocked) {
+		t.Fatalf("second instance should fail with lock held, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "pid") {
+		t.Errorf("error should have pid of the running instance: %v", err)
+	}
+	if cur, err := os.Stat(sockPath); err != nil || !os.SameFile(fi, cur) {
+		t.Errorf("socket of running instance should not be removed, err: %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l.Close()
+	}()
+	l2, _, _, err := GetListener(addr + "&bind_retry=5s")
+	if err != nil {
+		t.Fatalf("lock should be taken after running instance exits, got: %v", err)
+	}
+	l2.Close()
+	if _, err := os.Stat(sockPath + ".lock"); err != nil {
+		t.Errorf("lock file should be kept: %v", err)
+	}
+}
+
+func TestLockPath(t *testing.T) {
+	for _, tc := range []struct {
+		addr string
+		path string
+	}{
+		{"unix?path=/run/app.sock&lock=true", "/run/app.sock.lock"},
+		{"127.0.0.1:8080?lock=true", filepath.Join(os.TempDir(), "anyhttp-127.0.0.1_8080.lock")},
+		{"sysd?name=app&lock=/run/app.lock", "/run/app.lock"},
+	} {
+		a, err := parseAddr(tc.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path, err := a.lockPath(); err != nil || path != tc.path {
+			t.Errorf("%v: got %q, err: %v, want %q", tc.addr, path, err, tc.path)
+		}
+	}
+	a, err := parseAddr("sysd?name=app&lock=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.lockPath(); err == nil {
+		t.Error("lock=true should fail for sysd")
+	}
+	if a, err := parseAddr(":8080?lock=false"); err != nil || a.lock != "" {
+		t.Errorf("lock=false should disable, got: %q, err: %v", a.lock, err)
+	}
+}
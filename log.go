@@ -0,0 +1,64 @@
+package anyhttp
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"go.balki.me/anyhttp/journal"
+)
+
+// Priority prefixes understood by the journal, see sd-daemon(3)
+const (
+	prioWarning = "<4>"
+	prioInfo    = "<6>"
+)
+
+var journalPriority = map[string]journal.Priority{
+	prioWarning: journal.PriWarning,
+	prioInfo:    journal.PriInfo,
+}
+
+var journalOnce struct {
+	sync.Once
+	connected bool
+	// Used as http.Server.ErrorLog, for errors logged by net/http
+	errorLog *log.Logger
+}
+
+// toJournal checks if logs are written to the journal, i.e. stderr is the stream in $JOURNAL_STREAM and the standard
+// logger still writes to stderr. Journal adds its own timestamps
+func toJournal() bool {
+	j := &journalOnce
+	j.Do(func() {
+		j.connected = stderrIsJournal()
+		if j.connected {
+			j.errorLog = log.New(&prioWriter{w: os.Stderr, prio: prioWarning}, "", 0)
+		}
+	})
+	return j.connected && log.Writer() == os.Stderr
+}
+
+// logPrintf logs with the priority prefix and without timestamp when logging to the journal, else using the standard logger
+func logPrintf(prio string, format string, args ...any) {
+	if toJournal() {
+		fmt.Fprintf(os.Stderr, "%v%v\n", prio, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// prioWriter prefixes each log line with the priority
+type prioWriter struct {
+	w    io.Writer
+	prio string
+}
+
+func (p *prioWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(p.w, p.prio); err != nil {
+		return 0, err
+	}
+	return p.w.Write(b)
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package anyhttp
+
+func stderrIsJournal() bool {
+	return false
+}
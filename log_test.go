@@ -0,0 +1,95 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestJournalLog(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+
+	stderr := os.Stderr
+	os.Stderr = f
+	resetJournal := func() {
+		journalOnce.Once = sync.Once{}
+		journalOnce.connected = false
+		journalOnce.errorLog = nil
+	}
+	defer func() {
+		os.Stderr = stderr
+		resetJournal()
+	}()
+
+	t.Setenv("JOURNAL_STREAM", "1:2")
+	if stderrIsJournal() {
+		t.Error("stderr should not be journal for other stream")
+	}
+	t.Setenv("JOURNAL_STREAM", fmt.Sprintf("%v:%v", st.Dev, st.Ino))
+	if !stderrIsJournal() {
+		t.Fatal("stderr should be journal")
+	}
+
+	resetJournal()
+	logOutput := log.Writer()
+	defer log.SetOutput(logOutput)
+	log.SetOutput(f)
+	if !toJournal() {
+		t.Fatal("should log to journal")
+	}
+	logPrintf(prioInfo, "anyhttp: %v", "hello")
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<6>anyhttp: hello\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
+
+func TestPrioWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := &prioWriter{w: f, prio: prioWarning}
+	fmt.Fprintln(w, "anyhttp: one")
+	fmt.Fprintln(w, "anyhttp: two")
+	data, _ := os.ReadFile(path)
+	if want := "<4>anyhttp: one\n<4>anyhttp: two\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
+
+func TestJournalOption(t *testing.T) {
+	if _, err := parseAddr("127.0.0.1:0?journal=maybe"); err == nil {
+		t.Error("parseAddr should fail for bad journal")
+	}
+	// Not run by systemd, logs as usual
+	t.Setenv("INVOCATION_ID", "")
+	ctx, err := Serve("127.0.0.1:0?journal=true", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if ctx.journalLog != nil {
+		t.Error("journal should not be used without INVOCATION_ID")
+	}
+}
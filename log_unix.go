@@ -0,0 +1,25 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// stderrIsJournal compares device and inode of stderr with $JOURNAL_STREAM, which is set by systemd for services
+// logging to the journal. Inherited by child processes, so the fd has to be checked too
+func stderrIsJournal() bool {
+	dev, ino, ok := strings.Cut(os.Getenv("JOURNAL_STREAM"), ":")
+	if !ok {
+		return false
+	}
+	fi, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && fmt.Sprint(st.Dev) == dev && fmt.Sprint(st.Ino) == ino
+}
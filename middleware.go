@@ -0,0 +1,103 @@
+package anyhttp
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// MaintenanceResponse is the response sent for all requests in maintenance mode
+type MaintenanceResponse struct {
+	// Response body. Defaults to "Service under maintenance\n"
+	Body string
+	// Content-Type header. Defaults to "text/plain; charset=utf-8"
+	ContentType string
+	// Sets Retry-After header in seconds if non-zero
+	RetryAfter time.Duration
+}
+
+var defaultMaintenanceResponse = MaintenanceResponse{
+	Body:        "Service under maintenance\n",
+	ContentType: "text/plain; charset=utf-8",
+}
+
+// SetMaintenance enables or disables maintenance mode. In maintenance mode all requests are answered with 503 Service Unavailable,
+// while the listener stays open so that systemd does not activate another instance
+func (s *ServerCtx) SetMaintenance(on bool) {
+	s.maintenance.Store(on)
+}
+
+// SetMaintenanceResponse configures the response sent in maintenance mode
+func (s *ServerCtx) SetMaintenanceResponse(resp MaintenanceResponse) {
+	if resp.Body == "" {
+		resp.Body = defaultMaintenanceResponse.Body
+	}
+	if resp.ContentType == "" {
+		resp.ContentType = defaultMaintenanceResponse.ContentType
+	}
+	s.maintenanceResp.Store(&resp)
+}
+
+// wrapHandler adds the handlers for features managed by ServerCtx
+func (s *ServerCtx) wrapHandler(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer s.recoverPanic(w, r)
+		if s.warmupDone != nil {
+			select {
+			case <-s.warmupDone:
+			case <-r.Context().Done():
+				return
+			}
+			if s.warmupErr != nil {
+				unavailable(w, "Service failed to start\n", "text/plain; charset=utf-8", 0)
+				return
+			}
+		}
+		if s.draining.Load() {
+			// Ask client and load balancers to go elsewhere instead of reusing this connection. The HTTP/2 server of
+			// net/http drops the header and sends GOAWAY instead, so multiplexed clients stop opening new streams
+			w.Header().Set("Connection", "close")
+			if s.drainRetryAfter > 0 {
+				unavailable(w, "Service shutting down\n", "text/plain; charset=utf-8", s.drainRetryAfter)
+				return
+			}
+		}
+		if s.maintenance.Load() {
+			resp := s.maintenanceResp.Load()
+			if resp == nil {
+				resp = &defaultMaintenanceResponse
+			}
+			unavailable(w, resp.Body, resp.ContentType, resp.RetryAfter)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// recoverPanic logs panics from handlers and responds with 500 Internal Server Error. http.ErrAbortHandler is passed on,
+// so that the server aborts the response silently
+func (s *ServerCtx) recoverPanic(w http.ResponseWriter, r *http.Request) {
+	err := recover()
+	if err == nil {
+		return
+	}
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	s.logf("anyhttp: panic serving %v %v: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+	// Superfluous if the handler already wrote the header, logged by the server
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func unavailable(w http.ResponseWriter, body, contentType string, retryAfter time.Duration) {
+	w.Header().Set("Content-Type", contentType)
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write([]byte(body))
+}
@@ -0,0 +1,101 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestMaintenance(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	url := "http://" + ctx.Addr().String()
+
+	ctx.SetMaintenance(true)
+	resp, body := get(t, url)
+	if resp.StatusCode != http.StatusServiceUnavailable || body != defaultMaintenanceResponse.Body || resp.Header.Get("Retry-After") != "" {
+		t.Errorf("got %v %q, Retry-After: %q", resp.StatusCode, body, resp.Header.Get("Retry-After"))
+	}
+
+	ctx.SetMaintenanceResponse(MaintenanceResponse{Body: `{"error": "maintenance"}`, ContentType: "application/json", RetryAfter: 2 * time.Minute})
+	resp, body = get(t, url)
+	if resp.StatusCode != http.StatusServiceUnavailable || body != `{"error": "maintenance"}` ||
+		resp.Header.Get("Retry-After") != "120" || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("got %v %q, headers: %v", resp.StatusCode, body, resp.Header)
+	}
+
+	ctx.SetMaintenance(false)
+	if resp, body = get(t, url); resp.StatusCode != http.StatusOK || body != "ok" {
+		t.Errorf("got %v %q, want 200 ok", resp.StatusCode, body)
+	}
+}
+
+func TestDrainRetryAfter(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0?drain_retry_after=5s", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ctx.wrapHandler(ctx.Server.Handler)
+	if resp, body := get(t, "http://"+ctx.Addr().String()); resp.StatusCode != http.StatusOK || body != "ok" {
+		t.Errorf("got %v %q, want 200 ok", resp.StatusCode, body)
+	}
+
+	if err := ctx.Shutdown(context.Background()); !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("Shutdown() = %v, want %v", err, http.ErrServerClosed)
+	}
+	if !ctx.draining.Load() {
+		t.Fatal("draining should be set on Shutdown")
+	}
+	// Emulates a request received on a keep-alive connection after shutdown started
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "5" || rec.Header().Get("Connection") != "close" {
+		t.Errorf("got %v, headers: %v", rec.Code, rec.Header())
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	url := "http://" + ctx.Addr().String()
+
+	if resp, _ := get(t, url+"/panic"); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got %v, want 500", resp.StatusCode)
+	}
+	if resp, body := get(t, url); resp.StatusCode != http.StatusOK || body != "ok" {
+		t.Errorf("got %v %q, want 200 ok", resp.StatusCode, body)
+	}
+}
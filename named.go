@@ -0,0 +1,241 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ServeAllSystemdFDs serves the handler on all systemd socket activated fds under one ServerCtx, e.g. several ListenStream=
+// in one socket unit. Same as Serve("sysd?all=true", h). Use Serve with options, e.g. "sysd?all=true&idle_timeout=30m"
+func ServeAllSystemdFDs(h http.Handler, opts ...Option) (*ServerCtx, error) {
+	return Serve("sysd?all=true", h, opts...)
+}
+
+// ServeNamed serves each systemd socket activated fd with the handler for its FileDescriptorName, under one ServerCtx
+// sharing idle_timeout and shutdown. addr is a sysd address without name or idx, e.g. "sysd" or "sysd?idle_timeout=30m".
+// All fds with a name are served, e.g. several ListenStream= in one socket unit. Fails if a name has no fd.
+// Fds with names not in handlers are not used.
+//
+// tls option has the names of the fds to serve https on, e.g. "sysd?tls=https,admin&cert_dir=/etc/app/certs".
+// Without it, https is served on all fds when cert_dir or cert is set
+//
+//	anyhttp.ServeNamed("sysd?idle_timeout=30m", map[string]http.Handler{
+//		"public":  app,
+//		"metrics": promhttp.Handler(),
+//	})
+func ServeNamed(addr string, handlers map[string]http.Handler, opts ...Option) (*ServerCtx, error) {
+	base, rawQuery, _ := strings.Cut(addr, "?")
+	if base != "sysd" {
+		return nil, fmt.Errorf("ServeNamed needs a sysd address, got: %q", addr)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket fd address error. Bad query: %q, err: %w", rawQuery, err)
+	}
+	var tlsNames []string
+	if query.Has("tls") {
+		tlsNames = strings.Split(query.Get("tls"), ",")
+		query.Del("tls")
+	}
+	a := &address{}
+	if err := a.parseSysd(query); err != nil {
+		return nil, err
+	}
+	if a.sysc.FDName != nil || a.sysc.FDIndex != nil || a.sysc.All || a.sysc.Accepted {
+		return nil, fmt.Errorf("systemd socket fd address error. name, idx, all and accepted are not used by ServeNamed: %q", addr)
+	}
+	if a.handoff != "" || a.lock != "" {
+		return nil, fmt.Errorf("systemd socket fd address error. handoff and lock are not supported by ServeNamed: %q", addr)
+	}
+	if len(handlers) == 0 {
+		return nil, errors.New("ServeNamed needs at least one handler")
+	}
+	for _, name := range tlsNames {
+		if _, ok := handlers[name]; !ok {
+			return nil, fmt.Errorf("systemd socket fd address error. Bad tls: %q has no handler", name)
+		}
+	}
+	certFile, keyFile, err := a.srvOpts.tlsFiles("", "")
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket fd address error. %w", err)
+	}
+	if tlsNames != nil && a.srvOpts.certDir == "" && certFile == "" {
+		return nil, fmt.Errorf("systemd socket fd address error. tls needs certificates, set cert_dir or cert: %q", addr)
+	}
+	listeners, names, err := a.sysc.namedListeners(handlers)
+	if err != nil {
+		return nil, err
+	}
+	var fdTLS []bool
+	if tlsNames != nil {
+		for _, name := range names {
+			fdTLS = append(fdTLS, contains(tlsNames, name))
+		}
+	}
+	cfg := applyOptions(opts)
+	cfg.fdNames = names
+	cfg.fdTLS = fdTLS
+	return serveListener(a, listeners, namedHandler(handlers), certFile, keyFile, cfg)
+}
+
+// namedListeners creates listeners for all fds with a name in handlers. Fails for datagram fds
+func (s *SysdConfig) namedListeners(handlers map[string]http.Handler) ([]net.Listener, []string, error) {
+	if s.UnsetEnv {
+		defer UnsetSystemdListenVars()
+	}
+	envData, err := s.envData()
+	if err != nil {
+		return nil, nil, err
+	}
+	var listeners []net.Listener
+	var names []string
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+	for idx := 0; idx < envData.numFds && idx < len(envData.fdNames); idx++ {
+		name := envData.fdNames[idx]
+		if _, ok := handlers[name]; !ok {
+			continue
+		}
+		if isDatagram(StartFD + idx) {
+			closeAll()
+			return nil, nil, fmt.Errorf("fd %v, name: %q is a datagram socket, e.g. ListenDatagram=, use GetPacketConn", StartFD+idx, name)
+		}
+		if err := s.verify(StartFD+idx, name, true); err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		l, err := makeFdListener(StartFD+idx, name)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("fd %v, name: %q, err: %w", StartFD+idx, name, err)
+		}
+		listeners = append(listeners, l)
+		names = append(names, name)
+	}
+	var missing []string
+	for name := range handlers {
+		if !contains(names, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		closeAll()
+		sort.Strings(missing)
+		return nil, nil, fmt.Errorf("fdNames not found: %q, LISTEN_FDNAMES:%q", missing, envData.fdNamesStr)
+	}
+	return listeners, names, nil
+}
+
+// allListeners creates listeners for all socket activated stream fds, datagram fds are skipped. Fds without a name are
+// named sysdfd_<fd>
+func (s *SysdConfig) allListeners() ([]net.Listener, []string, error) {
+	if s.UnsetEnv {
+		defer UnsetSystemdListenVars()
+	}
+	envData, err := s.envData()
+	if err != nil {
+		return nil, nil, err
+	}
+	if envData.numFds == 0 {
+		return nil, nil, errors.New("no socket activated fds, LISTEN_FDS is 0")
+	}
+	var listeners []net.Listener
+	var names []string
+	for idx := 0; idx < envData.numFds; idx++ {
+		fd := StartFD + idx
+		if isDatagram(fd) {
+			continue
+		}
+		name := fmt.Sprintf("sysdfd_%d", fd)
+		if idx < len(envData.fdNames) {
+			name = envData.fdNames[idx]
+		}
+		err := s.verify(fd, name, true)
+		var l net.Listener
+		if err == nil {
+			l, err = makeFdListener(fd, name)
+		}
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, nil, fmt.Errorf("fd %v, name: %q, err: %w", fd, name, err)
+		}
+		listeners = append(listeners, l)
+		names = append(names, name)
+	}
+	if len(listeners) == 0 {
+		return nil, nil, errors.New("no socket activated stream fds, only datagram fds")
+	}
+	return listeners, names, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// namedHandler routes requests to the handler for the name of the fd they are received on
+type namedHandler map[string]http.Handler
+
+func (h namedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, _ := r.Context().Value(fdNameKey{}).(string)
+	h[name].ServeHTTP(w, r)
+}
+
+type fdNameKey struct{}
+
+// namedListener tags accepted connections with the fd name. Outermost wrapper, so other wrappers see the actual connection
+type namedListener struct {
+	net.Listener
+	name string
+}
+
+func (l *namedListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &namedConn{Conn: c, name: l.name}, nil
+}
+
+type namedConn struct {
+	net.Conn
+	name string
+}
+
+func (c *namedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// connContext is the http.Server.ConnContext adding the fd name and the PROXY protocol header of the connection, which
+// could be wrapped in tls.Conn
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	for {
+		switch conn := c.(type) {
+		case *namedConn:
+			ctx = context.WithValue(ctx, fdNameKey{}, conn.name)
+		case *proxyConn:
+			// Header is read later when needed, not blocking the accept loop
+			ctx = context.WithValue(ctx, proxyHeaderKey{}, conn)
+		}
+		w, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			return ctx
+		}
+		c = w.NetConn()
+	}
+}
@@ -0,0 +1,254 @@
+package anyhttp_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.balki.me/anyhttp"
+	"go.balki.me/anyhttp/anyhttptest"
+	"go.balki.me/anyhttp/devca"
+)
+
+func TestServeNamed(t *testing.T) {
+	sockets := []anyhttptest.SysdSocket{{Name: "public"}, {Name: "admin", Network: "unix"}, {Name: "public"}, {Name: "other"}}
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		})
+	}
+	anyhttptest.RunSysd(t, sockets,
+		func(ctx context.Context, t *testing.T) {
+			srv, err := anyhttp.ServeNamed("sysd", map[string]http.Handler{
+				"public": respond("app"),
+				"admin":  respond("pprof"),
+			})
+			if err != nil {
+				t.Fatalf("ServeNamed failed: %v", err)
+			}
+			if len(srv.Listeners) != 3 {
+				t.Errorf("got %v listeners, want 3", len(srv.Listeners))
+			}
+			<-ctx.Done()
+			if err := srv.Shutdown(context.Background()); err != http.ErrServerClosed {
+				t.Errorf("Shutdown() = %v", err)
+			}
+		},
+		func(t *testing.T, addrs []net.Addr) {
+			for i, want := range []string{"app", "pprof", "app"} {
+				client, baseURL := anyhttptest.NewClient(addrs[i])
+				client.Timeout = 5 * time.Second
+				resp, err := client.Get(baseURL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != want {
+					t.Errorf("fd %v: got %q, want %q", i, body, want)
+				}
+			}
+		})
+}
+
+func TestServeNamedTLS(t *testing.T) {
+	sockets := []anyhttptest.SysdSocket{{Name: "http"}, {Name: "https"}}
+	anyhttptest.RunSysd(t, sockets,
+		func(ctx context.Context, t *testing.T) {
+			ca, err := devca.Load(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			certFile, _, err := ca.CertFiles("127.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			})
+			srv, err := anyhttp.ServeNamed("sysd?tls=https&cert_dir="+filepath.Dir(certFile), map[string]http.Handler{
+				"http":  ok,
+				"https": ok,
+			})
+			if err != nil {
+				t.Fatalf("ServeNamed failed: %v", err)
+			}
+			defer srv.Server.Close()
+			<-ctx.Done()
+		},
+		func(t *testing.T, addrs []net.Addr) {
+			client := &http.Client{
+				Timeout:   5 * time.Second,
+				Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			}
+			for _, tc := range []struct {
+				url  string
+				want int
+			}{
+				{"http://" + addrs[0].String(), http.StatusOK},
+				{"https://" + addrs[1].String(), http.StatusOK},
+				{"http://" + addrs[1].String(), http.StatusBadRequest},
+			} {
+				resp, err := client.Get(tc.url)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tc.want {
+					t.Errorf("%v: got %v, want %v", tc.url, resp.StatusCode, tc.want)
+				}
+			}
+		})
+}
+
+func TestServeNamedErrors(t *testing.T) {
+	h := map[string]http.Handler{"web": http.NotFoundHandler()}
+	for _, addr := range []string{"sysd?name=web", "unix?path=app.sock", "sysd?handoff=/run/h.sock", "sysd?tls=web", "sysd?tls=api&cert_dir=/etc/certs"} {
+		if _, err := anyhttp.ServeNamed(addr, h); err == nil {
+			t.Errorf("ServeNamed(%q) should fail", addr)
+		}
+	}
+	if _, err := anyhttp.ServeNamed("sysd", nil); err == nil {
+		t.Error("ServeNamed without handlers should fail")
+	}
+}
+
+func TestServeAllSystemdFDs(t *testing.T) {
+	sockets := []anyhttptest.SysdSocket{{Name: "public"}, {Name: "admin", Network: "unix"}, {Name: "public"}}
+	anyhttptest.RunSysd(t, sockets,
+		func(ctx context.Context, t *testing.T) {
+			if _, _, _, err := anyhttp.GetListener("sysd?all=true&unset_env=false"); err == nil {
+				t.Error("GetListener should fail with all")
+			}
+			srv, err := anyhttp.ServeAllSystemdFDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			}))
+			if err != nil {
+				t.Fatalf("ServeAllSystemdFDs failed: %v", err)
+			}
+			if len(srv.Listeners) != 3 {
+				t.Errorf("got %v listeners, want 3", len(srv.Listeners))
+			}
+			<-ctx.Done()
+			if err := srv.Shutdown(context.Background()); err != http.ErrServerClosed {
+				t.Errorf("Shutdown() = %v", err)
+			}
+		},
+		func(t *testing.T, addrs []net.Addr) {
+			for i, addr := range addrs {
+				client, baseURL := anyhttptest.NewClient(addr)
+				client.Timeout = 5 * time.Second
+				resp, err := client.Get(baseURL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != "ok" {
+					t.Errorf("fd %v: got %q", i, body)
+				}
+			}
+		})
+}
+
+func TestSysdAllAddress(t *testing.T) {
+	for _, addr := range []string{"sysd?all=true&name=app", "sysd?all=true&idx=0", "sysd?all=maybe"} {
+		if _, err := anyhttp.Serve(addr, http.NotFoundHandler()); err == nil {
+			t.Errorf("Serve(%q) should fail", addr)
+		}
+	}
+	if got := anyhttp.SysdAddr().All().IdleTimeout(time.Minute).String(); got != "sysd?all=true&idle_timeout=1m0s" {
+		t.Errorf("SysdAddr().All() = %q", got)
+	}
+}
+
+func TestSysdDatagram(t *testing.T) {
+	sockets := []anyhttptest.SysdSocket{{Name: "web"}, {Name: "dns", Network: "udp"}}
+	anyhttptest.RunSysd(t, sockets,
+		func(_ context.Context, t *testing.T) {
+			if _, _, _, err := anyhttp.GetListener("sysd?name=dns&unset_env=false"); err == nil {
+				t.Error("GetListener should fail for a datagram fd")
+			}
+			if _, _, _, err := anyhttp.GetPacketConn("sysd?name=web&unset_env=false"); err == nil {
+				t.Error("GetPacketConn should fail for a stream fd")
+			}
+			pc, _, _, err := anyhttp.GetPacketConn("sysd?name=dns&unset_env=false")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			client, err := net.Dial("udp", pc.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			if _, err := client.Write([]byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 16)
+			if n, _, err := pc.ReadFrom(buf); err != nil || string(buf[:n]) != "ping" {
+				t.Errorf("ReadFrom() = %q, %v", buf[:n], err)
+			}
+
+			_, err = anyhttp.ServeNamed("sysd?unset_env=false", map[string]http.Handler{"dns": http.NotFoundHandler()})
+			if err == nil || !strings.Contains(err.Error(), "datagram") {
+				t.Errorf("ServeNamed() err = %v, want datagram socket error", err)
+			}
+
+			// Datagram fds are skipped
+			srv, err := anyhttp.ServeAllSystemdFDs(http.NotFoundHandler())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(srv.Listeners) != 1 {
+				t.Errorf("got %v listeners, want 1", len(srv.Listeners))
+			}
+			_ = srv.Shutdown(context.Background())
+		}, nil)
+}
+
+func TestSysdNetwork(t *testing.T) {
+	sockets := []anyhttptest.SysdSocket{{Name: "web"}, {Name: "api"}, {Name: "admin", Network: "unix"}, {Name: "dns", Network: "udp"}}
+	anyhttptest.RunSysd(t, sockets,
+		func(_ context.Context, t *testing.T) {
+			// Each fd is used once, failures are before using it
+			for addr, ok := range map[string]bool{
+				"sysd?name=web&network=tcp":               true,
+				"sysd?name=api&network=tcp4":              true,
+				"sysd?name=web&network=tcp6":              false,
+				"sysd?name=web&network=unix":              false,
+				"sysd?name=admin&network=unix":            true,
+				"sysd?name=admin&network=tcp":             false,
+				"sysd?name=web&network=tcp&accepted=true": false,
+			} {
+				l, _, _, err := anyhttp.GetListener(addr + "&unset_env=false")
+				if (err == nil) != ok {
+					t.Errorf("GetListener(%q) err: %v, want ok: %v", addr, err, ok)
+				}
+				if l != nil {
+					l.Close()
+				}
+			}
+			pc, _, _, err := anyhttp.GetPacketConn("sysd?name=dns&network=udp4&unset_env=false")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pc.Close()
+			if _, _, _, err := anyhttp.GetPacketConn("sysd?name=dns&network=unixgram&unset_env=false"); err == nil {
+				t.Error("GetPacketConn should fail for a udp fd with network=unixgram")
+			}
+		}, nil)
+	if _, err := anyhttp.Serve("sysd?name=web&network=sctp", http.NotFoundHandler()); err == nil {
+		t.Error("Serve should fail for bad network")
+	}
+	if got := anyhttp.SysdAddr().Name("web").Network("tcp").String(); got != "sysd?name=web&network=tcp" {
+		t.Errorf("SysdAddr().Network() = %q", got)
+	}
+}
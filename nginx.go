@@ -0,0 +1,105 @@
+package anyhttp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// isNginxListen checks if addr is in nginx listen directive syntax, e.g. unix:/run/app.sock, *:8080 ssl or 8000.
+// Plain host:port addresses are same in both syntaxes. Addresses with a query are not, so that e.g. a unix path with a
+// space works
+func isNginxListen(addr string) bool {
+	if strings.Contains(addr, "?") {
+		return false
+	}
+	fields := strings.Fields(addr)
+	if len(fields) == 0 {
+		return false
+	}
+	listen := fields[0]
+	if strings.HasPrefix(listen, "unix:") || strings.HasPrefix(listen, "*:") {
+		return true
+	}
+	if _, err := strconv.ParseUint(listen, 10, 16); err == nil {
+		return true
+	}
+	// host:port followed by parameters
+	return len(fields) > 1 && strings.Contains(listen, ":")
+}
+
+// parseNginxListen parses the value of nginx listen directive. See https://nginx.org/en/docs/http/ngx_http_core_module.html#listen
+func parseNginxListen(addr string) (*address, error) {
+	fields := strings.Fields(addr)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("nginx listen address error. Empty address")
+	}
+	listen, params := fields[0], fields[1:]
+	var base string
+	var q queryParams
+	if path, ok := strings.CutPrefix(listen, "unix:"); ok {
+		base = "unix"
+		q.set("path", path)
+	} else if _, err := strconv.ParseUint(listen, 10, 16); err == nil {
+		base = ":" + listen
+	} else if port, ok := strings.CutPrefix(listen, "*:"); ok {
+		base = ":" + port
+	} else {
+		base = listen
+	}
+
+	requireTLS := false
+	for _, param := range params {
+		key, val, _ := strings.Cut(param, "=")
+		switch key {
+		case "ssl":
+			requireTLS = true
+		case "default_server", "default", "bind", "deferred", "http2":
+			// Not applicable. http2 is enabled by default with TLS
+		case "reuseport":
+			q.set("reuseport", "true")
+		case "backlog":
+			q.set("backlog", val)
+		case "so_keepalive":
+			// on is the go default. keepidle:keepintvl:keepcnt form is not supported
+			if val == "off" {
+				q.set("keepalive", "0")
+			} else if val != "on" {
+				return nil, fmt.Errorf("nginx listen address error. Unsupported so_keepalive: %q", val)
+			}
+		case "rcvbuf", "sndbuf":
+			size, err := parseNginxSize(val)
+			if err != nil {
+				return nil, fmt.Errorf("nginx listen address error. Bad %v: %v, err: %w", key, val, err)
+			}
+			q.set(key, strconv.Itoa(size))
+		default:
+			return nil, fmt.Errorf("nginx listen address error. Unsupported parameter: %q", param)
+		}
+	}
+	a, err := parseAddr(q.encode(base))
+	if err != nil {
+		return nil, err
+	}
+	a.requireTLS = requireTLS
+	return a, nil
+}
+
+// parseNginxSize parses sizes like 512, 64k or 1m
+func parseNginxSize(size string) (int, error) {
+	mult := 1
+	switch {
+	case strings.HasSuffix(size, "k"), strings.HasSuffix(size, "K"):
+		mult = 1024
+	case strings.HasSuffix(size, "m"), strings.HasSuffix(size, "M"):
+		mult = 1024 * 1024
+	}
+	if mult != 1 {
+		size = size[:len(size)-1]
+	}
+	n, err := strconv.Atoi(size)
+	if err != nil {
+		return 0, err
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,63 @@
+package anyhttp
+
+import "testing"
+
+func TestNginxListen(t *testing.T) {
+	tests := []struct {
+		addr       string
+		wantType   AddressType
+		wantTCP    string
+		wantPath   string
+		wantTLS    bool
+		wantRcvBuf int
+		wantErr    bool
+	}{
+		{addr: "127.0.0.1:8000", wantType: TCP, wantTCP: "127.0.0.1:8000"},
+		{addr: "8000", wantType: TCP, wantTCP: ":8000"},
+		{addr: "*:8080 ssl", wantType: TCP, wantTCP: ":8080", wantTLS: true},
+		{addr: "[::]:80 default_server rcvbuf=64k", wantType: TCP, wantTCP: "[::]:80", wantRcvBuf: 64 * 1024},
+		{addr: "unix:/var/run/app.sock", wantType: UnixSocket, wantPath: "/var/run/app.sock"},
+		{addr: "unix:/var/run/app.sock ssl http2", wantType: UnixSocket, wantPath: "/var/run/app.sock", wantTLS: true},
+		{addr: "*:8080 quic", wantErr: true},
+		{addr: "*:8080 rcvbuf=lots", wantErr: true},
+		{addr: "*:8080 backlog=lots", wantErr: true},
+		{addr: "*:8080 so_keepalive=30m::10", wantErr: true},
+	}
+	for _, tt := range tests {
+		a, err := parseAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAddr(%q) err: %v, wantErr: %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var path string
+		if a.usc != nil {
+			path = a.usc.SocketPath
+		}
+		if a.addrType != tt.wantType || a.tcpAddr != tt.wantTCP || path != tt.wantPath || a.requireTLS != tt.wantTLS || a.sockOpts.readBuffer != tt.wantRcvBuf {
+			t.Errorf("parseAddr(%q) = %+v, usc: %v", tt.addr, a, a.usc)
+		}
+	}
+
+	a, err := parseAddr("*:8080 reuseport backlog=511 so_keepalive=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (tcpOptions{reusePort: true, backlog: 511, keepAlive: -1}); a.tcpOpts != want {
+		t.Errorf("tcpOpts = %+v, want %+v", a.tcpOpts, want)
+	}
+
+	if _, err := Serve("*:0 ssl", nil); err == nil {
+		t.Error("Serve() should fail for address with ssl")
+	}
+
+	// Spaces in the query are not nginx syntax
+	if a, err := parseAddr("unix?path=/tmp/my app.sock"); err != nil || a.usc.SocketPath != "/tmp/my app.sock" {
+		t.Errorf("parseAddr() unix path with space, err: %v", err)
+	}
+	if a, err := parseAddr("sysd?name=my app"); err != nil || a.sysc.FDName == nil || *a.sysc.FDName != "my app" {
+		t.Errorf("parseAddr() sysd name with space, err: %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+module go.balki.me/anyhttp/npipe
+
+go 1.21
+
+require (
+	github.com/Microsoft/go-winio v0.6.2
+	go.balki.me/anyhttp v0.0.0
+)
+
+require golang.org/x/sys v0.10.0 // indirect
+
+replace go.balki.me/anyhttp => ../
@@ -0,0 +1,71 @@
+// Package npipe adds the npipe address scheme to anyhttp, to serve on a Windows named pipe, e.g. for local clients
+// without opening a port, similar to unix sockets. Import for side effects
+//
+//	import _ "go.balki.me/anyhttp/npipe"
+//
+//	anyhttp.Serve(`npipe?path=\\.\pipe\myapp`, h)
+//	anyhttp.Serve("npipe?path=myapp&sddl="+url.QueryEscape("D:P(A;;GA;;;BA)(A;;GA;;;SY)"), h)
+package npipe
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"go.balki.me/anyhttp"
+)
+
+// Scheme is the address scheme, also the anyhttp.AddressType of the listeners
+const Scheme = "npipe"
+
+// Prefix of named pipe paths on the local machine
+const pipePrefix = `\\.\pipe\`
+
+func init() {
+	anyhttp.RegisterScheme(Scheme, listen)
+}
+
+// Config has the options of the npipe scheme
+type Config struct {
+	// Pipe path, e.g. \\.\pipe\myapp. \\.\pipe\ is added if missing
+	Path string
+	// Security descriptor in SDDL format controlling who can connect. Defaults to the Windows default for pipes, i.e.
+	// full control for the creator, SYSTEM and administrators, read for everyone
+	SDDL string
+}
+
+// Listen creates the named pipe listener. Windows only
+func Listen(cfg Config) (net.Listener, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("npipe address error. Missing path")
+	}
+	path := cfg.Path
+	if !strings.HasPrefix(path, `\\`) {
+		path = pipePrefix + path
+	}
+	l, err := listenPipe(path, cfg.SDDL)
+	if err != nil {
+		return nil, fmt.Errorf("npipe address error. Failed to listen on %v, err: %w", path, err)
+	}
+	return l, nil
+}
+
+func listen(query url.Values) (net.Listener, error) {
+	var cfg Config
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("npipe address error. Multiple %v found: %v", key, val)
+		}
+		switch key {
+		case "path":
+			cfg.Path = val[0]
+		case "sddl":
+			cfg.SDDL = val[0]
+		default:
+			return nil, fmt.Errorf("npipe address error. Bad option; key: %v, val: %v", key, val)
+		}
+	}
+	return Listen(cfg)
+}
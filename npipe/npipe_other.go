@@ -0,0 +1,12 @@
+//go:build !windows
+
+package npipe
+
+import (
+	"errors"
+	"net"
+)
+
+func listenPipe(path, sddl string) (net.Listener, error) {
+	return nil, errors.New("named pipes are only supported on windows")
+}
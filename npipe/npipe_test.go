@@ -0,0 +1,21 @@
+package npipe
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+
+	"go.balki.me/anyhttp"
+)
+
+func TestListenErrors(t *testing.T) {
+	addrs := []string{"npipe", "npipe?path=a&foo=bar", "npipe?path=a&path=b"}
+	if runtime.GOOS != "windows" {
+		addrs = append(addrs, "npipe?path=myapp")
+	}
+	for _, addr := range addrs {
+		if _, err := anyhttp.Serve(addr, http.NotFoundHandler()); err == nil {
+			t.Errorf("Serve(%q) should fail", addr)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package npipe
+
+import (
+	"net"
+
+	"github.com/Microsoft/go-winio"
+)
+
+func listenPipe(path, sddl string) (net.Listener, error) {
+	return winio.ListenPipe(path, &winio.PipeConfig{SecurityDescriptor: sddl})
+}
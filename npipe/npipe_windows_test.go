@@ -0,0 +1,42 @@
+package npipe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"go.balki.me/anyhttp"
+)
+
+func TestServe(t *testing.T) {
+	name := fmt.Sprintf("anyhttp-test-%d", time.Now().UnixNano())
+	ctx, err := anyhttp.Serve("npipe?path="+name, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("over pipe"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if ctx.AddressType != Scheme {
+		t.Errorf("AddressType = %v, want %v", ctx.AddressType, Scheme)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return winio.DialPipeContext(ctx, pipePrefix+name)
+		},
+	}}
+	resp, err := client.Get("http://app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "over pipe" {
+		t.Errorf("got %q, want over pipe", body)
+	}
+}
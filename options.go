@@ -0,0 +1,162 @@
+package anyhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Option configures the server created by Serve and ServeTLS
+type Option func(*serveConfig)
+
+type serveConfig struct {
+	warmup func(ctx context.Context) error
+	// Names of the listeners and whether to serve https on them, set by ServeNamed
+	fdNames []string
+	fdTLS   []bool
+	// Set by ServeAll, which notifies systemd once all servers are ready
+	deferNotify bool
+	// Checked before each systemd watchdog ping
+	health func(ctx context.Context) error
+	// Called on SIGHUP
+	reload ReloadFunc
+	// Reload certificates on SIGHUP, without reload
+	reloadSignal bool
+	// http.Server fields, 0 or nil keeps the default
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	tlsConfig    *tls.Config
+	logger       *log.Logger
+	// Called last, before serving
+	configure func(*http.Server)
+	// Drain on SIGINT and SIGTERM, with drainTimeout instead of shutdown_timeout if set
+	shutdownSignals bool
+	drainTimeout    time.Duration
+}
+
+func applyOptions(opts []Option) serveConfig {
+	var cfg serveConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithReadTimeout sets http.Server.ReadTimeout
+func WithReadTimeout(d time.Duration) Option {
+	return func(c *serveConfig) {
+		c.readTimeout = d
+	}
+}
+
+// WithWriteTimeout sets http.Server.WriteTimeout
+func WithWriteTimeout(d time.Duration) Option {
+	return func(c *serveConfig) {
+		c.writeTimeout = d
+	}
+}
+
+// WithIdleTimeout sets http.Server.IdleTimeout, how long keep-alive connections are kept open between requests. Not to
+// be confused with idle_timeout of systemd addresses, which sets it too unless given
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *serveConfig) {
+		c.idleTimeout = d
+	}
+}
+
+// WithTLSConfig serves https with config, e.g. with Certificates or GetCertificate set. Certificates from cert_dir and
+// Hosts are tried first. config is cloned
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *serveConfig) {
+		c.tlsConfig = config
+	}
+}
+
+// WithLogger sets http.Server.ErrorLog, which anyhttp logs to as well. Takes precedence over the journal option
+func WithLogger(logger *log.Logger) Option {
+	return func(c *serveConfig) {
+		c.logger = logger
+	}
+}
+
+// WithServer calls fn with the created http.Server before serving, to set fields without an option, e.g.
+// MaxHeaderBytes or ReadHeaderTimeout. Handler, ConnState and ConnContext are used by anyhttp, replacing them disables
+// features like idle_timeout and max_conns
+func WithServer(fn func(*http.Server)) Option {
+	return func(c *serveConfig) {
+		c.configure = fn
+	}
+}
+
+// WithWarmup runs fn after the listener is bound and before requests are handled, e.g. to prime caches or connection
+// pools. Serve returns without waiting for it. Requests received meanwhile are held until fn returns. If fn fails, the
+// server is closed and Done reports the error. ctx is canceled when the server is shutdown
+func WithWarmup(fn func(ctx context.Context) error) Option {
+	return func(c *serveConfig) {
+		c.warmup = fn
+	}
+}
+
+// WithShutdownSignals shuts the server down gracefully on SIGINT or SIGTERM. Done reports nil once drained, so that
+// Wait can be returned from main. Remaining connections are closed after drainTimeout, 0 uses shutdown_timeout
+func WithShutdownSignals(drainTimeout time.Duration) Option {
+	return func(c *serveConfig) {
+		c.shutdownSignals = true
+		c.drainTimeout = drainTimeout
+	}
+}
+
+// handleShutdownSignals drains on SIGINT or SIGTERM, until the server stops serving
+func (s *ServerCtx) handleShutdownSignals() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case <-s.stopped:
+		case got := <-sig:
+			s.infof("anyhttp: received %v, shutting down", got)
+			s.requestDrain()
+		}
+	}()
+}
+
+// startWarmup runs the warmup func, closing warmupDone once done
+func (s *ServerCtx) startWarmup(fn func(ctx context.Context) error) {
+	s.warmupDone = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Server.RegisterOnShutdown(cancel)
+	go func() {
+		defer cancel()
+		if err := fn(ctx); err != nil {
+			s.warmupErr = fmt.Errorf("warmup failed: %w", err)
+			close(s.warmupDone)
+			s.logf("anyhttp: %v", s.warmupErr)
+			_ = s.Server.Close()
+			return
+		}
+		close(s.warmupDone)
+	}()
+}
+
+// serveErr returns the warmup error instead of http.ErrServerClosed if the server was closed due to it
+func (s *ServerCtx) serveErr(err error) error {
+	if s.warmupDone == nil {
+		return err
+	}
+	select {
+	case <-s.warmupDone:
+		if s.warmupErr != nil {
+			return s.warmupErr
+		}
+	default:
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package anyhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.balki.me/anyhttp/devca"
+)
+
+func TestWithWarmup(t *testing.T) {
+	release := make(chan struct{})
+	ctx, err := Serve("127.0.0.1:0", text("ok"), WithWarmup(func(context.Context) error {
+		<-release
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+
+	done := make(chan string)
+	go func() {
+		_, body := get(t, "http://"+ctx.Addr().String())
+		done <- body
+	}()
+	select {
+	case <-done:
+		t.Fatal("request should be held until warmup is done")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if body := <-done; body != "ok" {
+		t.Errorf("got %q, want ok", body)
+	}
+}
+
+func TestWithWarmupError(t *testing.T) {
+	errWarmup := errors.New("cache unavailable")
+	ctx, err := Serve("127.0.0.1:0", text("ok"), WithWarmup(func(context.Context) error {
+		return errWarmup
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-ctx.Done:
+		if !errors.Is(err, errWarmup) {
+			t.Errorf("Done = %v, want %v", err, errWarmup)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server should be closed when warmup fails")
+	}
+	if _, err := http.Get("http://" + ctx.Addr().String()); err == nil {
+		t.Error("request should fail after warmup failed")
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	ctx, err := Serve("127.0.0.1:0", text("ok"), WithReadTimeout(time.Second), WithWriteTimeout(2*time.Second),
+		WithIdleTimeout(3*time.Second), WithLogger(logger), WithServer(func(s *http.Server) { s.MaxHeaderBytes = 4096 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	s := ctx.Server
+	if s.ReadTimeout != time.Second || s.WriteTimeout != 2*time.Second || s.IdleTimeout != 3*time.Second || s.MaxHeaderBytes != 4096 {
+		t.Errorf("got ReadTimeout: %v, WriteTimeout: %v, IdleTimeout: %v, MaxHeaderBytes: %v", s.ReadTimeout, s.WriteTimeout,
+			s.IdleTimeout, s.MaxHeaderBytes)
+	}
+	ctx.logf("hello %v", "logger")
+	if got := buf.String(); got != "hello logger\n" {
+		t.Errorf("logged %q, want it in the logger", got)
+	}
+}
+
+func TestLogfWithoutServer(t *testing.T) {
+	var buf bytes.Buffer
+	logOutput := log.Writer()
+	defer log.SetOutput(logOutput)
+	log.SetOutput(&buf)
+	// e.g. logging while the server is being set up
+	ctx := &ServerCtx{journalLog: log.New(io.Discard, "", 0)}
+	ctx.logf("hello %v", "logger")
+	if !strings.Contains(buf.String(), "hello logger") {
+		t.Errorf("logged %q, want it in the standard logger", buf.String())
+	}
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	ca, err := devca.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := Serve("127.0.0.1:0?tls=true", text("ok"), WithTLSConfig(ca.TLSConfig()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	if _, err := ServeTLSConfig("127.0.0.1:0", nil, nil); err == nil {
+		t.Error("ServeTLSConfig should fail without a config")
+	}
+	viaServe, err := ServeTLSConfig("127.0.0.1:0", text("ok"), ca.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viaServe.Server.Close()
+	if !strings.HasPrefix(viaServe.URL(), "https://") {
+		t.Errorf("URL() = %q, want https", viaServe.URL())
+	}
+	if !strings.HasPrefix(ctx.URL(), "https://") {
+		t.Errorf("URL() = %q, want https", ctx.URL())
+	}
+	resp, err := client.Get(ctx.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestWithShutdownSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can not be sent to self on windows")
+	}
+	ctx, err := Serve("127.0.0.1:0", text("ok"), WithShutdownSignals(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.shutdownTimeout != time.Second {
+		t.Errorf("shutdownTimeout = %v, want 1s", ctx.shutdownTimeout)
+	}
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-ctx.Done:
+		if err != nil {
+			t.Errorf("Done = %v, want nil after drain", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shutdown on SIGTERM")
+	}
+}
+
+func TestOptionsAppliedOnce(t *testing.T) {
+	applied := 0
+	count := func(*serveConfig) { applied++ }
+	opts := make([]Option, 1, 2)
+	opts[0] = count
+	ctx, err := ServeTLSConfig("127.0.0.1:0", text("ok"), &tls.Config{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if applied != 1 {
+		t.Errorf("option applied %v times, want once", applied)
+	}
+}
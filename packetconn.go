@@ -0,0 +1,120 @@
+package anyhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+)
+
+// UDPConfig has the configuration for udp packet conn
+type UDPConfig struct {
+	// host:port to listen on, e.g. :5353 or 127.0.0.1:514
+	Addr string `json:"addr" yaml:"addr"`
+}
+
+// GetPacketConn returns the udp packet conn
+func (u *UDPConfig) GetPacketConn() (net.PacketConn, error) {
+	return net.ListenPacket("udp", u.Addr)
+}
+
+// parseUDP parses udp?addr=:5353
+func (a *address) parseUDP(query url.Values) (*address, error) {
+	a.udpc = &UDPConfig{}
+	a.addrType = UDP
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("udp address error. Multiple %v found: %v", key, val)
+		}
+		if key == "addr" {
+			a.udpc.Addr = val[0]
+		} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return nil, fmt.Errorf("udp address error. %w", cerr)
+		} else if !ok {
+			return nil, fmt.Errorf("udp address error. Bad option; key: %v, val: %v", key, val)
+		}
+	}
+	if a.udpc.Addr == "" {
+		return nil, errors.New("udp address error. Missing addr")
+	}
+	return a, nil
+}
+
+// GetPacketConn is the counterpart of GetListener for datagram servers, e.g. dns, syslog, QUIC. Supports udp addresses,
+// unix addresses as unixgram sockets and systemd socket activated datagram fds, e.g. ListenDatagram=
+//
+//	udp?addr=:5353
+//	unix?path=/run/app/log.sock
+//	sysd?name=dns.socket
+func GetPacketConn(addr string) (net.PacketConn, AddressType, any /* cfg */, error) {
+	a, err := parseAddr(addr)
+	if err != nil {
+		return nil, Unknown, nil, err
+	}
+	if a.handoff != "" || a.lock != "" {
+		return nil, Unknown, nil, fmt.Errorf("handoff and lock are not supported by GetPacketConn: %q", addr)
+	}
+	var pc net.PacketConn
+	switch {
+	case a.udpc != nil:
+		pc, err = retryBind(a.bindRetry, a.udpc.GetPacketConn)
+	case a.usc != nil:
+		pc, err = retryBind(a.bindRetry, a.usc.GetPacketConn)
+	case a.sysc != nil:
+		pc, err = a.sysc.GetPacketConn()
+	default:
+		return nil, Unknown, nil, fmt.Errorf("address type %v is not supported by GetPacketConn: %q", a.addrType, addr)
+	}
+	if err != nil {
+		return nil, Unknown, nil, err
+	}
+	a.sockOpts.apply(pc)
+	return pc, a.addrType, a.cfg(), nil
+}
+
+// GetPacketConn returns the unixgram packet conn
+func (u *UnixSocketConfig) GetPacketConn() (net.PacketConn, error) {
+	if u.SocketType != "" && u.SocketType != "dgram" {
+		return nil, fmt.Errorf("unix socket of type %v is not a datagram socket, use GetListener", u.SocketType)
+	}
+	if u.isAbstract() {
+		return net.ListenPacket("unixgram", u.SocketPath)
+	}
+	if err := u.removeExisting(); err != nil {
+		return nil, err
+	}
+	pc, err := net.ListenPacket("unixgram", u.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(u.SocketPath, u.SocketMode); err != nil {
+		pc.Close()
+		return nil, err
+	}
+	return pc, nil
+}
+
+// GetPacketConn returns the FilePacketConn created with socket activated datagram fd
+func (s *SysdConfig) GetPacketConn() (net.PacketConn, error) {
+	if s.UnsetEnv {
+		defer UnsetSystemdListenVars()
+	}
+	fd, name, err := s.fd()
+	if err != nil {
+		return nil, err
+	}
+	if !isDatagram(fd) {
+		return nil, fmt.Errorf("fd %v, name: %q is not a datagram socket, use GetListener", fd, name)
+	}
+	if err := s.verify(fd, name, false); err != nil {
+		return nil, err
+	}
+	fdFile := os.NewFile(uintptr(fd), name)
+	pc, err := net.FilePacketConn(fdFile)
+	if err != nil {
+		return nil, err
+	}
+	closeOnExec(fd)
+	return pc, nil
+}
@@ -0,0 +1,155 @@
+package anyhttp
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// echo replies to one datagram received on pc and returns the response read by client
+func echo(t *testing.T, pc net.PacketConn, client net.Conn) string {
+	t.Helper()
+	go func() {
+		buf := make([]byte, 64)
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		_, _ = pc.WriteTo(append([]byte("echo "), buf[:n]...), from)
+	}()
+	return echoConn(t, client)
+}
+
+// echoConn sends ping on c and returns the response
+func echoConn(t *testing.T, c net.Conn) string {
+	t.Helper()
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestGetPacketConnUDP(t *testing.T) {
+	pc, addrType, cfg, err := GetPacketConn("udp?addr=127.0.0.1:0&rcvbuf=65536")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if addrType != UDP {
+		t.Errorf("addrType = %v, want UDP", addrType)
+	}
+	if udpc, ok := cfg.(*UDPConfig); !ok || udpc.Addr != "127.0.0.1:0" {
+		t.Errorf("cfg = %#v", cfg)
+	}
+	client, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if got := echo(t, pc, client); got != "echo ping" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestGetPacketConnUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unixgram on windows")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.sock")
+	pc, addrType, _, err := GetPacketConn("unix?path=" + path + "&mode=600")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if addrType != UnixSocket {
+		t.Errorf("addrType = %v, want UnixSocket", addrType)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode: %v, err: %v", fi, err)
+	}
+	clientPath := filepath.Join(dir, "client.sock")
+	client, err := net.DialUnix("unixgram", &net.UnixAddr{Name: clientPath, Net: "unixgram"}, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if got := echo(t, pc, client); got != "echo ping" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestGetPacketConnErrors(t *testing.T) {
+	for _, addr := range []string{"udp", "udp?addr=:0&foo=bar", "udp?addr=:0&handoff=/tmp/h.sock", ":0", "vsock?port=80"} {
+		if pc, _, _, err := GetPacketConn(addr); err == nil {
+			pc.Close()
+			t.Errorf("GetPacketConn(%q) should fail", addr)
+		}
+	}
+	if _, err := Serve("udp?addr=127.0.0.1:0", text("ok")); err == nil {
+		t.Error("Serve on udp address should fail")
+	}
+}
+
+func TestUnixSocketType(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("seqpacket unix sockets are tested on linux only")
+	}
+	dir := t.TempDir()
+	seqAddr := UnixAddr(filepath.Join(dir, "seq.sock")).Type("seqpacket").String()
+	l, addrType, _, err := GetListener(seqAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if addrType != UnixSocket || l.Addr().Network() != "unixpacket" {
+		t.Errorf("GetListener(%q) = %v, %v", seqAddr, addrType, l.Addr().Network())
+	}
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		buf := make([]byte, 64)
+		n, _ := c.Read(buf)
+		_, _ = c.Write(append([]byte("echo "), buf[:n]...))
+	}()
+	c, err := DialContext(context.Background(), seqAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got := echoConn(t, c); got != "echo ping" {
+		t.Errorf("got %q", got)
+	}
+
+	pc, _, cfg, err := GetPacketConn("unix?path=" + filepath.Join(dir, "dgram.sock") + "&type=dgram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc.Close()
+	if usc := cfg.(*UnixSocketConfig); usc.SocketType != "dgram" {
+		t.Errorf("SocketType = %q, want dgram", usc.SocketType)
+	}
+
+	if _, _, _, err := GetListener("unix?path=" + filepath.Join(dir, "a.sock") + "&type=dgram"); err == nil {
+		t.Error("GetListener should fail for dgram")
+	}
+	if _, _, _, err := GetPacketConn(seqAddr); err == nil {
+		t.Error("GetPacketConn should fail for seqpacket")
+	}
+	if _, err := parseAddr("unix?path=a.sock&type=raw"); err == nil {
+		t.Error("parseAddr should fail for bad type")
+	}
+}
@@ -0,0 +1,126 @@
+package anyhttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sniffTimeout is the time to wait for the first byte of a connection
+const sniffTimeout = 10 * time.Second
+
+// recordTypeHandshake is the first byte of a TLS connection
+const recordTypeHandshake = 0x16
+
+// redirectListener is used beneath the TLS listener. Plain http requests are redirected to https instead of failing the
+// TLS handshake. Connections are sniffed in their own goroutine, so that slow clients do not block Accept
+type redirectListener struct {
+	net.Listener
+	conns     chan acceptResult
+	startOnce sync.Once
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type acceptResult struct {
+	c   net.Conn
+	err error
+}
+
+func newRedirectListener(l net.Listener) *redirectListener {
+	return &redirectListener{Listener: l, conns: make(chan acceptResult), closed: make(chan struct{})}
+}
+
+func (l *redirectListener) Accept() (net.Conn, error) {
+	l.startOnce.Do(func() { go l.acceptLoop() })
+	select {
+	case r := <-l.conns:
+		return r.c, r.err
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *redirectListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+func (l *redirectListener) acceptLoop() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.conns <- acceptResult{err: err}:
+			case <-l.closed:
+				return
+			}
+			if isRetriedAcceptError(err) {
+				continue
+			}
+			return
+		}
+		go l.sniff(c)
+	}
+}
+
+// isRetriedAcceptError is true if Accept is called again after err, by resilientListener or http.Server.Serve
+func isRetriedAcceptError(err error) bool {
+	var ne net.Error
+	// Temporary is deprecated, but it is what http.Server.Serve checks
+	return isOutOfMemory(err) || errors.As(err, &ne) && ne.Temporary()
+}
+
+func (l *redirectListener) sniff(c net.Conn) {
+	br := bufio.NewReader(c)
+	_ = c.SetReadDeadline(time.Now().Add(sniffTimeout))
+	first, err := br.Peek(1)
+	if err != nil {
+		c.Close()
+		return
+	}
+	if first[0] != recordTypeHandshake {
+		redirectToHTTPS(c, br)
+		return
+	}
+	_ = c.SetReadDeadline(time.Time{})
+	select {
+	case l.conns <- acceptResult{c: &peekedConn{Conn: c, r: br}}:
+	case <-l.closed:
+		c.Close()
+	}
+}
+
+func redirectToHTTPS(c net.Conn, br *bufio.Reader) {
+	defer c.Close()
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+	host := req.Host
+	if host == "" {
+		host = c.LocalAddr().String()
+	}
+	_ = c.SetWriteDeadline(time.Now().Add(sniffTimeout))
+	fmt.Fprintf(c, "HTTP/1.1 308 Permanent Redirect\r\nLocation: https://%s%s\r\nConnection: close\r\nContent-Length: 0\r\n\r\n",
+		host, req.URL.RequestURI())
+}
+
+// peekedConn reads the sniffed bytes first
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// NetConn returns the accepted connection
+func (c *peekedConn) NetConn() net.Conn {
+	return c.Conn
+}
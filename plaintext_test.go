@@ -0,0 +1,60 @@
+package anyhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+
+	"go.balki.me/anyhttp/devca"
+)
+
+func TestRedirectHTTP(t *testing.T) {
+	ca, err := devca.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile, err := ca.CertFiles("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noRedirect := func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	for _, tc := range []struct {
+		addr     string
+		wantCode int
+	}{
+		{"127.0.0.1:0?redirect_http=true", http.StatusPermanentRedirect},
+		{"127.0.0.1:0", http.StatusBadRequest},
+	} {
+		ctx, err := ServeTLS(tc.addr, text("ok"), certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ctx.Addr().String()
+		resp, err := (&http.Client{CheckRedirect: noRedirect}).Get("http://" + addr + "/path?q=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.wantCode {
+			t.Errorf("%v: got %v, want %v", tc.addr, resp.StatusCode, tc.wantCode)
+		}
+		if loc := resp.Header.Get("Location"); tc.wantCode == http.StatusPermanentRedirect && loc != "https://"+addr+"/path?q=1" {
+			t.Errorf("Location = %q", loc)
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.Certificate())
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+		resp, err = client.Get("https://" + addr)
+		if err != nil {
+			t.Fatalf("%v: https request failed: %v", tc.addr, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%v: got %v over https, want 200", tc.addr, resp.StatusCode)
+		}
+		ctx.Server.Close()
+	}
+}
@@ -0,0 +1,62 @@
+package anyhttp
+
+import (
+	"time"
+)
+
+// defaultProgressInterval is used when a progress callback is set without drain_progress option
+const defaultProgressInterval = 5 * time.Second
+
+// DrainProgress is the state of connections while shutdown is in progress
+type DrainProgress struct {
+	// Time since shutdown started
+	Elapsed time.Duration
+	// Open connections, including idle keep-alive connections
+	Conns int
+	// Connections with a request in progress
+	Active int
+	// Age of the oldest request in progress. 0 if there are none
+	OldestRequest time.Duration
+	// Hijacked connections not closed yet, e.g. websockets. Tracked only with hijack_timeout option
+	Hijacked int
+}
+
+// OnDrainProgress sets a callback to be called periodically while shutdown is in progress, instead of logging.
+// Interval is set by the drain_progress option, defaults to 5s
+func (s *ServerCtx) OnDrainProgress(fn func(DrainProgress)) {
+	s.progressFn.Store(&fn)
+}
+
+// reportProgress reports drain progress until the returned func is called
+func (s *ServerCtx) reportProgress() (stop func()) {
+	fn := s.progressFn.Load()
+	interval := s.progressInterval
+	if interval == 0 && fn != nil {
+		interval = defaultProgressInterval
+	}
+	if interval <= 0 || s.conns == nil {
+		return func() {}
+	}
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				p := s.conns.progress(now)
+				p.Elapsed = now.Sub(start)
+				if fn != nil {
+					(*fn)(p)
+				} else {
+					s.infof("anyhttp: shutting down for %v, open connections: %v, active: %v, oldest request: %v, hijacked: %v",
+						p.Elapsed.Round(time.Second), p.Conns, p.Active, p.OldestRequest.Round(time.Second), p.Hijacked)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
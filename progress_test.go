@@ -0,0 +1,42 @@
+package anyhttp
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDrainProgress(t *testing.T) {
+	started := make(chan struct{})
+	blocked := make(chan struct{})
+	ctx, err := Serve("127.0.0.1:0?drain_progress=10ms", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-blocked
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := make(chan DrainProgress, 100)
+	ctx.OnDrainProgress(func(p DrainProgress) {
+		progress <- p
+	})
+	go func() {
+		resp, err := http.Get("http://" + ctx.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	drained := make(chan error)
+	go func() {
+		drained <- ctx.drain()
+	}()
+	p := <-progress
+	if p.Conns != 1 || p.Active != 1 || p.OldestRequest <= 0 || p.Elapsed <= 0 {
+		t.Errorf("unexpected progress: %+v", p)
+	}
+	close(blocked)
+	if err := <-drained; err != nil {
+		t.Errorf("drain() failed: %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package anyhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time to wait for the PROXY protocol header after the connection is accepted
+const proxyHeaderTimeout = 5 * time.Second
+
+// PROXY protocol v2 TLV types. See https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+const (
+	ProxyTLVALPN      = 0x01
+	ProxyTLVAuthority = 0x02
+	ProxyTLVUniqueID  = 0x05
+	ProxyTLVAWS       = 0xEA
+
+	proxyAWSVPCEndpointID = 0x01
+)
+
+var proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyHeader has the original connection metadata sent using PROXY protocol by load balancers like HAProxy or AWS NLB
+type ProxyHeader struct {
+	// 1 or 2
+	Version int
+	// Addresses of the original connection. Nil for health checks of the load balancer, i.e. LOCAL or UNKNOWN
+	Source      net.Addr
+	Destination net.Addr
+	// Raw v2 TLVs by type
+	TLVs map[byte][]byte
+}
+
+// TLV returns the value of v2 TLV typ
+func (h *ProxyHeader) TLV(typ byte) ([]byte, bool) {
+	val, ok := h.TLVs[typ]
+	return val, ok
+}
+
+// ALPN returns the protocol negotiated by the client with the load balancer, e.g. h2
+func (h *ProxyHeader) ALPN() string {
+	return string(h.TLVs[ProxyTLVALPN])
+}
+
+// Authority returns the host name sent by the client, usually SNI
+func (h *ProxyHeader) Authority() string {
+	return string(h.TLVs[ProxyTLVAuthority])
+}
+
+// UniqueID returns the connection id generated by the load balancer
+func (h *ProxyHeader) UniqueID() []byte {
+	return h.TLVs[ProxyTLVUniqueID]
+}
+
+// AWSVPCEndpointID returns the id of the VPC endpoint the connection was received from, with AWS PrivateLink
+func (h *ProxyHeader) AWSVPCEndpointID() string {
+	val := h.TLVs[ProxyTLVAWS]
+	if len(val) == 0 || val[0] != proxyAWSVPCEndpointID {
+		return ""
+	}
+	return string(val[1:])
+}
+
+type proxyHeaderKey struct{}
+
+// ProxyHeaderFromContext returns the PROXY protocol header of the connection the request was received on, with
+// proxy_protocol option. Pass r.Context() of the request
+func ProxyHeaderFromContext(ctx context.Context) (*ProxyHeader, bool) {
+	c, ok := ctx.Value(proxyHeaderKey{}).(*proxyConn)
+	if !ok {
+		return nil, false
+	}
+	if err := c.readHeader(); err != nil {
+		return nil, false
+	}
+	return c.header, true
+}
+
+// proxyListener expects PROXY protocol header on all connections. Connections without it fail
+type proxyListener struct {
+	net.Listener
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: c, r: bufio.NewReader(c)}, nil
+}
+
+// proxyConn reads the header on first use, in the goroutine serving the connection and not in Accept
+type proxyConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	once   sync.Once
+	header *ProxyHeader
+	err    error
+}
+
+func (c *proxyConn) readHeader() error {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.header, c.err = parseProxyHeader(c.r)
+		if c.err != nil {
+			c.err = fmt.Errorf("bad PROXY protocol header from %v, err: %w", c.Conn.RemoteAddr(), c.err)
+			return
+		}
+		_ = c.Conn.SetReadDeadline(time.Time{})
+	})
+	return c.err
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	if err := c.readHeader(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.readHeader() == nil && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	if c.readHeader() == nil && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func parseProxyHeader(r *bufio.Reader) (*ProxyHeader, error) {
+	sig, err := r.Peek(len(proxyV2Sig))
+	if err == nil && bytes.Equal(sig, proxyV2Sig) {
+		return parseProxyV2(r)
+	}
+	if prefix, perr := r.Peek(6); perr == nil && string(prefix) == "PROXY " {
+		return parseProxyV1(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, errors.New("missing header")
+}
+
+// parseProxyV1 parses the text header, e.g. PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n
+func parseProxyV1(r *bufio.Reader) (*ProxyHeader, error) {
+	// Max length of v1 header is 107 bytes
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	text, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errors.New("v1 header too long or not terminated by CRLF")
+	}
+	fields := strings.Split(text, " ")
+	h := &ProxyHeader{Version: 1}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return h, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("bad v1 header: %q", text)
+	}
+	src, err := proxyV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := proxyV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	h.Source, h.Destination = src, dst
+	return h, nil
+}
+
+func proxyV1Addr(ip, port string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, fmt.Errorf("bad v1 address: %q", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("bad v1 port: %q", port)
+	}
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+// parseProxyV2 parses the binary header, with the addresses and TLVs
+func parseProxyV2(r *bufio.Reader) (*ProxyHeader, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, err
+	}
+	if fixed[12]>>4 != 2 {
+		return nil, fmt.Errorf("bad v2 version: %v", fixed[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	h := &ProxyHeader{Version: 2}
+	var addrLen int
+	switch fam := fixed[13]; fam >> 4 {
+	case 0x0: // AF_UNSPEC
+	case 0x1: // AF_INET
+		addrLen = 12
+	case 0x2: // AF_INET6
+		addrLen = 36
+	case 0x3: // AF_UNIX
+		addrLen = 216
+	default:
+		return nil, fmt.Errorf("bad v2 address family: %#x", fam)
+	}
+	if len(payload) < addrLen {
+		return nil, fmt.Errorf("v2 header too short for addresses: %v", len(payload))
+	}
+	switch cmd := fixed[12] & 0xf; cmd {
+	case 0x0: // LOCAL, e.g. health checks. Addresses are ignored
+	case 0x1: // PROXY
+		h.Source, h.Destination = proxyV2Addrs(fixed[13], payload[:addrLen])
+	default:
+		return nil, fmt.Errorf("bad v2 command: %#x", cmd)
+	}
+	tlvs := payload[addrLen:]
+	for len(tlvs) > 0 {
+		if len(tlvs) < 3 {
+			return nil, errors.New("truncated v2 TLV")
+		}
+		typ, n := tlvs[0], int(binary.BigEndian.Uint16(tlvs[1:3]))
+		if len(tlvs) < 3+n {
+			return nil, fmt.Errorf("truncated v2 TLV %#x", typ)
+		}
+		if h.TLVs == nil {
+			h.TLVs = map[byte][]byte{}
+		}
+		h.TLVs[typ] = tlvs[3 : 3+n]
+		tlvs = tlvs[3+n:]
+	}
+	return h, nil
+}
+
+func proxyV2Addrs(fam byte, addrs []byte) (src, dst net.Addr) {
+	transport := fam & 0xf
+	switch fam >> 4 {
+	case 0x1, 0x2:
+		n := 4
+		if fam>>4 == 0x2 {
+			n = 16
+		}
+		srcIP, dstIP := net.IP(addrs[:n]), net.IP(addrs[n:2*n])
+		srcPort, dstPort := int(binary.BigEndian.Uint16(addrs[2*n:])), int(binary.BigEndian.Uint16(addrs[2*n+2:]))
+		if transport == 0x2 {
+			return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}
+		}
+		return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}
+	case 0x3:
+		name := func(b []byte) string {
+			if i := bytes.IndexByte(b, 0); i >= 0 {
+				return string(b[:i])
+			}
+			return string(b)
+		}
+		return &net.UnixAddr{Name: name(addrs[:108]), Net: "unix"}, &net.UnixAddr{Name: name(addrs[108:]), Net: "unix"}
+	}
+	return nil, nil
+}
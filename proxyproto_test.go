@@ -0,0 +1,127 @@
+package anyhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func proxyV2Header(cmd byte, tlvs ...[]byte) []byte {
+	var b bytes.Buffer
+	b.Write(proxyV2Sig)
+	b.WriteByte(0x20 | cmd)
+	b.WriteByte(0x11) // TCP over IPv4
+	var payload bytes.Buffer
+	payload.Write(net.ParseIP("192.0.2.1").To4())
+	payload.Write(net.ParseIP("198.51.100.1").To4())
+	_ = binary.Write(&payload, binary.BigEndian, uint16(56324))
+	_ = binary.Write(&payload, binary.BigEndian, uint16(443))
+	for _, tlv := range tlvs {
+		payload.Write(tlv)
+	}
+	_ = binary.Write(&b, binary.BigEndian, uint16(payload.Len()))
+	b.Write(payload.Bytes())
+	return b.Bytes()
+}
+
+func tlv(typ byte, val string) []byte {
+	return append([]byte{typ, byte(len(val) >> 8), byte(len(val))}, val...)
+}
+
+func TestParseProxyHeader(t *testing.T) {
+	h, err := parseProxyHeader(bufio.NewReader(strings.NewReader("PROXY TCP6 2001:db8::1 2001:db8::2 1234 443\r\nGET")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != 1 || h.Source.String() != "[2001:db8::1]:1234" || h.Destination.String() != "[2001:db8::2]:443" {
+		t.Errorf("v1 header = %+v", h)
+	}
+
+	v2 := proxyV2Header(0x1, tlv(ProxyTLVALPN, "h2"), tlv(ProxyTLVAuthority, "app.example.com"),
+		tlv(ProxyTLVUniqueID, "id-1"), tlv(ProxyTLVAWS, "\x01vpce-0123"), tlv(0xE0, "custom"))
+	h, err = parseProxyHeader(bufio.NewReader(bytes.NewReader(v2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != 2 || h.Source.String() != "192.0.2.1:56324" || h.Destination.String() != "198.51.100.1:443" {
+		t.Errorf("v2 header = %+v", h)
+	}
+	if h.ALPN() != "h2" || h.Authority() != "app.example.com" || string(h.UniqueID()) != "id-1" || h.AWSVPCEndpointID() != "vpce-0123" {
+		t.Errorf("v2 TLVs = %q", h.TLVs)
+	}
+	if val, ok := h.TLV(0xE0); !ok || string(val) != "custom" {
+		t.Errorf("TLV(0xE0) = %q, %v", val, ok)
+	}
+
+	h, err = parseProxyHeader(bufio.NewReader(bytes.NewReader(proxyV2Header(0x0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Source != nil || h.Destination != nil {
+		t.Errorf("LOCAL should not have addresses: %+v", h)
+	}
+
+	for _, bad := range []string{
+		"GET / HTTP/1.1\r\n",
+		"PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n",
+		"PROXY TCP4 bad 198.51.100.1 56324 443\r\n",
+		"PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n",
+		string(proxyV2Header(0x1, []byte{0xE0, 0, 10})),
+		string(proxyV2Header(0x2)),
+	} {
+		if _, err := parseProxyHeader(bufio.NewReader(strings.NewReader(bad))); err == nil {
+			t.Errorf("parseProxyHeader(%q) should fail", bad)
+		}
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0?proxy_protocol=true", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hdr, ok := ProxyHeaderFromContext(r.Context())
+		if !ok {
+			http.Error(w, "no header", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "%v %v", r.RemoteAddr, hdr.Authority())
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+
+	c, err := net.Dial("tcp", ctx.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	req := append(proxyV2Header(0x1, tlv(ProxyTLVAuthority, "app.example.com")), "GET / HTTP/1.1\r\nHost: app\r\n\r\n"...)
+	if _, err := c.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "192.0.2.1:56324 app.example.com" {
+		t.Errorf("got %q", body)
+	}
+
+	// Without the header
+	resp, err = http.Get("http://" + ctx.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("request without PROXY protocol header got %v, want 400", resp.Status)
+	}
+}
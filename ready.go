@@ -0,0 +1,64 @@
+package anyhttp
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// readyFile is created once the server is ready to handle requests and removed when it stops serving, for supervisors
+// and health checks watching the file system
+type readyFile struct {
+	path string
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+// startReadyFile creates the ready file now, or after warmup if set. A stale file from a previous run is removed first
+func (s *ServerCtx) startReadyFile(path string) error {
+	s.ready = &readyFile{path: path}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if s.warmupDone == nil {
+		return s.ready.create()
+	}
+	go func() {
+		<-s.warmupDone
+		if s.warmupErr != nil {
+			return
+		}
+		if err := s.ready.create(); err != nil {
+			s.logf("anyhttp: failed to create ready_file: %v", err)
+		}
+	}()
+	return nil
+}
+
+// create writes the pid to the file. Written to a temporary file and renamed, so watchers never see a partial file
+func (r *readyFile) create() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return nil
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (r *readyFile) remove() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	_ = os.Remove(r.path)
+}
@@ -0,0 +1,62 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReadyFile(t *testing.T) {
+	readyPath := filepath.Join(t.TempDir(), "ready")
+	ctx, err := Serve("127.0.0.1:0?ready_file="+readyPath, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(readyPath)
+	if err != nil {
+		t.Fatalf("ready_file should be created once serving: %v", err)
+	}
+	if string(data) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("ready_file = %q, want pid", data)
+	}
+	ctx.Shutdown(context.Background())
+	if _, err := os.Stat(readyPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ready_file should be removed on shutdown, err: %v", err)
+	}
+}
+
+func TestReadyFileWarmup(t *testing.T) {
+	readyPath := filepath.Join(t.TempDir(), "ready")
+	// Stale file from a previous run
+	if err := os.WriteFile(readyPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	ctx, err := Serve("127.0.0.1:0?ready_file="+readyPath, text("ok"), WithWarmup(func(context.Context) error {
+		<-release
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if _, err := os.Stat(readyPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ready_file should not exist during warmup, err: %v", err)
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(readyPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ready_file should be created after warmup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,97 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ReloadFunc reloads the app, e.g. reads the config again. The returned handler replaces the current one for new
+// requests, nil keeps it. If it fails, the server continues with the current handler
+type ReloadFunc func(ctx context.Context) (http.Handler, error)
+
+// WithReload calls fn on SIGHUP, so that `systemctl reload` works with ExecReload=kill -HUP $MAINPID or Type=notify-reload.
+// RELOADING=1 with MONOTONIC_USEC is sent to systemd before and READY=1 after. cert_dir and cert files are loaded again
+// too. See ServerCtx.Reload
+func WithReload(fn ReloadFunc) Option {
+	return func(c *serveConfig) {
+		c.reload = fn
+	}
+}
+
+// WithReloadSignal reloads certificates in cert_dir and cert files on SIGHUP, e.g. from a certbot deploy hook, without
+// waiting for cert_rescan. Not needed with WithReload
+func WithReloadSignal() Option {
+	return func(c *serveConfig) {
+		c.reloadSignal = true
+	}
+}
+
+// Reload runs the ReloadFunc passed to WithReload and loads cert_dir and changed cert files again, same as on SIGHUP. Reloads are not run
+// concurrently. Returns the error from ReloadFunc or the scan
+func (s *ServerCtx) Reload(ctx context.Context) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if s.reload == nil && len(s.certs) == 0 {
+		return errors.New("nothing to reload, use WithReload, cert_dir or cert")
+	}
+	s.sendNotify(reloadingState())
+	err := s.runReload(ctx)
+	if err != nil {
+		s.logf("anyhttp: reload failed, continuing with current config: %v", err)
+		s.sendNotify("READY=1\nSTATUS=Reload failed: " + err.Error())
+		return err
+	}
+	s.infof("anyhttp: reloaded")
+	s.sendNotify("READY=1\nSTATUS=Reloaded")
+	return nil
+}
+
+func (s *ServerCtx) runReload(ctx context.Context) error {
+	if s.reload != nil {
+		h, err := s.reload(ctx)
+		if err != nil {
+			return err
+		}
+		if h != nil {
+			s.handler.Store(&h)
+		}
+	}
+	for _, c := range s.certs {
+		if err := c.scan(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// currentHandler serves requests with h until it is replaced on reload
+func (s *ServerCtx) currentHandler(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	s.handler.Store(&h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		(*s.handler.Load()).ServeHTTP(w, r)
+	})
+}
+
+// handleReloadSignal reloads on SIGHUP until the server stops serving
+func (s *ServerCtx) handleReloadSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-s.stopped:
+				return
+			case <-sig:
+				_ = s.Reload(context.Background())
+			}
+		}
+	}()
+}
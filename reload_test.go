@@ -0,0 +1,105 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	conn := notifySocket(t)
+	var version atomic.Int32
+	version.Store(1)
+	ctx, err := Serve("127.0.0.1:0", text("v1"), WithReload(func(context.Context) (http.Handler, error) {
+		switch version.Add(1) {
+		case 2:
+			return text("v2"), nil
+		case 3:
+			return nil, nil
+		}
+		return nil, errors.New("bad config")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+
+	if err := ctx.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := readNotify(t, conn, 5*time.Second)
+	state, usec, found := strings.Cut(got, "\nMONOTONIC_USEC=")
+	if state != "RELOADING=1" || runtime.GOOS == "linux" && !found {
+		t.Errorf("got %q, want RELOADING=1 with MONOTONIC_USEC", got)
+	}
+	if n, err := strconv.ParseUint(usec, 10, 64); found && (err != nil || n == 0) {
+		t.Errorf("bad MONOTONIC_USEC: %q", usec)
+	}
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1\nSTATUS=Reloaded" {
+		t.Errorf("got %q, want READY=1 with status", got)
+	}
+	if _, got := get(t, "http://"+ctx.Addr().String()); got != "v2" {
+		t.Errorf("got %q after reload, want v2", got)
+	}
+
+	// nil handler keeps the current one
+	if err := ctx.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, got := get(t, "http://"+ctx.Addr().String()); got != "v2" {
+		t.Errorf("got %q, want v2", got)
+	}
+
+	if err := ctx.Reload(context.Background()); err == nil {
+		t.Fatal("Reload should fail")
+	}
+	if _, got := get(t, "http://"+ctx.Addr().String()); got != "v2" {
+		t.Errorf("got %q after failed reload, want v2", got)
+	}
+}
+
+func TestReloadSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP on windows")
+	}
+	reloaded := make(chan struct{}, 1)
+	ctx, err := Serve("127.0.0.1:0", text("v1"), WithReload(func(context.Context) (http.Handler, error) {
+		reloaded <- struct{}{}
+		return text("v2"), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reloaded on SIGHUP")
+	}
+}
+
+func TestReloadNothing(t *testing.T) {
+	ctx, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if err := ctx.Reload(context.Background()); err == nil {
+		t.Error("Reload should fail without WithReload or cert_dir")
+	}
+}
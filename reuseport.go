@@ -0,0 +1,25 @@
+package anyhttp
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+// listenReusePort listens with SO_REUSEPORT, or SO_REUSEPORT_LB on FreeBSD, so that many listeners can bind the same
+// address and the kernel load balances new connections across them
+func listenReusePort(network, addr string) (net.Listener, error) {
+	lc := net.ListenConfig{Control: reusePortControl}
+	return lc.Listen(context.Background(), network, addr)
+}
+
+// reusePortControl is the net.ListenConfig.Control setting SO_REUSEPORT before bind
+func reusePortControl(_, _ string, c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = setReusePort(fd)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,10 @@
+package anyhttp
+
+import "syscall"
+
+// SO_REUSEPORT_LB, FreeBSD 12+. Plain SO_REUSEPORT on FreeBSD only allows binding, connections are not load balanced
+const soReusePortLB = 0x10000
+
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePortLB, 1)
+}
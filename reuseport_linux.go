@@ -0,0 +1,10 @@
+package anyhttp
+
+import "syscall"
+
+// SO_REUSEPORT, not in syscall package for linux
+const soReusePort = 0xf
+
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !freebsd && !darwin && !dragonfly && !netbsd && !openbsd
+
+package anyhttp
+
+import "errors"
+
+func setReusePort(fd uintptr) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
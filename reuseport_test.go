@@ -0,0 +1,40 @@
+//go:build linux || freebsd
+
+package anyhttp
+
+import "testing"
+
+func TestListenReusePort(t *testing.T) {
+	l1, err := listenReusePort("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+	l2, err := listenReusePort("tcp", l1.Addr().String())
+	if err != nil {
+		t.Fatalf("second listener on %v should succeed, err: %v", l1.Addr(), err)
+	}
+	l2.Close()
+}
+
+func TestReusePortOption(t *testing.T) {
+	ctx1, err := Serve("127.0.0.1:0?reuseport=true", text("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx1.Server.Close()
+	ctx2, err := Serve(ctx1.Addr().String()+"?reuseport=true", text("two"))
+	if err != nil {
+		t.Fatalf("second server on %v should succeed, err: %v", ctx1.Addr(), err)
+	}
+	ctx2.Server.Close()
+	for _, addr := range []string{"127.0.0.1:0?reuseport=maybe", "unix?path=/tmp/app.sock&reuseport=true"} {
+		if _, err := parseAddr(addr); err == nil {
+			t.Errorf("parseAddr(%q) should fail", addr)
+		}
+	}
+	a, err := parseAddr("127.0.0.1:8080 reuseport")
+	if err != nil || !a.tcpOpts.reusePort {
+		t.Errorf("nginx reuseport should be parsed, err: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package anyhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// S6Config has the configuration for a listening fd passed by a supervisor following the s6 conventions, e.g. by
+// s6-ipcserver-socketbinder, s6-tcpserver-socketbinder or s6-fdholder-retrieve. Also works with runit and others that exec
+// the daemon with the socket already bound. Only one of Env and FD can be set, fd 0 (stdin) is used when neither is set
+type S6Config struct {
+
+	// Environment variable with the fd number, e.g. S6_FD
+	Env string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// fd number of the listening socket
+	FD int `json:"fd,omitempty" yaml:"fd,omitempty"`
+}
+
+// String returns the canonical address of the config, which can be passed back to Serve, GetListener etc.
+func (s S6Config) String() string {
+	q := s.params()
+	return q.encode("s6")
+}
+
+func (s S6Config) params() queryParams {
+	var q queryParams
+	if s.Env != "" {
+		q.set("env", s.Env)
+	} else if s.FD != 0 {
+		q.set("fd", strconv.Itoa(s.FD))
+	}
+	return q
+}
+
+// fd returns the fd number from Env if set, else FD
+func (s *S6Config) fd() (int, error) {
+	if s.Env == "" {
+		return s.FD, nil
+	}
+	val, ok := os.LookupEnv(s.Env)
+	if !ok {
+		return 0, fmt.Errorf("s6 fd error. $%v not set", s.Env)
+	}
+	fd, err := strconv.Atoi(val)
+	if err != nil || fd < 0 {
+		return 0, fmt.Errorf("s6 fd error. Bad $%v: %q, err: %v", s.Env, val, err)
+	}
+	return fd, nil
+}
+
+// GetListener returns the FileListener created with the passed fd
+func (s *S6Config) GetListener() (net.Listener, error) {
+	fd, err := s.fd()
+	if err != nil {
+		return nil, err
+	}
+	return makeFdListener(fd, fmt.Sprintf("s6fd_%d", fd))
+}
+
+// parseS6 parses s6?env=S6_FD or s6?fd=3
+func (a *address) parseS6(query url.Values) (*address, error) {
+	a.s6c = &S6Config{}
+	a.addrType = S6FD
+	hasFD := false
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("s6 address error. Multiple %v found: %v", key, val)
+		}
+		if key == "env" {
+			if val[0] == "" {
+				return nil, errors.New("s6 address error. Bad env: empty name")
+			}
+			a.s6c.Env = val[0]
+		} else if key == "fd" {
+			fd, err := strconv.Atoi(val[0])
+			if err != nil || fd < 0 {
+				return nil, fmt.Errorf("s6 address error. Bad fd: %v, err: %v", val, err)
+			}
+			a.s6c.FD, hasFD = fd, true
+		} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return nil, fmt.Errorf("s6 address error. %w", cerr)
+		} else if !ok {
+			return nil, fmt.Errorf("s6 address error. Bad option; key: %v, val: %v", key, val)
+		}
+	}
+	if hasFD && a.s6c.Env != "" {
+		return nil, errors.New("s6 address error. Only one of env and fd can be set")
+	}
+	return a, nil
+}
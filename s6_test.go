@@ -0,0 +1,49 @@
+package anyhttp
+
+import "testing"
+
+func TestParseS6(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    S6Config
+		wantErr bool
+	}{
+		{addr: "s6", want: S6Config{}},
+		{addr: "s6?env=S6_FD", want: S6Config{Env: "S6_FD"}},
+		{addr: "s6?fd=4&idle_timeout=1m", wantErr: true},
+		{addr: "s6?fd=4&rcvbuf=65536", want: S6Config{FD: 4}},
+		{addr: "s6?env=", wantErr: true},
+		{addr: "s6?fd=-1", wantErr: true},
+		{addr: "s6?fd=x", wantErr: true},
+		{addr: "s6?env=S6_FD&fd=3", wantErr: true},
+	}
+	for _, tt := range tests {
+		a, err := parseAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAddr(%q) err: %v, wantErr: %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if a.addrType != S6FD || *a.s6c != tt.want {
+			t.Errorf("parseAddr(%q) = %v, %+v, want %+v", tt.addr, a.addrType, a.s6c, tt.want)
+		}
+		if got := a.s6c.String(); got != tt.want.String() {
+			t.Errorf("String() = %q, want %q", got, tt.want.String())
+		}
+	}
+}
+
+func TestS6ListenerConfig(t *testing.T) {
+	var l ListenerConfig
+	if err := l.UnmarshalText([]byte("s6?env=S6_FD")); err != nil {
+		t.Fatal(err)
+	}
+	if l.S6 == nil || l.S6.Env != "S6_FD" {
+		t.Fatalf("S6 = %+v", l.S6)
+	}
+	if got := l.String(); got != "s6?env=S6_FD" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestS6Serve(t *testing.T) {
+	tl, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := tl.File()
+	tl.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Like the fd passed by the supervisor, owned by the listener once served
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_S6_FD", strconv.Itoa(fd))
+
+	ctx, err := Serve("s6?env=TEST_S6_FD", text("from s6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if ctx.AddressType != S6FD {
+		t.Errorf("AddressType = %v, want S6FD", ctx.AddressType)
+	}
+	resp, err := http.Get("http://" + ctx.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "from s6" {
+		t.Errorf("got %q", body)
+	}
+
+	if _, err := Serve("s6?env=TEST_S6_UNSET", text("ok")); err == nil {
+		t.Error("Serve should fail when env is not set")
+	}
+}
@@ -0,0 +1,56 @@
+package anyhttp
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+)
+
+// ListenFunc creates the listener for an address of a registered scheme. query has the options of the address except
+// the ones supported by all address types. If the listener has a SetShutdownTimeout(time.Duration) method, it is called
+// with shutdown_timeout, e.g. to give a helper process stopped on Close as long to finish the requests in progress
+type ListenFunc func(query url.Values) (net.Listener, error)
+
+var schemes sync.Map // map[string]ListenFunc
+
+// builtinSchemes are parsed by parseAddr before registered schemes, so they can not be registered
+var builtinSchemes = []string{"unix", "sysd", "s6", "tcp4", "tcp6", "udp", "vsock", "alias", "port_env"}
+
+// RegisterScheme adds support for addresses starting with name, e.g. wg?config=/etc/app/wg.conf. Typically called from
+// init of the package implementing the scheme, so that importing it is enough. The address type is AddressType(name).
+// Panics if name is already registered or is one of the builtin schemes
+func RegisterScheme(name string, listen ListenFunc) {
+	if name == "" || contains(builtinSchemes, name) {
+		panic(fmt.Sprintf("anyhttp: can not register builtin scheme: %q", name))
+	}
+	if _, loaded := schemes.LoadOrStore(name, listen); loaded {
+		panic(fmt.Sprintf("anyhttp: scheme already registered: %q", name))
+	}
+}
+
+func lookupScheme(name string) (ListenFunc, bool) {
+	listen, ok := schemes.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return listen.(ListenFunc), true
+}
+
+func (a *address) parseScheme(name string, query url.Values) (*address, error) {
+	a.addrType = AddressType(name)
+	a.schemeQuery = url.Values{}
+	for key, val := range query {
+		if len(val) != 1 {
+			// Repeated options are for the scheme to handle
+			a.schemeQuery[key] = val
+			continue
+		}
+		if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return nil, fmt.Errorf("%v address error. %w", name, cerr)
+		} else if !ok {
+			a.schemeQuery[key] = val
+		}
+	}
+	return a, nil
+}
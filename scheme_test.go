@@ -0,0 +1,50 @@
+package anyhttp
+
+import (
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestRegisterScheme(t *testing.T) {
+	var got url.Values
+	RegisterScheme("testscheme", func(query url.Values) (net.Listener, error) {
+		got = query
+		return net.Listen("tcp", "127.0.0.1:0")
+	})
+	l, addrType, _, err := GetListener("testscheme?foo=bar&nodelay=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if addrType != "testscheme" {
+		t.Errorf("addrType = %v, want testscheme", addrType)
+	}
+	if len(got) != 1 || got.Get("foo") != "bar" {
+		t.Errorf("query = %v, want only scheme options", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterScheme should panic for duplicate scheme")
+		}
+	}()
+	RegisterScheme("testscheme", nil)
+}
+
+func TestBuiltinSchemes(t *testing.T) {
+	for _, name := range builtinSchemes {
+		// Parsed by parseAddr, not as a tcp address
+		if a, err := parseAddr(name); err == nil && a.addrType == TCP {
+			t.Errorf("parseAddr(%q) is a tcp address, not the builtin scheme", name)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterScheme(%q) should panic for builtin scheme", name)
+				}
+			}()
+			RegisterScheme(name, nil)
+		}()
+	}
+}
@@ -0,0 +1,135 @@
+package anyhttp
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends state to the service manager listening on path, i.e. $NOTIFY_SOCKET, e.g. READY=1. Abstract socket names
+// start with @, which net handles
+func sdNotify(path, state string) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// notifyReady sends READY=1 now, or after warmup if set. Not sent if warmup fails, systemd stops the unit as the server exits
+func (s *ServerCtx) notifyReady() {
+	if s.warmupDone == nil {
+		s.sendNotify(readyState())
+		return
+	}
+	go func() {
+		<-s.warmupDone
+		if s.warmupErr == nil {
+			s.sendNotify(readyState())
+		}
+	}()
+}
+
+// sendNotify sends state to systemd, no-op without NOTIFY_SOCKET
+func (s *ServerCtx) sendNotify(state string) {
+	if s.notifySocket == "" {
+		return
+	}
+	if err := sdNotify(s.notifySocket, state); err != nil {
+		s.logf("anyhttp: failed to notify systemd %v: %v", state, err)
+	}
+}
+
+// reloadingState is RELOADING=1 with MONOTONIC_USEC, which systemd needs for Type=notify-reload
+func reloadingState() string {
+	if usec := monotonicUsec(); usec != 0 {
+		return "RELOADING=1\nMONOTONIC_USEC=" + strconv.FormatUint(usec, 10)
+	}
+	return "RELOADING=1"
+}
+
+// notifyReady sends READY=1 once all servers are ready, instead of each server on its own
+func (m *MultiServerCtx) notifyReady() {
+	notify := false
+	for _, s := range m.Servers {
+		if s.notifySocket == "" {
+			continue
+		}
+		notify = true
+		if s.warmupDone != nil {
+			<-s.warmupDone
+			if s.warmupErr != nil {
+				return
+			}
+		}
+	}
+	if notify {
+		m.Servers[0].sendNotify(readyState())
+	}
+}
+
+// notifyStopping sends STOPPING=1 with status once, when shutdown begins, so that systemd and the journal show the exit is
+// intentional
+func (s *ServerCtx) notifyStopping(status string) {
+	if s.notifySocket == "" {
+		return
+	}
+	s.stopping.Do(func() {
+		s.sendNotify("STOPPING=1\nSTATUS=" + status)
+	})
+}
+
+// WithWatchdog checks health before each WATCHDOG=1 ping sent to systemd, e.g. that a database ping or a request to the
+// handler works. Pings are skipped while it fails, so that systemd restarts a wedged service after WatchdogSec. ctx times
+// out at half the interval. Without it, pings are sent as long as the server is running
+func WithWatchdog(health func(ctx context.Context) error) Option {
+	return func(c *serveConfig) {
+		c.health = health
+	}
+}
+
+// watchdogInterval returns the interval systemd expects WATCHDOG=1 within, i.e. WatchdogSec. 0 if the watchdog is not
+// enabled for this process
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseUint(os.Getenv("WATCHDOG_USEC"), 10, 63)
+	if err != nil || usec == 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// startWatchdog sends WATCHDOG=1 at half the interval until the server stops serving
+func (s *ServerCtx) startWatchdog(interval time.Duration, health func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for {
+			if err := checkHealth(health, interval/2); err != nil {
+				s.logf("anyhttp: health check failed, not sending watchdog ping: %v", err)
+			} else {
+				s.sendNotify("WATCHDOG=1")
+			}
+			select {
+			case <-s.stopped:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func checkHealth(health func(ctx context.Context) error, timeout time.Duration) error {
+	if health == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return health(ctx)
+}
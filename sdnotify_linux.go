@@ -0,0 +1,17 @@
+package anyhttp
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const clockMonotonic = 1
+
+// monotonicUsec returns CLOCK_MONOTONIC in microseconds, as expected in MONOTONIC_USEC. 0 if it fails
+func monotonicUsec() uint64 {
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockMonotonic, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0
+	}
+	return uint64(ts.Sec)*1e6 + uint64(ts.Nsec)/1e3
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package anyhttp
+
+// monotonicUsec returns 0, Type=notify-reload is only on linux
+func monotonicUsec() uint64 {
+	return 0
+}
@@ -0,0 +1,189 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// notifySocket listens on a unixgram socket set as NOTIFY_SOCKET
+func notifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("no unixgram on windows")
+	}
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// readNotify returns the next state sent, or "" if nothing is sent within timeout
+func readNotify(t *testing.T, conn *net.UnixConn, timeout time.Duration) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return ""
+	}
+	return string(buf[:n])
+}
+
+func TestNotifyReady(t *testing.T) {
+	conn := notifySocket(t)
+	ctx, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+}
+
+func TestNotifyReadyAfterWarmup(t *testing.T) {
+	conn := notifySocket(t)
+	release := make(chan struct{})
+	ctx, err := Serve("127.0.0.1:0", text("ok"), WithWarmup(func(context.Context) error {
+		<-release
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if got := readNotify(t, conn, 100*time.Millisecond); got != "" {
+		t.Errorf("got %q before warmup is done", got)
+	}
+	close(release)
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+}
+
+func TestNotifyDisabled(t *testing.T) {
+	conn := notifySocket(t)
+	ctx, err := Serve("127.0.0.1:0?sd_notify=false", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if got := readNotify(t, conn, 100*time.Millisecond); got != "" {
+		t.Errorf("got %q with sd_notify=false", got)
+	}
+	if _, err := parseAddr("127.0.0.1:0?sd_notify=maybe"); err == nil {
+		t.Error("parseAddr should fail for bad sd_notify")
+	}
+}
+
+func TestNotifyReadyServeAll(t *testing.T) {
+	conn := notifySocket(t)
+	m, err := ServeAll([]string{"127.0.0.1:0", "127.0.0.1:0"}, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+	if got := readNotify(t, conn, 100*time.Millisecond); got != "" {
+		t.Errorf("got %q, want READY=1 only once", got)
+	}
+}
+
+func TestNotifyStopping(t *testing.T) {
+	conn := notifySocket(t)
+	ctx, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+	_ = ctx.Shutdown(context.Background())
+	if got := readNotify(t, conn, 5*time.Second); got != "STOPPING=1\nSTATUS=Shutting down" {
+		t.Errorf("got %q, want STOPPING=1", got)
+	}
+	_ = ctx.Server.Close()
+	if got := readNotify(t, conn, 100*time.Millisecond); got != "" {
+		t.Errorf("got %q, want STOPPING=1 only once", got)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	conn := notifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "100000")
+	ctx, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pings := 0
+	for pings < 3 {
+		got := readNotify(t, conn, 5*time.Second)
+		if got == "WATCHDOG=1" {
+			pings++
+		} else if got != "READY=1" {
+			t.Fatalf("got %q, want WATCHDOG=1", got)
+		}
+	}
+	_ = ctx.Shutdown(context.Background())
+	if got := readNotify(t, conn, 5*time.Second); got != "STOPPING=1\nSTATUS=Shutting down" {
+		t.Errorf("got %q, want STOPPING=1", got)
+	}
+	for got := readNotify(t, conn, 200*time.Millisecond); got != ""; got = readNotify(t, conn, 200*time.Millisecond) {
+		// A ping could be in flight while shutting down
+		if pings++; pings > 4 {
+			t.Fatalf("got %q after shutdown", got)
+		}
+	}
+}
+
+func TestWatchdogHealth(t *testing.T) {
+	conn := notifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "100000")
+	var healthy atomic.Bool
+	ctx, err := Serve("127.0.0.1:0", text("ok"), WithWatchdog(func(context.Context) error {
+		if !healthy.Load() {
+			return errors.New("db down")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+	if got := readNotify(t, conn, 200*time.Millisecond); got != "" {
+		t.Errorf("got %q while unhealthy", got)
+	}
+	healthy.Store(true)
+	if got := readNotify(t, conn, 5*time.Second); got != "WATCHDOG=1" {
+		t.Errorf("got %q, want WATCHDOG=1", got)
+	}
+}
+
+func TestWatchdogOtherPID(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "100000")
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := watchdogInterval(); got != 0 {
+		t.Errorf("watchdogInterval() = %v for other pid", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if got := watchdogInterval(); got != 100*time.Millisecond {
+		t.Errorf("watchdogInterval() = %v, want 100ms", got)
+	}
+}
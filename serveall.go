@@ -0,0 +1,107 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// MultiServerCtx has the servers started by ServeAll, one per address
+type MultiServerCtx struct {
+	Servers []*ServerCtx
+
+	// closed after all servers are done, err is set before
+	done chan struct{}
+	err  error
+}
+
+// ServeAll serves h on all the addresses, e.g. a unix socket for a local proxy and a tcp port for health checks.
+// Each address gets its own server with its options. When any of them stops, e.g. on idle_timeout or an error, the
+// rest are shut down too. opts are applied to each server
+//
+//	srv, err := anyhttp.ServeAll([]string{"unix?path=/run/app.sock", ":8080"}, h)
+//	...
+//	err = srv.Wait()
+func ServeAll(addrs []string, h http.Handler, opts ...Option) (*MultiServerCtx, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("ServeAll needs at least one address")
+	}
+	// Bad addresses fail before anything is bound
+	for _, addr := range addrs {
+		if _, err := parseAddr(addr); err != nil {
+			return nil, err
+		}
+	}
+	m := &MultiServerCtx{done: make(chan struct{})}
+	opts = append(opts[:len(opts):len(opts)], func(c *serveConfig) { c.deferNotify = true })
+	for _, addr := range addrs {
+		ctx, err := Serve(addr, h, opts...)
+		if err != nil {
+			for _, s := range m.Servers {
+				_ = s.Server.Close()
+				_ = s.Wait()
+			}
+			return nil, fmt.Errorf("failed to serve %q, err: %w", addr, err)
+		}
+		m.Servers = append(m.Servers, ctx)
+	}
+	go m.wait()
+	go m.notifyReady()
+	return m, nil
+}
+
+func (m *MultiServerCtx) wait() {
+	errs := make(chan error, len(m.Servers))
+	for _, s := range m.Servers {
+		go func(s *ServerCtx) {
+			errs <- s.Wait()
+		}(s)
+	}
+	m.err = <-errs
+	for _, s := range m.Servers {
+		go func(s *ServerCtx) {
+			_ = s.drain()
+		}(s)
+	}
+	for range m.Servers[1:] {
+		<-errs
+	}
+	close(m.done)
+}
+
+// Wait waits for all servers to stop and returns the error of the first one that stopped
+func (m *MultiServerCtx) Wait() error {
+	<-m.done
+	return m.err
+}
+
+// Shutdown gracefully shuts down all servers, see ServerCtx.Shutdown
+func (m *MultiServerCtx) Shutdown(ctx context.Context) error {
+	errs := make(chan error, len(m.Servers))
+	for _, s := range m.Servers {
+		go func(s *ServerCtx) {
+			errs <- s.shutdown(ctx)
+		}(s)
+	}
+	var err error
+	for range m.Servers {
+		if serr := <-errs; serr != nil && err == nil {
+			err = serr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return m.Wait()
+}
+
+// Addrs returns the addresses of all servers, in the order passed to ServeAll
+func (m *MultiServerCtx) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(m.Servers))
+	for i, s := range m.Servers {
+		addrs[i] = s.Addr()
+	}
+	return addrs
+}
@@ -0,0 +1,80 @@
+package anyhttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeAll(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "app.sock")
+	m, err := ServeAll([]string{"unix?path=" + sockPath, "127.0.0.1:0"}, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Servers) != 2 || m.Servers[0].AddressType != UnixSocket || m.Servers[1].AddressType != TCP {
+		t.Fatalf("Servers = %v", m.Servers)
+	}
+	addrs := m.Addrs()
+	for _, addr := range []string{"unix?path=" + sockPath, addrs[1].String()} {
+		client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return DialContext(ctx, addr)
+		}}}
+		resp, err := client.Get("http://app/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" {
+			t.Errorf("%v: got %q", addr, body)
+		}
+	}
+	if err := m.Shutdown(context.Background()); err != http.ErrServerClosed {
+		t.Errorf("Shutdown() = %v", err)
+	}
+}
+
+func TestServeAllStopsRest(t *testing.T) {
+	m, err := ServeAll([]string{"127.0.0.1:0", "127.0.0.1:0"}, text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Servers[0].Server.Close()
+	select {
+	case <-m.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("other servers should be shut down when one stops")
+	}
+	if err := m.Wait(); err != http.ErrServerClosed {
+		t.Errorf("Wait() = %v", err)
+	}
+}
+
+func TestServeAllError(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "app.sock")
+	if _, err := ServeAll([]string{"unix?path=" + sockPath, "127.0.0.1:0?foo=bar"}, text("ok")); err == nil {
+		t.Fatal("ServeAll should fail for bad address")
+	}
+	blocker, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocker.Server.Close()
+	if _, err := ServeAll([]string{"unix?path=" + sockPath, blocker.Addr().String()}, text("ok")); err == nil {
+		t.Fatal("ServeAll should fail when an address is in use")
+	}
+	// First server is closed, so the socket can be used again
+	ctx, err := Serve("unix?path="+sockPath+"&remove_existing=false", text("ok"))
+	if err != nil {
+		t.Fatalf("socket should be released: %v", err)
+	}
+	ctx.Server.Close()
+	if _, err := ServeAll(nil, text("ok")); err == nil {
+		t.Error("ServeAll should fail without addresses")
+	}
+}
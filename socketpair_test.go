@@ -0,0 +1,115 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSocketpair(t *testing.T) {
+	ctx, f, err := ServeSocketpair(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.AddressType != Socketpair {
+		t.Errorf("AddressType = %v, want %v", ctx.AddressType, Socketpair)
+	}
+	client, err := NewSocketpairClient(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/one", "/two"} {
+		resp, err := client.Get("http://socketpair" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != path {
+			t.Errorf("got %q, want %q", body, path)
+		}
+	}
+
+	// Server is done when the other end is closed
+	client.CloseIdleConnections()
+	select {
+	case err := <-ctx.Done:
+		if err != io.EOF {
+			t.Errorf("Done = %v, want %v", err, io.EOF)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server should be done after client end is closed")
+	}
+}
+
+func TestServeAccepted(t *testing.T) {
+	tl, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	client, err := net.Dial("tcp", tl.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := tl.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Like the fd passed by systemd with Accept=yes
+	f, err := conn.File()
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := makeFdConnListener(fd, "connection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := serveListener(&address{addrType: SystemdFD, sysc: &SysdConfig{Accepted: true}}, []net.Listener{l}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("accepted"))
+	}), "", "", serveConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Addr().String() != tl.Addr().String() {
+		t.Errorf("Addr() = %v, want %v", ctx.Addr(), tl.Addr())
+	}
+	if _, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: accepted\r\nConnection: close\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "accepted" {
+		t.Errorf("got %q", body)
+	}
+
+	// Server is done after the connection
+	select {
+	case err := <-ctx.Done:
+		if err != io.EOF {
+			t.Errorf("Done = %v, want %v", err, io.EOF)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server not done after the connection is closed")
+	}
+}
@@ -0,0 +1,125 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// ServeSocketpair serves h on one end of a new unix socketpair and returns the other end. Gives a private http channel,
+// e.g. for a parent process to control a child, without any file system or network footprint. Pass the returned file
+// to the child, e.g. using exec.Cmd.ExtraFiles, and use NewSocketpairClient in the child, or use it in the same process.
+// Server is done when the other end is closed
+func ServeSocketpair(h http.Handler, opts ...Option) (*ServerCtx, *os.File, error) {
+	// SOCK_CLOEXEC is not available on all platforms
+	syscall.ForkLock.RLock()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err == nil {
+		syscall.CloseOnExec(fds[0])
+		syscall.CloseOnExec(fds[1])
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, nil, fmt.Errorf("socketpair error. err: %w", err)
+	}
+	serverFile := os.NewFile(uintptr(fds[0]), "socketpair-server")
+	clientFile := os.NewFile(uintptr(fds[1]), "socketpair-client")
+	c, err := net.FileConn(serverFile)
+	serverFile.Close()
+	if err != nil {
+		clientFile.Close()
+		return nil, nil, fmt.Errorf("socketpair error. err: %w", err)
+	}
+	ctx, err := serveListener(&address{addrType: Socketpair}, []net.Listener{newSingleConnListener(c)}, h, "", "", applyOptions(opts))
+	if err != nil {
+		c.Close()
+		clientFile.Close()
+		return nil, nil, err
+	}
+	return ctx, clientFile, nil
+}
+
+// NewSocketpairClient returns a http client using f, an end of a socketpair returned by ServeSocketpair.
+// URL host is not used, e.g. http://socketpair/status. Requests are sent one at a time on the single connection
+func NewSocketpairClient(f *os.File) (*http.Client, error) {
+	c, err := net.FileConn(f)
+	if err != nil {
+		return nil, fmt.Errorf("socketpair error. err: %w", err)
+	}
+	var mu sync.Mutex
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if c == nil {
+				return nil, errors.New("socketpair error. Connection already used or closed")
+			}
+			conn := c
+			c = nil
+			return conn, nil
+		},
+		MaxConnsPerHost: 1,
+	}}, nil
+}
+
+// singleConnListener returns the connection once. Accept fails after the connection is closed
+type singleConnListener struct {
+	conn      net.Conn
+	accepted  bool
+	mu        sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func newSingleConnListener(c net.Conn) *singleConnListener {
+	l := &singleConnListener{done: make(chan struct{})}
+	l.conn = &notifyCloseConn{Conn: c, onClose: func() { l.close(io.EOF) }}
+	return l
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if !l.accepted {
+		l.accepted = true
+		l.mu.Unlock()
+		return l.conn, nil
+	}
+	l.mu.Unlock()
+	<-l.done
+	return nil, l.err
+}
+
+func (l *singleConnListener) close(err error) {
+	l.closeOnce.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
+
+func (l *singleConnListener) Close() error {
+	l.close(net.ErrClosed)
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+type notifyCloseConn struct {
+	net.Conn
+	onClose func()
+}
+
+func (c *notifyCloseConn) Close() error {
+	c.onClose()
+	return c.Conn.Close()
+}
@@ -0,0 +1,95 @@
+package anyhttp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// socketOptions are tuning options applied to every accepted connection. Supported on all address types
+type socketOptions struct {
+	// SO_RCVBUF in bytes, 0 keeps the system default
+	readBuffer int
+	// SO_SNDBUF in bytes, 0 keeps the system default
+	writeBuffer int
+	// TCP_NODELAY, nil keeps the go default (enabled). Ignored for non tcp connections
+	noDelay *bool
+}
+
+func (o *socketOptions) parse(key, val string) (bool, error) {
+	switch key {
+	case "rcvbuf", "sndbuf":
+		size, err := strconv.Atoi(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad %v: %v, err: %w", key, val, err)
+		}
+		if size < 0 {
+			return true, fmt.Errorf("Bad %v: %v, must not be negative", key, val)
+		}
+		if key == "rcvbuf" {
+			o.readBuffer = size
+		} else {
+			o.writeBuffer = size
+		}
+	case "nodelay":
+		noDelay, err := strconv.ParseBool(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad nodelay: %v, err: %w", val, err)
+		}
+		o.noDelay = &noDelay
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (o *socketOptions) isSet() bool {
+	return o.readBuffer != 0 || o.writeBuffer != 0 || o.noDelay != nil
+}
+
+// wrap returns l as is when no options are set
+func (o socketOptions) wrap(l net.Listener) net.Listener {
+	if !o.isSet() {
+		return l
+	}
+	return &tunedListener{Listener: l, opts: o}
+}
+
+type tunedListener struct {
+	net.Listener
+	opts socketOptions
+}
+
+// unwrap returns the wrapped listener, see ServerCtx.TCPListener
+func (l *tunedListener) unwrap() net.Listener {
+	return l.Listener
+}
+
+// Accept applies the socket options on a best effort basis, similar to how net package sets TCP_NODELAY
+func (l *tunedListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.opts.apply(c)
+	return c, nil
+}
+
+// apply sets the options supported by c, a net.Conn or net.PacketConn
+func (o socketOptions) apply(c any) {
+	if o.readBuffer != 0 {
+		if bc, ok := c.(interface{ SetReadBuffer(int) error }); ok {
+			_ = bc.SetReadBuffer(o.readBuffer)
+		}
+	}
+	if o.writeBuffer != 0 {
+		if bc, ok := c.(interface{ SetWriteBuffer(int) error }); ok {
+			_ = bc.SetWriteBuffer(o.writeBuffer)
+		}
+	}
+	if o.noDelay != nil {
+		if tc, ok := c.(*net.TCPConn); ok {
+			_ = tc.SetNoDelay(*o.noDelay)
+		}
+	}
+}
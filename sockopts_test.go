@@ -0,0 +1,43 @@
+package anyhttp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSocketOptions(t *testing.T) {
+	a, err := parseAddr("unix?path=/tmp/foo.sock&rcvbuf=65536&sndbuf=32768&nodelay=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := socketOptions{readBuffer: 65536, writeBuffer: 32768, noDelay: ptr(false)}
+	got := a.sockOpts
+	if got.readBuffer != want.readBuffer || got.writeBuffer != want.writeBuffer || !check(got.noDelay, want.noDelay) {
+		t.Errorf("parseAddr() sockOpts = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseAddr("sysd?idx=0&rcvbuf=-1"); err == nil {
+		t.Error("parseAddr() should fail for negative rcvbuf")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if (socketOptions{}).wrap(l) != l {
+		t.Error("wrap() should not wrap when no options are set")
+	}
+	tl := want.wrap(l)
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			c.Close()
+		}
+	}()
+	c, err := tl.Accept()
+	if err != nil {
+		t.Fatalf("Accept() failed: %v", err)
+	}
+	c.Close()
+}
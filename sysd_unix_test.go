@@ -0,0 +1,177 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.balki.me/anyhttp/idle"
+)
+
+// activate passes a dup of l as a socket activated fd named name, returns its index
+func activate(t *testing.T, l *net.TCPListener, name string) int {
+	t.Helper()
+	f, err := l.File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Owned by the listener created from it
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetSysdEnv(t)
+	idx := fd - StartFD
+	names := make([]string, idx+1)
+	names[idx] = name
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", strconv.Itoa(idx+1))
+	t.Setenv("LISTEN_FDNAMES", strings.Join(names, ":"))
+	return idx
+}
+
+func TestIdleExit(t *testing.T) {
+	exited := make(chan int, 1)
+	osExit = func(code int) { exited <- code }
+	t.Cleanup(func() { osExit = os.Exit })
+
+	tests := []struct {
+		options  string
+		wantCode int
+		want     string
+	}{
+		{options: "", wantCode: -1, want: "STOPPING=1\nSTATUS=No requests for 100ms, shutting down"},
+		{options: "&idle_exit=3", wantCode: 3, want: "STOPPING=1\nSTATUS=No requests for 100ms, shutting down"},
+		{options: "&idle_exit=0&idle_notify=false", wantCode: 0, want: ""},
+	}
+	for _, tt := range tests {
+		conn := notifySocket(t)
+		tl, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx := activate(t, tl, "web")
+		tl.Close()
+		ctx, err := Serve(fmt.Sprintf("sysd?idx=%d&idle_timeout=100ms%v", idx, tt.options), text("ok"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hooked := make(chan struct{})
+		ctx.RegisterOnShutdown(func() { close(hooked) })
+		if got := readNotify(t, conn, 5*time.Second); got != "READY=1" {
+			t.Errorf("%v: got %q, want READY=1", tt.options, got)
+		}
+		if got := readNotify(t, conn, time.Second); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.options, got, tt.want)
+		}
+		select {
+		case code := <-exited:
+			if code != tt.wantCode {
+				t.Errorf("%v: exit code = %v, want %v", tt.options, code, tt.wantCode)
+			}
+		case err := <-ctx.Done:
+			if tt.wantCode != -1 {
+				t.Errorf("%v: Done = %v, want exit %v", tt.options, err, tt.wantCode)
+			} else if err != nil {
+				t.Errorf("%v: Done = %v", tt.options, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v: not shutdown on idle", tt.options)
+		}
+		select {
+		case <-hooked:
+		default:
+			t.Errorf("%v: RegisterOnShutdown func not called", tt.options)
+		}
+		canceled := make(chan struct{})
+		ctx.Idler.(idle.Controller).Go(func(ctx context.Context) {
+			<-ctx.Done()
+			close(canceled)
+		})
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%v: Idler.Go ctx not canceled on idle shutdown", tt.options)
+		}
+	}
+	if _, err := parseAddr("sysd?idx=0&idle_exit=256"); err == nil {
+		t.Error("parseAddr should fail for bad idle_exit")
+	}
+}
+
+func TestIdleKeepAlive(t *testing.T) {
+	tl, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := activate(t, tl, "web")
+	tl.Close()
+	timeout := 400 * time.Millisecond
+	ctx, err := Serve(fmt.Sprintf("sysd?idx=%d&idle_timeout=%v", idx, timeout), text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The connection is kept open till net/http closes it after idle_timeout
+	client := &http.Client{Transport: &http.Transport{}}
+	resp, err := client.Get(ctx.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	start := time.Now()
+	select {
+	case err := <-ctx.Done:
+		if err != nil {
+			t.Errorf("Done = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shutdown on idle")
+	}
+	// Closing the keep-alive connection should not restart the countdown
+	if took := time.Since(start); took > timeout*7/4 {
+		t.Errorf("shutdown after %v, want about idle_timeout %v", took, timeout)
+	}
+}
+
+func TestIdleOpenConn(t *testing.T) {
+	tl, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := activate(t, tl, "web")
+	tl.Close()
+	ctx, err := Serve(fmt.Sprintf("sysd?idx=%d&idle_timeout=100ms", idx), text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", ctx.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-ctx.Done:
+		t.Fatalf("shutdown with an open connection: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	conn.Close()
+	select {
+	case err := <-ctx.Done:
+		if err != nil {
+			t.Errorf("Done = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shutdown on idle after the connection is closed")
+	}
+}
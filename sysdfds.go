@@ -0,0 +1,90 @@
+package anyhttp
+
+import (
+	"fmt"
+	"net"
+)
+
+// SystemdFDInfo describes a socket activated fd, see ListSystemdFDs
+type SystemdFDInfo struct {
+	// Index to use in sysd?idx=
+	Index int
+	// fd number, StartFD + Index
+	FD int
+	// FileDescriptorName, or the socket unit name by default
+	Name string
+	// Network of the socket, e.g. tcp, udp, unix, unixgram or unixpacket. Empty if not known, e.g. vsock
+	Network string
+	// Local address of the socket, nil if not known
+	Addr net.Addr
+	// Whether the socket is listening, false for Accept=yes connections and datagram sockets
+	Listening bool
+}
+
+// ListSystemdFDs returns the socket activated fds passed by systemd, e.g. to log them or to choose one before calling
+// GetListener. LISTEN_PID is checked and the environment variables are not unset
+func ListSystemdFDs() ([]SystemdFDInfo, error) {
+	sysc := SysdConfig{CheckPID: true}
+	envData, err := sysc.envData()
+	if err != nil {
+		return nil, err
+	}
+	fds := make([]SystemdFDInfo, 0, envData.numFds)
+	for idx := 0; idx < envData.numFds; idx++ {
+		sfd := SystemdFDInfo{Index: idx, FD: StartFD + idx}
+		if idx < len(envData.fdNames) {
+			sfd.Name = envData.fdNames[idx]
+		}
+		if err := sfd.inspect(); err != nil {
+			return nil, err
+		}
+		fds = append(fds, sfd)
+	}
+	return fds, nil
+}
+
+// sysdNetworks are the values for sysd?network=
+var sysdNetworks = []string{"tcp", "tcp4", "tcp6", "unix", "unixpacket", "udp", "udp4", "udp6", "unixgram"}
+
+// verify checks fd is a socket of s.Network, and listening or not as expected. Nothing is checked if s.Network is empty
+func (s *SysdConfig) verify(fd int, name string, listening bool) error {
+	if s.Network == "" {
+		return nil
+	}
+	sfd := SystemdFDInfo{FD: fd, Name: name}
+	if err := sfd.inspect(); err != nil {
+		return err
+	}
+	if sfd.Network == "" {
+		return fmt.Errorf("fd %v, name: %q, network is not known, expected %v", fd, name, s.Network)
+	}
+	network := sfd.Network
+	if ip := addrIP(sfd.Addr); ip != nil && len(s.Network) == 4 {
+		// tcp4, tcp6, udp4 or udp6
+		if ip.To4() != nil {
+			network += "4"
+		} else {
+			network += "6"
+		}
+	}
+	if network != s.Network {
+		return fmt.Errorf("fd %v, name: %q is a %v socket, expected %v", fd, name, network, s.Network)
+	}
+	if listening && !sfd.Listening {
+		return fmt.Errorf("fd %v, name: %q is not listening, expected a listening socket. Accept=yes needs sysd?accepted=true", fd, name)
+	}
+	if !listening && sfd.Listening {
+		return fmt.Errorf("fd %v, name: %q is listening, expected a connection or a datagram socket", fd, name)
+	}
+	return nil
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix || aix
+
+package anyhttp
+
+// inspect does nothing, socket details are not known on this platform
+func (s *SystemdFDInfo) inspect() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package anyhttp_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"go.balki.me/anyhttp"
+	"go.balki.me/anyhttp/anyhttptest"
+)
+
+func TestListSystemdFDs(t *testing.T) {
+	sockets := []anyhttptest.SysdSocket{{Name: "web"}, {Name: "admin", Network: "unix"}}
+	anyhttptest.RunSysd(t, sockets,
+		func(_ context.Context, t *testing.T) {
+			fds, err := anyhttp.ListSystemdFDs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fds) != 2 {
+				t.Fatalf("got %v fds, want 2", len(fds))
+			}
+			web, admin := fds[0], fds[1]
+			if web.Index != 0 || web.FD != 3 || web.Name != "web" || web.Network != "tcp" || !web.Listening {
+				t.Errorf("web = %+v", web)
+			}
+			if addr, ok := web.Addr.(*net.TCPAddr); !ok || addr.Port == 0 || !addr.IP.IsLoopback() {
+				t.Errorf("web.Addr = %v", web.Addr)
+			}
+			if admin.Index != 1 || admin.FD != 4 || admin.Name != "admin" || admin.Network != "unix" || !admin.Listening {
+				t.Errorf("admin = %+v", admin)
+			}
+			// Environment is kept, fds can still be used
+			srv, err := anyhttp.Serve("sysd?name=admin", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = srv.Shutdown(context.Background())
+		}, nil)
+}
+
+func TestListSystemdFDsNotActivated(t *testing.T) {
+	if _, err := anyhttp.ListSystemdFDs(); err == nil {
+		t.Error("ListSystemdFDs should fail when not socket activated")
+	}
+}
+
+func TestPassSystemdFDs(t *testing.T) {
+	sockets := []anyhttptest.SysdSocket{{Name: "web"}, {Name: "admin", Network: "unix"}}
+	anyhttptest.RunSysd(t, sockets,
+		func(_ context.Context, t *testing.T) {
+			// Environment is unset after it is read, e.g. by GetListener. fds are still passed
+			if _, err := anyhttp.ListSystemdFDs(); err != nil {
+				t.Fatal(err)
+			}
+			anyhttp.UnsetSystemdListenVars()
+			if err := anyhttp.PassSystemdFDs(exec.Command(os.Args[0]), "missing"); err == nil {
+				t.Error("PassSystemdFDs should fail for a missing name")
+			}
+			cmd := exec.Command(os.Args[0], "-test.run=^TestPassSystemdFDsChild$", "-test.v")
+			cmd.Env = append(os.Environ(), "ANYHTTP_TEST_PASSED_FDS=1")
+			if err := anyhttp.PassSystemdFDs(cmd, "admin"); err != nil {
+				t.Fatal(err)
+			}
+			out, err := cmd.CombinedOutput()
+			if err != nil || !strings.Contains(string(out), "--- PASS: TestPassSystemdFDsChild") {
+				t.Errorf("child failed: %v, output:\n%s", err, out)
+			}
+		}, nil)
+}
+
+// TestPassSystemdFDsChild is run by TestPassSystemdFDs with the passed fds
+func TestPassSystemdFDsChild(t *testing.T) {
+	if os.Getenv("ANYHTTP_TEST_PASSED_FDS") == "" {
+		t.Skip("run by TestPassSystemdFDs")
+	}
+	fds, err := anyhttp.ListSystemdFDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fds) != 1 || fds[0].FD != 3 || fds[0].Name != "admin" || fds[0].Network != "unix" || !fds[0].Listening {
+		t.Errorf("fds = %+v", fds)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build unix && !aix
+
+package anyhttp
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// inspect sets the network, address and whether the fd is listening
+func (s *SystemdFDInfo) inspect() error {
+	sotype, err := syscall.GetsockoptInt(s.FD, syscall.SOL_SOCKET, syscall.SO_TYPE)
+	if err != nil {
+		return fmt.Errorf("fd %v is not a socket, err: %w", s.FD, err)
+	}
+	if acceptConn, err := syscall.GetsockoptInt(s.FD, syscall.SOL_SOCKET, syscall.SO_ACCEPTCONN); err == nil {
+		s.Listening = acceptConn != 0
+	}
+	sa, err := syscall.Getsockname(s.FD)
+	if err != nil {
+		// Unknown family, e.g. vsock
+		return nil
+	}
+	s.Network, s.Addr = sockaddrNetwork(sa, sotype)
+	return nil
+}
+
+func sockaddrNetwork(sa syscall.Sockaddr, sotype int) (string, net.Addr) {
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return inetNetwork(sa.Addr[:], sa.Port, "", sotype)
+	case *syscall.SockaddrInet6:
+		zone := ""
+		if sa.ZoneId != 0 {
+			if ifi, err := net.InterfaceByIndex(int(sa.ZoneId)); err == nil {
+				zone = ifi.Name
+			}
+		}
+		return inetNetwork(sa.Addr[:], sa.Port, zone, sotype)
+	case *syscall.SockaddrUnix:
+		network := map[int]string{syscall.SOCK_STREAM: "unix", syscall.SOCK_DGRAM: "unixgram", syscall.SOCK_SEQPACKET: "unixpacket"}[sotype]
+		if network == "" {
+			return "", nil
+		}
+		return network, &net.UnixAddr{Name: sa.Name, Net: network}
+	}
+	return "", nil
+}
+
+func inetNetwork(ip []byte, port int, zone string, sotype int) (string, net.Addr) {
+	ip = append([]byte(nil), ip...)
+	switch sotype {
+	case syscall.SOCK_STREAM:
+		return "tcp", &net.TCPAddr{IP: ip, Port: port, Zone: zone}
+	case syscall.SOCK_DGRAM:
+		return "udp", &net.UDPAddr{IP: ip, Port: port, Zone: zone}
+	}
+	return "", nil
+}
@@ -0,0 +1,81 @@
+package anyhttp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// PassSystemdFDs passes socket activated fds to the child process run by cmd, with LISTEN_FDS, LISTEN_FDNAMES and
+// LISTEN_PID set for the child, e.g. for a wrapper that runs the actual server as a child process. Only the fds with
+// names in names are passed, all if none. Works after Serve or GetListener unset the environment variables, see
+// SysdConfig.UnsetEnv.
+//
+// Call before cmd.Start and before adding other files to cmd.ExtraFiles, as the fds have to start at 3. cmd is run via
+// /bin/sh to set LISTEN_PID, which is known only after fork. os/exec sets the passed sockets to blocking mode, so they
+// should not be served by this process too
+func PassSystemdFDs(cmd *exec.Cmd, names ...string) error {
+	if cmd.Process != nil || len(cmd.ExtraFiles) != 0 {
+		return errors.New("pass systemd fds error. Call before cmd.Start and before setting cmd.ExtraFiles")
+	}
+	sysc := SysdConfig{CheckPID: true}
+	envData, err := sysc.envData()
+	if err != nil {
+		return fmt.Errorf("pass systemd fds error. %w", err)
+	}
+	var files []*os.File
+	var fdNames []string
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	for idx := 0; idx < envData.numFds; idx++ {
+		name := "unknown"
+		if idx < len(envData.fdNames) {
+			name = envData.fdNames[idx]
+		}
+		if len(names) > 0 && !contains(names, name) {
+			continue
+		}
+		// Owned by the file, fd is not closed when it is
+		fd, err := dupFD(StartFD + idx)
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("pass systemd fds error. fd %v, name: %q, err: %w", StartFD+idx, name, err)
+		}
+		files = append(files, os.NewFile(uintptr(fd), name))
+		fdNames = append(fdNames, name)
+	}
+	var missing []string
+	for _, name := range names {
+		if !contains(fdNames, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 || len(files) == 0 {
+		closeAll()
+		sort.Strings(missing)
+		return fmt.Errorf("pass systemd fds error. fdNames not found: %q, LISTEN_FDNAMES:%q", missing, envData.fdNamesStr)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	childEnv := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "LISTEN_PID=") && !strings.HasPrefix(kv, "LISTEN_FDS=") && !strings.HasPrefix(kv, "LISTEN_FDNAMES=") {
+			childEnv = append(childEnv, kv)
+		}
+	}
+	cmd.Env = append(childEnv, fmt.Sprintf("LISTEN_FDS=%d", len(files)), "LISTEN_FDNAMES="+strings.Join(fdNames, ":"))
+	cmd.ExtraFiles = files
+	// Same trick as systemd-socket-activate
+	cmd.Args = append([]string{"/bin/sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+	return nil
+}
@@ -0,0 +1,123 @@
+package anyhttp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// tcpOptions are options for binding tcp addresses
+type tcpOptions struct {
+	// SO_REUSEPORT, so that many processes can bind the same address, e.g. for rolling restarts
+	reusePort bool
+	// Keep-alive period of accepted connections, same as net.ListenConfig.KeepAlive. 0 uses go default, negative disables
+	keepAlive time.Duration
+	// Listen backlog, 0 keeps the go default, i.e. somaxconn
+	backlog int
+	// tcp4 or tcp6 to listen only on IPv4 or IPv6. Empty is tcp, dual stack when supported
+	network string
+}
+
+func (o *tcpOptions) parse(key, val string) (bool, error) {
+	switch key {
+	case "reuseport":
+		reusePort, err := strconv.ParseBool(val)
+		if err != nil {
+			return true, fmt.Errorf("Bad reuseport: %v, err: %w", val, err)
+		}
+		o.reusePort = reusePort
+	case "keepalive":
+		period, err := time.ParseDuration(val)
+		if err != nil || period < 0 {
+			return true, fmt.Errorf("Bad keepalive: %v, must be a duration, 0 disables", val)
+		}
+		o.keepAlive = period
+		if period == 0 {
+			o.keepAlive = -1
+		}
+	case "family":
+		switch val {
+		case "4", "6":
+			o.network = "tcp" + val
+		default:
+			return true, fmt.Errorf("Bad family: %v, must be 4 or 6", val)
+		}
+	case "backlog":
+		backlog, err := strconv.Atoi(val)
+		if err != nil || backlog <= 0 {
+			return true, fmt.Errorf("Bad backlog: %v, must be a positive integer", val)
+		}
+		o.backlog = backlog
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// listen binds the tcp address with the options
+func (o *tcpOptions) listen(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{KeepAlive: o.keepAlive}
+	if o.reusePort {
+		lc.Control = reusePortControl
+	}
+	l, err := lc.Listen(context.Background(), o.networkName(), addr)
+	if err != nil || o.backlog == 0 {
+		return l, err
+	}
+	// net package always uses somaxconn. Calling listen again on the listening socket updates the backlog
+	rc, err := l.(syscall.Conn).SyscallConn()
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = setBacklog(fd, o.backlog)
+	}); err != nil {
+		serr = err
+	}
+	if serr != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set backlog: %v, err: %w", o.backlog, serr)
+	}
+	return l, nil
+}
+
+func (o *tcpOptions) networkName() string {
+	if o.network == "" {
+		return "tcp"
+	}
+	return o.network
+}
+
+// parseTCPFamily parses tcp4?addr=:8080 and tcp6?addr=[::]:8080, same as tcp addresses with family option
+func (a *address) parseTCPFamily(network string, query url.Values) (*address, error) {
+	a.addrType = TCP
+	// Recorded as family option, so the address round trips through ListenerConfig
+	if _, err := a.parseCommon("family", strings.TrimPrefix(network, "tcp")); err != nil {
+		return nil, err
+	}
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("%v address error. Multiple %v found: %v", network, key, val)
+		}
+		if key == "addr" {
+			a.tcpAddr = val[0]
+		} else if key == "family" {
+			return nil, fmt.Errorf("%v address error. family can not be an option", network)
+		} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return nil, fmt.Errorf("%v address error. %w", network, cerr)
+		} else if !ok {
+			return nil, fmt.Errorf("%v address error. Bad option; key: %v, val: %v", network, key, val)
+		}
+	}
+	if a.tcpAddr == "" {
+		return nil, fmt.Errorf("%v address error. Missing addr", network)
+	}
+	return a, nil
+}
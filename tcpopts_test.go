@@ -0,0 +1,73 @@
+package anyhttp
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTCPOptions(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    tcpOptions
+		wantErr bool
+	}{
+		{addr: ":8080?keepalive=30s", want: tcpOptions{keepAlive: 30 * time.Second}},
+		{addr: ":8080?keepalive=0&nodelay=false", want: tcpOptions{keepAlive: -1}},
+		{addr: ":8080?backlog=4096&reuseport=true", want: tcpOptions{backlog: 4096, reusePort: true}},
+		{addr: "port_env?env=NOT_SET&fallback=:8080&backlog=16", want: tcpOptions{backlog: 16}},
+		{addr: "127.0.0.1:8080?family=4", want: tcpOptions{network: "tcp4"}},
+		{addr: "tcp6?addr=[::]:8080&backlog=16", want: tcpOptions{network: "tcp6", backlog: 16}},
+		{addr: "tcp4?addr=:8080", want: tcpOptions{network: "tcp4"}},
+		{addr: ":8080?family=5", wantErr: true},
+		{addr: "tcp4", wantErr: true},
+		{addr: "tcp4?addr=:8080&family=6", wantErr: true},
+		{addr: "tcp6?addr=:8080&foo=bar", wantErr: true},
+		{addr: ":8080?keepalive=-1s", wantErr: true},
+		{addr: ":8080?keepalive=often", wantErr: true},
+		{addr: ":8080?backlog=0", wantErr: true},
+		{addr: "unix?path=/run/app.sock&backlog=16", wantErr: true},
+	}
+	for _, tt := range tests {
+		a, err := parseAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAddr(%q) err: %v, wantErr: %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if err == nil && a.tcpOpts != tt.want {
+			t.Errorf("parseAddr(%q) tcpOpts = %+v, want %+v", tt.addr, a.tcpOpts, tt.want)
+		}
+	}
+}
+
+func TestTCPOptionsListen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("backlog is not supported on windows")
+	}
+	ctx, err := Serve("127.0.0.1:0?keepalive=10s&backlog=16", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Server.Close()
+}
+
+func TestTCPFamily(t *testing.T) {
+	ctx, err := Serve("tcp4?addr=127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	if ctx.AddressType != TCP {
+		t.Errorf("AddressType = %v, want TCP", ctx.AddressType)
+	}
+	if _, err := Serve("tcp4?addr=[::1]:0", text("ok")); err == nil {
+		t.Error("tcp4 should not listen on IPv6 address")
+	}
+	var l ListenerConfig
+	if err := l.UnmarshalText([]byte("tcp6?addr=[::]:8080")); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.String(); got != "[::]:8080?family=6" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// upgradeEnv has the listening sockets passed by the previous instance on Upgrade, as <fd>=<address> pairs, with the fd to
+// write to once ready and the pid of the previous instance
+const upgradeEnv = "ANYHTTP_UPGRADE"
+
+// Upgrade starts a new instance of the binary with the same arguments and environment, passing it the listening socket,
+// e.g. on SIGUSR2 after the binary is replaced. When the new instance serves the same address, the passed socket is used
+// instead of binding again, so no connections are refused. Once it is ready, i.e. all passed sockets are served and
+// warmup is done, this server is drained and Upgrade returns. If the new instance exits or is not ready before ctx is
+// done, it is killed and this server keeps serving.
+//
+// Under systemd, the new instance sends MAINPID= with READY=1, which needs NotifyAccess=all in the unit
+func (s *ServerCtx) Upgrade(ctx context.Context) error {
+	return upgrade(ctx, []*ServerCtx{s})
+}
+
+// Upgrade starts a new instance of the binary passing the listening sockets of all servers, see ServerCtx.Upgrade
+func (m *MultiServerCtx) Upgrade(ctx context.Context) error {
+	return upgrade(ctx, m.Servers)
+}
+
+func upgrade(ctx context.Context, servers []*ServerCtx) error {
+	listeners := make(map[string]net.Listener, len(servers))
+	for _, s := range servers {
+		if s.addr == "" || len(s.Listeners) != 1 {
+			return errors.New("upgrade error. Only servers started with Serve, ServeTLS or ServeAll can be upgraded")
+		}
+		listeners[s.addr] = unwrapListener(s.Listeners[0])
+	}
+	if err := startUpgrade(ctx, listeners); err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range servers {
+		s.infof("anyhttp: new instance is ready, draining")
+		// Service is not stopping, the new instance took over
+		s.stopping.Do(func() {})
+		if ul, ok := unwrapListener(s.Listeners[0]).(*net.UnixListener); ok {
+			// Socket file is used by the new instance
+			ul.SetUnlinkOnClose(false)
+		}
+		if err := s.drain(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// inherited has the listening sockets passed by the previous instance, see Upgrade
+var inherited struct {
+	once sync.Once
+	mu   sync.Mutex
+	// fds by address, removed when taken
+	fds map[string]int
+	// servers not ready yet
+	pending int
+	ready   *os.File
+}
+
+// parseUpgradeEnv reads and unsets upgradeEnv. Ignored if it is not set by the parent process
+func parseUpgradeEnv() {
+	val, ok := os.LookupEnv(upgradeEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(upgradeEnv)
+	query, err := url.ParseQuery(val)
+	if err != nil || query.Get("ppid") != strconv.Itoa(os.Getppid()) {
+		return
+	}
+	inherited.fds = map[string]int{}
+	for key, vals := range query {
+		if key == "ppid" || len(vals) != 1 {
+			continue
+		}
+		fd, err := strconv.Atoi(vals[0])
+		if err != nil {
+			continue
+		}
+		closeOnExec(fd)
+		if key == "ready" {
+			inherited.ready = os.NewFile(uintptr(fd), "upgrade-ready")
+			continue
+		}
+		inherited.fds[key] = fd
+	}
+	inherited.pending = len(inherited.fds)
+}
+
+// inheritedListener returns the listener for addr passed by the previous instance. nil if there is none
+func inheritedListener(addr string) (net.Listener, error) {
+	inherited.once.Do(parseUpgradeEnv)
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	fd, ok := inherited.fds[addr]
+	if !ok {
+		return nil, nil
+	}
+	delete(inherited.fds, addr)
+	l, err := makeFdListener(fd, "upgrade")
+	if err != nil {
+		return nil, fmt.Errorf("upgrade error. Bad listener fd for %q, err: %w", addr, err)
+	}
+	return l, nil
+}
+
+// upgraded tells the previous instance that this server is ready, after warmup if set. Once all servers with passed
+// sockets are ready, the previous instance is drained
+func (s *ServerCtx) upgraded() {
+	go func() {
+		if s.warmupDone != nil {
+			<-s.warmupDone
+			if s.warmupErr != nil {
+				return
+			}
+		}
+		inherited.mu.Lock()
+		defer inherited.mu.Unlock()
+		if inherited.pending--; inherited.pending == 0 && inherited.ready != nil {
+			_, _ = inherited.ready.Write([]byte{'R'})
+			inherited.ready.Close()
+			inherited.ready = nil
+		}
+	}()
+}
+
+// readyState is READY=1, with MAINPID= for the instance started by Upgrade, as systemd still has the previous one as main pid
+func readyState() string {
+	inherited.once.Do(parseUpgradeEnv)
+	if inherited.fds != nil {
+		return fmt.Sprintf("MAINPID=%d\nREADY=1", os.Getpid())
+	}
+	return "READY=1"
+}
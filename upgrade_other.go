@@ -0,0 +1,13 @@
+//go:build !unix
+
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+func startUpgrade(ctx context.Context, listeners map[string]net.Listener) error {
+	return errors.New("upgrade error. Not supported on this platform")
+}
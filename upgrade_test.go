@@ -0,0 +1,89 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUpgrade(t *testing.T) {
+	if os.Getenv(upgradeEnv) != "" {
+		upgradeChild()
+	}
+	oldCtx, err := Serve("127.0.0.1:0", text("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + oldCtx.Addr().String()
+	if _, body := get(t, url); body != "old" {
+		t.Fatalf("got %q, want old", body)
+	}
+
+	// New instance runs only this test
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestUpgrade$"}
+	defer func() { os.Args = args }()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := oldCtx.Upgrade(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-oldCtx.Done:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("old Done = %v, want %v", err, http.ErrServerClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("old instance should drain after upgrade")
+	}
+	http.DefaultClient.CloseIdleConnections()
+	if _, body := get(t, url); body != "new" {
+		t.Errorf("got %q, want new", body)
+	}
+}
+
+// upgradeChild serves the passed listener until one request is served and exits
+func upgradeChild() {
+	served := make(chan struct{}, 1)
+	ctx, err := Serve("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new"))
+		served <- struct{}{}
+	}))
+	if err != nil {
+		os.Exit(1)
+	}
+	select {
+	case <-served:
+	case <-time.After(10 * time.Second):
+	}
+	_ = ctx.Shutdown(context.Background())
+	os.Exit(0)
+}
+
+func TestUpgradeNotReady(t *testing.T) {
+	if os.Getenv(upgradeEnv) != "" {
+		// New instance exits without serving the address
+		os.Exit(0)
+	}
+	ctx, err := Serve("127.0.0.1:0", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestUpgradeNotReady$"}
+	defer func() { os.Args = args }()
+	uctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ctx.Upgrade(uctx); err == nil {
+		t.Fatal("Upgrade should fail when the new instance exits")
+	}
+	if _, body := get(t, "http://"+ctx.Addr().String()); body != "ok" {
+		t.Errorf("got %q, server should keep serving", body)
+	}
+}
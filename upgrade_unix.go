@@ -0,0 +1,97 @@
+//go:build unix
+
+package anyhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// startUpgrade starts the new instance with the listeners from fd 3 and waits till it is ready
+func startUpgrade(ctx context.Context, listeners map[string]net.Listener) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("upgrade error. Failed to find executable, err: %w", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("upgrade error. err: %w", err)
+	}
+	defer r.Close()
+	files := []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()}
+	var dups []int
+	defer func() {
+		for _, fd := range dups {
+			syscall.Close(fd)
+		}
+	}()
+	query := url.Values{"ppid": {strconv.Itoa(os.Getpid())}}
+	for addr, l := range listeners {
+		fd, err := dupListener(l)
+		if err != nil {
+			w.Close()
+			return err
+		}
+		dups = append(dups, fd)
+		query.Set(addr, strconv.Itoa(len(files)))
+		files = append(files, uintptr(fd))
+	}
+	query.Set("ready", strconv.Itoa(len(files)))
+	files = append(files, w.Fd())
+
+	env := []string{upgradeEnv + "=" + query.Encode()}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, upgradeEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	pid, err := syscall.ForkExec(exe, os.Args, &syscall.ProcAttr{Env: env, Files: files})
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("upgrade error. Failed to start %v, err: %w", exe, err)
+	}
+	p, _ := os.FindProcess(pid)
+	// Reaps the new instance if it exits before the current one
+	go func() { _, _ = p.Wait() }()
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := r.Read(buf)
+		if err == io.EOF {
+			err = errors.New("new instance exited or closed the ready fd")
+		}
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = p.Kill()
+		return fmt.Errorf("upgrade error. New instance pid %v is not ready, err: %w", pid, err)
+	}
+	return nil
+}
+
+// dupListener returns a dup of the listening socket fd
+func dupListener(l net.Listener) (int, error) {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return 0, fmt.Errorf("upgrade error. Listener %T can not be passed", l)
+	}
+	fd, err := dupConnFD(sc)
+	if err != nil {
+		return 0, fmt.Errorf("upgrade error. Failed to dup listener fd, err: %w", err)
+	}
+	return fd, nil
+}
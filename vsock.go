@@ -0,0 +1,80 @@
+package anyhttp
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// VsockCIDAny listens on all CIDs of the machine, i.e. VMADDR_CID_ANY
+const VsockCIDAny = 0xFFFFFFFF
+
+// VsockConfig has the configuration for AF_VSOCK sockets, used to talk between VMs and their host without networking,
+// e.g. Firecracker, cloud-hypervisor, QEMU. Linux only
+type VsockConfig struct {
+
+	// Context ID to listen on. Usually VsockCIDAny. Host is 2, guests get theirs from the hypervisor
+	CID uint32 `json:"cid" yaml:"cid"`
+
+	// Port to listen on
+	Port uint32 `json:"port" yaml:"port"`
+}
+
+// String returns the canonical address of the config, which can be passed back to Serve, GetListener etc.
+func (v VsockConfig) String() string {
+	q := v.params()
+	return q.encode("vsock")
+}
+
+func (v VsockConfig) params() queryParams {
+	var q queryParams
+	q.set("cid", strconv.FormatUint(uint64(v.CID), 10))
+	q.set("port", strconv.FormatUint(uint64(v.Port), 10))
+	return q
+}
+
+// VsockAddr is the address of vsock listeners and connections
+type VsockAddr struct {
+	CID  uint32
+	Port uint32
+}
+
+// Network returns "vsock"
+func (a *VsockAddr) Network() string {
+	return "vsock"
+}
+
+func (a *VsockAddr) String() string {
+	return fmt.Sprintf("vm(%d):%d", a.CID, a.Port)
+}
+
+// parseVsock parses vsock?cid=3&port=8080. cid defaults to VsockCIDAny
+func (a *address) parseVsock(addr string, query url.Values) (*address, error) {
+	a.vsc = &VsockConfig{CID: VsockCIDAny}
+	a.addrType = Vsock
+	hasPort := false
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("vsock address error. Multiple %v found: %v", key, val)
+		}
+		if key == "cid" || key == "port" {
+			num, err := strconv.ParseUint(val[0], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("vsock address error. Bad %v: %v, err: %w", key, val, err)
+			}
+			if key == "cid" {
+				a.vsc.CID = uint32(num)
+			} else {
+				a.vsc.Port, hasPort = uint32(num), true
+			}
+		} else if ok, cerr := a.parseCommon(key, val[0]); cerr != nil {
+			return nil, fmt.Errorf("vsock address error. %w", cerr)
+		} else if !ok {
+			return nil, fmt.Errorf("vsock address error. Bad option; key: %v, val: %v", key, val)
+		}
+	}
+	if !hasPort {
+		return nil, fmt.Errorf("vsock address error. Missing port; addr: %v", addr)
+	}
+	return a, nil
+}
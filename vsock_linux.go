@@ -0,0 +1,130 @@
+//go:build linux && !386
+
+package anyhttp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const afVsock = 40
+
+// sockaddr_vm from linux/vm_sockets.h. syscall package does not know AF_VSOCK, so bind, accept and getsockname are
+// called directly
+type rawSockaddrVM struct {
+	Family    uint16
+	Reserved1 uint16
+	Port      uint32
+	CID       uint32
+	Flags     uint8
+	Zero      [3]uint8
+}
+
+// GetListener creates the vsock listener
+func (v *VsockConfig) GetListener() (net.Listener, error) {
+	fd, err := syscall.Socket(afVsock, syscall.SOCK_STREAM|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	sa := rawSockaddrVM{Family: afVsock, Port: v.Port, CID: v.CID}
+	if _, _, errno := syscall.Syscall(syscall.SYS_BIND, uintptr(fd), uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa)); errno != 0 {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", errno)
+	}
+	if err := syscall.Listen(fd, syscall.SOMAXCONN); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("listen", err)
+	}
+	// Actual port, when listening on any port
+	addr, err := vsockAddr(uintptr(fd), syscall.SYS_GETSOCKNAME)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), addr.String())
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &vsockListener{f: f, rc: rc, addr: addr}, nil
+}
+
+func vsockAddr(fd uintptr, trap uintptr) (*VsockAddr, error) {
+	var sa rawSockaddrVM
+	n := uint32(unsafe.Sizeof(sa))
+	if _, _, errno := syscall.Syscall(trap, fd, uintptr(unsafe.Pointer(&sa)), uintptr(unsafe.Pointer(&n))); errno != 0 {
+		return nil, os.NewSyscallError("getsockname", errno)
+	}
+	return &VsockAddr{CID: sa.CID, Port: sa.Port}, nil
+}
+
+type vsockListener struct {
+	f    *os.File
+	rc   syscall.RawConn
+	addr *VsockAddr
+}
+
+func (l *vsockListener) Accept() (net.Conn, error) {
+	var nfd uintptr
+	var sa rawSockaddrVM
+	var errno syscall.Errno
+	err := l.rc.Read(func(fd uintptr) bool {
+		for {
+			n := uint32(unsafe.Sizeof(sa))
+			nfd, _, errno = syscall.Syscall6(syscall.SYS_ACCEPT4, fd, uintptr(unsafe.Pointer(&sa)), uintptr(unsafe.Pointer(&n)),
+				syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, 0, 0)
+			if errno != syscall.EINTR {
+				break
+			}
+		}
+		// Wait for the next connection
+		return errno != syscall.EAGAIN
+	})
+	if err == nil && errno != 0 {
+		err = os.NewSyscallError("accept4", errno)
+	}
+	if errors.Is(err, os.ErrClosed) {
+		err = net.ErrClosed
+	}
+	if err != nil {
+		return nil, &net.OpError{Op: "accept", Net: "vsock", Addr: l.addr, Err: err}
+	}
+	local, err := vsockAddr(nfd, syscall.SYS_GETSOCKNAME)
+	if err != nil {
+		syscall.Close(int(nfd))
+		return nil, err
+	}
+	remote := &VsockAddr{CID: sa.CID, Port: sa.Port}
+	return &vsockConn{File: os.NewFile(nfd, remote.String()), local: local, remote: remote}, nil
+}
+
+func (l *vsockListener) Close() error {
+	return l.f.Close()
+}
+
+func (l *vsockListener) Addr() net.Addr {
+	return l.addr
+}
+
+// SyscallConn is used by handoff to pass the listening socket
+func (l *vsockListener) SyscallConn() (syscall.RawConn, error) {
+	return l.rc, nil
+}
+
+// vsockConn uses os.File for reads and writes, which supports deadlines with the runtime poller
+type vsockConn struct {
+	*os.File
+	local, remote *VsockAddr
+}
+
+func (c *vsockConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *vsockConn) RemoteAddr() net.Addr {
+	return c.remote
+}
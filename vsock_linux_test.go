@@ -0,0 +1,60 @@
+//go:build linux && !386
+
+package anyhttp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// dialVsock connects to the port on VMADDR_CID_LOCAL, needs vsock_loopback module
+func dialVsock(t *testing.T, port uint32) *os.File {
+	t.Helper()
+	fd, err := syscall.Socket(afVsock, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa := rawSockaddrVM{Family: afVsock, Port: port, CID: 1}
+	if _, _, errno := syscall.Syscall(syscall.SYS_CONNECT, uintptr(fd), uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa)); errno != 0 {
+		syscall.Close(fd)
+		t.Fatal(errno)
+	}
+	return os.NewFile(uintptr(fd), "vsock")
+}
+
+func TestVsockServe(t *testing.T) {
+	// Any port
+	ctx, err := Serve("vsock?cid=1&port=4294967295", text("over vsock"))
+	if err != nil {
+		t.Skipf("vsock loopback not available: %v", err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if ctx.AddressType != Vsock {
+		t.Errorf("AddressType = %v, want Vsock", ctx.AddressType)
+	}
+	addr, ok := ctx.Addr().(*VsockAddr)
+	if !ok || addr.CID != 1 || addr.Port == VsockCIDAny {
+		t.Fatalf("Addr() = %v", ctx.Addr())
+	}
+
+	c := dialVsock(t, addr.Port)
+	defer c.Close()
+	if _, err := io.WriteString(c, "GET / HTTP/1.1\r\nHost: vm\r\nConnection: close\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "over vsock" {
+		t.Errorf("got %q", body)
+	}
+}
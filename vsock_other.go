@@ -0,0 +1,13 @@
+//go:build !linux || 386
+
+package anyhttp
+
+import (
+	"errors"
+	"net"
+)
+
+// GetListener creates the vsock listener. Not supported on this platform
+func (v *VsockConfig) GetListener() (net.Listener, error) {
+	return nil, errors.New("vsock is only supported on linux")
+}
@@ -0,0 +1,51 @@
+package anyhttp
+
+import (
+	"testing"
+)
+
+func TestParseVsock(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    VsockConfig
+		wantErr bool
+	}{
+		{addr: "vsock?port=8080", want: VsockConfig{CID: VsockCIDAny, Port: 8080}},
+		{addr: "vsock?cid=3&port=8080", want: VsockConfig{CID: 3, Port: 8080}},
+		{addr: "vsock?cid=2&port=80&bind_retry=5s", want: VsockConfig{CID: 2, Port: 80}},
+		{addr: "vsock", wantErr: true},
+		{addr: "vsock?cid=3", wantErr: true},
+		{addr: "vsock?port=-1", wantErr: true},
+		{addr: "vsock?port=8080&cid=x", wantErr: true},
+		{addr: "vsock?port=8080&foo=bar", wantErr: true},
+	}
+	for _, tt := range tests {
+		a, err := parseAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAddr(%q) err: %v, wantErr: %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if a.addrType != Vsock || *a.vsc != tt.want {
+			t.Errorf("parseAddr(%q) = %v, %+v, want %+v", tt.addr, a.addrType, a.vsc, tt.want)
+		}
+		if got := a.vsc.String(); got != tt.want.String() {
+			t.Errorf("String() = %q, want %q", got, tt.want.String())
+		}
+	}
+}
+
+func TestVsockListenerConfig(t *testing.T) {
+	var l ListenerConfig
+	if err := l.UnmarshalText([]byte("vsock?cid=3&port=8080")); err != nil {
+		t.Fatal(err)
+	}
+	if l.Vsock == nil || *l.Vsock != (VsockConfig{CID: 3, Port: 8080}) {
+		t.Fatalf("Vsock = %+v", l.Vsock)
+	}
+	if got := l.String(); got != "vsock?cid=3&port=8080" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,118 @@
+package anyhttp
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// watchedUnixListener recreates the unix socket when the socket file is removed or replaced, and switches Accept to it
+type watchedUnixListener struct {
+	usc UnixSocketConfig
+
+	mu     sync.Mutex
+	cur    net.Listener
+	fi     os.FileInfo
+	closed bool
+	stop   chan struct{}
+}
+
+func watchUnixListener(u *UnixSocketConfig, l net.Listener) (net.Listener, error) {
+	fi, err := os.Stat(u.SocketPath)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	w := &watchedUnixListener{usc: *u, cur: l, fi: fi, stop: make(chan struct{})}
+	// Whatever is at the path when recreating has to be replaced, and the recreated listener is not watched again
+	w.usc.RemoveExisting = true
+	w.usc.WatchInterval = 0
+	go w.watch(u.WatchInterval)
+	return w, nil
+}
+
+func (w *watchedUnixListener) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+func (w *watchedUnixListener) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if fi, err := os.Stat(w.usc.SocketPath); err == nil && os.SameFile(fi, w.fi) {
+		return
+	}
+	l, err := w.usc.GetListener()
+	if err != nil {
+		logPrintf(prioWarning, "anyhttp: unix socket %v was removed or replaced, failed to create again: %v", w.usc.SocketPath, err)
+		return
+	}
+	fi, err := os.Stat(w.usc.SocketPath)
+	if err != nil {
+		l.Close()
+		logPrintf(prioWarning, "anyhttp: unix socket %v was removed or replaced, failed to create again: %v", w.usc.SocketPath, err)
+		return
+	}
+	logPrintf(prioInfo, "anyhttp: unix socket %v was removed or replaced, created again", w.usc.SocketPath)
+	old := w.cur
+	w.cur, w.fi = l, fi
+	// The path belongs to the new socket now
+	if ul, ok := old.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	// Unblocks Accept on the old listener, which then switches to the new one
+	old.Close()
+}
+
+func (w *watchedUnixListener) Accept() (net.Conn, error) {
+	for {
+		w.mu.Lock()
+		cur := w.cur
+		w.mu.Unlock()
+		c, err := cur.Accept()
+		if err != nil {
+			w.mu.Lock()
+			swapped := w.cur != cur && !w.closed
+			w.mu.Unlock()
+			if swapped {
+				continue
+			}
+		}
+		return c, err
+	}
+}
+
+func (w *watchedUnixListener) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+	}
+	return w.cur.Close()
+}
+
+func (w *watchedUnixListener) Addr() net.Addr {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cur.Addr()
+}
+
+// unwrap returns the current listener, see ServerCtx.UnixListener
+func (w *watchedUnixListener) unwrap() net.Listener {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cur
+}
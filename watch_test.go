@@ -0,0 +1,57 @@
+package anyhttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchUnixSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "app.sock")
+	ctx, err := Serve("unix?path="+sockPath+"&watch=10ms", text("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Server.Close()
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sockPath)
+		},
+		DisableKeepAlives: true,
+	}}
+	check := func() {
+		t.Helper()
+		resp, err := client.Get("http://unix/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("got %v, want 200", resp.StatusCode)
+		}
+	}
+	check()
+
+	if err := os.Remove(sockPath); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(sockPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("socket should be created again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	check()
+	if _, ok := ctx.UnixListener(); !ok {
+		t.Error("UnixListener() should return the current listener")
+	}
+}
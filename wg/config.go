@@ -0,0 +1,213 @@
+package wg
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// config is the subset of wg-quick config used by the netstack device
+type config struct {
+	addresses []netip.Addr
+	dns       []netip.Addr
+	mtu       int
+	// UAPI commands for the device, see https://www.wireguard.com/xplatform/#configuration-protocol
+	uapi string
+}
+
+// parseConfig parses a wg-quick style config file, e.g.
+//
+//	[Interface]
+//	PrivateKey = <base64 key>
+//	Address = 10.0.0.2/32
+//
+//	[Peer]
+//	PublicKey = <base64 key>
+//	Endpoint = vpn.example.com:51820
+//	AllowedIPs = 10.0.0.0/24
+func parseConfig(r io.Reader) (*config, error) {
+	cfg := &config{mtu: 1420}
+	var uapi strings.Builder
+	var peers []string
+	var peer *strings.Builder
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			switch section {
+			case "interface":
+			case "peer":
+				peer = &strings.Builder{}
+				peers = append(peers, "")
+			default:
+				return nil, fmt.Errorf("line %v: unknown section: %q", lineNo, line)
+			}
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %v: expected key = value, got: %q", lineNo, line)
+		}
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+		var err error
+		switch section {
+		case "interface":
+			err = cfg.parseInterface(&uapi, key, val)
+		case "peer":
+			err = parsePeer(peer, key, val)
+			peers[len(peers)-1] = peer.String()
+		default:
+			err = fmt.Errorf("%v outside a section", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(uapi.String(), "private_key=") {
+		return nil, fmt.Errorf("missing PrivateKey in [Interface]")
+	}
+	if len(cfg.addresses) == 0 {
+		return nil, fmt.Errorf("missing Address in [Interface]")
+	}
+	for _, p := range peers {
+		if !strings.HasPrefix(p, "public_key=") {
+			return nil, fmt.Errorf("missing PublicKey in [Peer]")
+		}
+		uapi.WriteString(p)
+	}
+	cfg.uapi = uapi.String()
+	return cfg, nil
+}
+
+func (cfg *config) parseInterface(uapi *strings.Builder, key, val string) error {
+	switch key {
+	case "privatekey":
+		k, err := hexKey(val)
+		if err != nil {
+			return fmt.Errorf("bad PrivateKey, err: %w", err)
+		}
+		fmt.Fprintf(uapi, "private_key=%s\n", k)
+	case "listenport":
+		port, err := strconv.ParseUint(val, 10, 16)
+		if err != nil {
+			return fmt.Errorf("bad ListenPort: %v, err: %w", val, err)
+		}
+		fmt.Fprintf(uapi, "listen_port=%d\n", port)
+	case "address":
+		for _, s := range splitList(val) {
+			prefix, err := netip.ParsePrefix(s)
+			if err != nil {
+				addr, aerr := netip.ParseAddr(s)
+				if aerr != nil {
+					return fmt.Errorf("bad Address: %v, err: %w", s, err)
+				}
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			cfg.addresses = append(cfg.addresses, prefix.Addr())
+		}
+	case "dns":
+		for _, s := range splitList(val) {
+			// Search domains are not supported by netstack
+			if addr, err := netip.ParseAddr(s); err == nil {
+				cfg.dns = append(cfg.dns, addr)
+			}
+		}
+	case "mtu":
+		mtu, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("bad MTU: %v, err: %w", val, err)
+		}
+		cfg.mtu = mtu
+	default:
+		// wg-quick only options like PostUp, Table, SaveConfig are not relevant
+	}
+	return nil
+}
+
+func parsePeer(peer *strings.Builder, key, val string) error {
+	switch key {
+	case "publickey":
+		k, err := hexKey(val)
+		if err != nil {
+			return fmt.Errorf("bad PublicKey, err: %w", err)
+		}
+		if peer.Len() != 0 {
+			return fmt.Errorf("PublicKey has to be the first in [Peer]")
+		}
+		fmt.Fprintf(peer, "public_key=%s\n", k)
+		return nil
+	}
+	if peer.Len() == 0 {
+		return fmt.Errorf("PublicKey has to be the first in [Peer]")
+	}
+	switch key {
+	case "presharedkey":
+		k, err := hexKey(val)
+		if err != nil {
+			return fmt.Errorf("bad PresharedKey, err: %w", err)
+		}
+		fmt.Fprintf(peer, "preshared_key=%s\n", k)
+	case "endpoint":
+		addr, err := net.ResolveUDPAddr("udp", val)
+		if err != nil {
+			return fmt.Errorf("bad Endpoint: %v, err: %w", val, err)
+		}
+		fmt.Fprintf(peer, "endpoint=%s\n", addr)
+	case "allowedips":
+		for _, s := range splitList(val) {
+			prefix, err := netip.ParsePrefix(s)
+			if err != nil {
+				return fmt.Errorf("bad AllowedIPs: %v, err: %w", s, err)
+			}
+			fmt.Fprintf(peer, "allowed_ip=%s\n", prefix)
+		}
+	case "persistentkeepalive":
+		if val == "off" {
+			val = "0"
+		}
+		interval, err := strconv.ParseUint(val, 10, 16)
+		if err != nil {
+			return fmt.Errorf("bad PersistentKeepalive: %v, err: %w", val, err)
+		}
+		fmt.Fprintf(peer, "persistent_keepalive_interval=%d\n", interval)
+	default:
+		return fmt.Errorf("unknown peer option: %v", key)
+	}
+	return nil
+}
+
+func hexKey(b64 string) (string, error) {
+	k, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", err
+	}
+	if len(k) != 32 {
+		return "", fmt.Errorf("key should be 32 bytes, got: %v", len(k))
+	}
+	return hex.EncodeToString(k), nil
+}
+
+func splitList(val string) []string {
+	var items []string
+	for _, s := range strings.Split(val, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
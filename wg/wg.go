@@ -0,0 +1,101 @@
+// Package wg adds the wg address scheme to anyhttp, to listen only inside a WireGuard tunnel using a userspace network
+// stack. Nothing is exposed on the host network except the WireGuard UDP port, and no privileges are needed.
+// Import for side effects
+//
+//	import _ "go.balki.me/anyhttp/wg"
+//
+//	anyhttp.Serve("wg?config=/etc/app/wg.conf&port=443", h)
+package wg
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+
+	"go.balki.me/anyhttp"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+// Scheme is the address scheme, also the anyhttp.AddressType of the listeners
+const Scheme = "wg"
+
+func init() {
+	anyhttp.RegisterScheme(Scheme, listen)
+}
+
+// Listen creates the WireGuard device from the wg-quick style config file and listens on port inside the tunnel
+// on all its addresses. The device is closed when the listener is closed
+func Listen(configPath string, port int) (net.Listener, error) {
+	f, err := os.Open(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("wg address error. Failed to open config, err: %w", err)
+	}
+	defer f.Close()
+	cfg, err := parseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("wg address error. Bad config %v, %w", configPath, err)
+	}
+
+	tunDev, tnet, err := netstack.CreateNetTUN(cfg.addresses, cfg.dns, cfg.mtu)
+	if err != nil {
+		return nil, fmt.Errorf("wg address error. Failed to create netstack, err: %w", err)
+	}
+	dev := device.NewDevice(tunDev, conn.NewDefaultBind(), device.NewLogger(device.LogLevelError, "anyhttp/wg: "))
+	if err := dev.IpcSet(cfg.uapi); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("wg address error. Failed to configure device, err: %w", err)
+	}
+	if err := dev.Up(); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("wg address error. Failed to bring up device, err: %w", err)
+	}
+	l, err := tnet.ListenTCP(&net.TCPAddr{Port: port})
+	if err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("wg address error. Failed to listen on port %v, err: %w", port, err)
+	}
+	return &listener{Listener: l, dev: dev}, nil
+}
+
+func listen(query url.Values) (net.Listener, error) {
+	configPath := ""
+	port := 80
+	for key, val := range query {
+		if len(val) != 1 {
+			return nil, fmt.Errorf("wg address error. Multiple %v found: %v", key, val)
+		}
+		switch key {
+		case "config":
+			configPath = val[0]
+		case "port":
+			p, err := strconv.ParseUint(val[0], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("wg address error. Bad port: %v, err: %w", val, err)
+			}
+			port = int(p)
+		default:
+			return nil, fmt.Errorf("wg address error. Bad option; key: %v, val: %v", key, val)
+		}
+	}
+	if configPath == "" {
+		return nil, fmt.Errorf("wg address error. Missing config")
+	}
+	return Listen(configPath, port)
+}
+
+type listener struct {
+	net.Listener
+	dev       *device.Device
+	closeOnce sync.Once
+}
+
+func (l *listener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(l.dev.Close)
+	return err
+}
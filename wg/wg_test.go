@@ -0,0 +1,142 @@
+package wg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.balki.me/anyhttp"
+	"golang.org/x/crypto/curve25519"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+func TestParseConfig(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	cfg, err := parseConfig(strings.NewReader(fmt.Sprintf(`
+[Interface]
+PrivateKey = %[1]s
+Address = 10.0.0.2/32, fd00::2
+DNS = 1.1.1.1, example.com
+PostUp = ignored
+
+[Peer]
+PublicKey = %[1]s
+Endpoint = 127.0.0.1:51820
+AllowedIPs = 10.0.0.0/24
+PersistentKeepalive = 25
+`, key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero := strings.Repeat("00", 32)
+	want := "private_key=" + zero + "\npublic_key=" + zero + "\nendpoint=127.0.0.1:51820\nallowed_ip=10.0.0.0/24\npersistent_keepalive_interval=25\n"
+	if cfg.uapi != want {
+		t.Errorf("uapi =\n%v\nwant:\n%v", cfg.uapi, want)
+	}
+	if fmt.Sprint(cfg.addresses) != "[10.0.0.2 fd00::2]" || fmt.Sprint(cfg.dns) != "[1.1.1.1]" {
+		t.Errorf("addresses: %v, dns: %v", cfg.addresses, cfg.dns)
+	}
+
+	for _, bad := range []string{
+		"[Interface]\nAddress = 10.0.0.2/32",
+		"[Interface]\nPrivateKey = " + key,
+		"[Interface]\nPrivateKey = short\nAddress = 10.0.0.2/32",
+		"[Interface]\nPrivateKey = " + key + "\nAddress = 10.0.0.2/32\n[Peer]\nEndpoint = 127.0.0.1:1",
+		"PrivateKey = " + key,
+	} {
+		if _, err := parseConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseConfig(%q) should fail", bad)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	serverPriv, serverPub := keyPair(t)
+	clientPriv, clientPub := keyPair(t)
+	udpPort := freeUDPPort(t)
+
+	configPath := filepath.Join(t.TempDir(), "wg.conf")
+	serverConf := fmt.Sprintf("[Interface]\nPrivateKey = %v\nAddress = 10.9.0.1/32\nListenPort = %v\n\n[Peer]\nPublicKey = %v\nAllowedIPs = 10.9.0.2/32\n",
+		serverPriv, udpPort, clientPub)
+	if err := os.WriteFile(configPath, []byte(serverConf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := anyhttp.Serve("wg?config="+configPath+"&port=8080", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("inside tunnel"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Shutdown(context.Background())
+	if ctx.AddressType != Scheme {
+		t.Errorf("AddressType = %v, want %v", ctx.AddressType, Scheme)
+	}
+
+	// Client side of the tunnel
+	tunDev, tnet, err := netstack.CreateNetTUN([]netip.Addr{netip.MustParseAddr("10.9.0.2")}, nil, 1420)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := device.NewDevice(tunDev, conn.NewDefaultBind(), device.NewLogger(device.LogLevelError, "client: "))
+	defer dev.Close()
+	clientConf := fmt.Sprintf("[Interface]\nPrivateKey = %v\nAddress = 10.9.0.2/32\n\n[Peer]\nPublicKey = %v\nEndpoint = 127.0.0.1:%v\nAllowedIPs = 10.9.0.1/32\n",
+		clientPriv, serverPub, udpPort)
+	cfg, err := parseConfig(strings.NewReader(clientConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.IpcSet(cfg.uapi); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{DialContext: tnet.DialContext}}
+	resp, err := client.Get("http://10.9.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "inside tunnel" {
+		t.Errorf("got %q, want %q", body, "inside tunnel")
+	}
+}
+
+func keyPair(t *testing.T) (priv, pub string) {
+	t.Helper()
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		t.Fatal(err)
+	}
+	// Clamp as in curve25519 private keys
+	k[0] &= 248
+	k[31] = (k[31] & 127) | 64
+	p, err := curve25519.X25519(k, curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(k), base64.StdEncoding.EncodeToString(p)
+}
+
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).Port
+}
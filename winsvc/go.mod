@@ -0,0 +1,10 @@
+module go.balki.me/anyhttp/winsvc
+
+go 1.23.0
+
+require (
+	go.balki.me/anyhttp v0.0.0
+	golang.org/x/sys v0.32.0
+)
+
+replace go.balki.me/anyhttp => ../
@@ -0,0 +1,14 @@
+// Package winsvc runs anyhttp servers as Windows services. The service control manager is told the service is running
+// once the listener is bound, and Stop and Shutdown requests shutdown the server gracefully, similar to how systemd
+// manages services on Linux. When not started as a service, e.g. interactively or on other platforms, it just waits
+// for the server
+//
+//	srv, err := anyhttp.Serve("127.0.0.1:8080", h)
+//	...
+//	err = winsvc.Run(srv)
+package winsvc
+
+import "time"
+
+// StopTimeout is the time to wait for requests in progress to finish on Stop, before closing the connections
+var StopTimeout = 20 * time.Second
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package winsvc
+
+import "go.balki.me/anyhttp"
+
+// Run waits for the server. On Windows, reports its status to the service control manager when run as a service
+func Run(srv *anyhttp.ServerCtx) error {
+	return srv.Wait()
+}
@@ -0,0 +1,19 @@
+package winsvc
+
+import (
+	"net/http"
+	"testing"
+
+	"go.balki.me/anyhttp"
+)
+
+func TestRunNotService(t *testing.T) {
+	srv, err := anyhttp.Serve("127.0.0.1:0", http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Server.Close()
+	if err := Run(srv); err != http.ErrServerClosed {
+		t.Errorf("Run() = %v, want %v", err, http.ErrServerClosed)
+	}
+}
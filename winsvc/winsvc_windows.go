@@ -0,0 +1,72 @@
+package winsvc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.balki.me/anyhttp"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+)
+
+// Run reports the server as running to the service control manager and serves until Stop or Shutdown is requested,
+// or the server stops by itself, e.g. idle_timeout. When not run as a service, just waits for the server.
+// Returns the error the server stopped with, nil if it was stopped by the service control manager
+func Run(srv *anyhttp.ServerCtx) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return srv.Wait()
+	}
+	h := &handler{srv: srv}
+	// Name is ignored for services running in their own process
+	if err := svc.Run("", h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+type handler struct {
+	srv *anyhttp.ServerCtx
+	err error
+}
+
+func (h *handler) Execute(_ []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	// Listener is already bound by Serve
+	s <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-h.srv.Done:
+			h.err = err
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return false, uint32(windows.ERROR_EXCEPTION_IN_SERVICE)
+			}
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending, WaitHint: uint32(StopTimeout.Milliseconds())}
+				h.err = h.stop()
+				return false, 0
+			}
+		}
+	}
+}
+
+func (h *handler) stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), StopTimeout)
+	defer cancel()
+	err := h.srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = h.srv.Server.Close()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}